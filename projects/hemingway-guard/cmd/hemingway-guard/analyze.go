@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
+)
+
+// errNotApproved makes analyze exit with status 1 without printing an error.
+var errNotApproved = errors.New("message not approved")
+
+// analyzeResult is one analyzed input.
+type analyzeResult struct {
+	Source string `json:"source"`
+	*analyzer.Analysis
+
+	text string // as analyzed, for locating findings
+}
+
+func runAnalyze(args []string) error {
+	return analyzeSources("analyze", "", args)
+}
+
+// runLintMarkdown analyzes Markdown documents, such as PR descriptions,
+// ignoring code and front matter.
+func runLintMarkdown(args []string) error {
+	return analyzeSources("lint-markdown", analyzer.AppMarkdown, args)
+}
+
+// analyzeSources implements analyze and its fixed-app variants. An empty
+// app lets the user choose one with -app.
+func analyzeSources(name, app string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	appFlag := &app
+	channel := new(string)
+	if app == "" {
+		appFlag = fs.String("app", "", "app the message is for, by name or bundle ID (e.g. Slack), or git for commit messages")
+		channel = fs.String("channel", "", "where in the app, e.g. DM, channel or group")
+	}
+	format := fs.String("format", "human", "output format: human, json or sarif")
+	quiet := fs.Bool("q", false, "human format: say nothing about approved messages without findings")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: hemingway-guard %s [flags] [file ...]\n", name)
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Analyzes each file, or stdin when none are given or the file is -.")
+		fmt.Fprintln(fs.Output(), "Exits 1 if any message is not approved.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, []analyzeResult) error
+	switch *format {
+	case "human":
+		write = func(w io.Writer, results []analyzeResult) error {
+			return writeHuman(w, results, *quiet)
+		}
+	case "json":
+		write = writeJSON
+	case "sarif":
+		write = writeSARIF
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	sources := fs.Args()
+	if len(sources) == 0 {
+		sources = []string{"-"}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return err
+	}
+	appCtx := analyzer.AppContext{AppName: appName(*appFlag), ChannelType: *channel}
+	hemingway := cfg.NewAnalyzer()
+
+	var commentChar string
+	if appCtx.AppName == analyzer.AppGit {
+		commentChar = gitCommentChar()
+	}
+
+	var results []analyzeResult
+	approved := true
+	for _, source := range sources {
+		text, err := readSource(source)
+		if err != nil {
+			return err
+		}
+		switch appCtx.AppName {
+		case analyzer.AppGit:
+			text = analyzer.CleanCommitMessage(text, commentChar)
+		case analyzer.AppMarkdown:
+			text = analyzer.MaskMarkdown(text)
+		}
+		analysis, err := hemingway.Analyze(ctx, text, appCtx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		results = append(results, analyzeResult{Source: source, Analysis: analysis, text: text})
+		approved = approved && analysis.Approved
+	}
+
+	if err := write(os.Stdout, results); err != nil {
+		return err
+	}
+	if !approved {
+		return errNotApproved
+	}
+	return nil
+}
+
+// appName resolves a target app's bundle ID or name to its display name.
+// Anything else is passed through, so unlisted apps still give context.
+func appName(s string) string {
+	switch strings.ToLower(s) {
+	case analyzer.AppGit, analyzer.AppMarkdown:
+		return strings.ToLower(s)
+	}
+	for _, target := range apps.DefaultTargets() {
+		if strings.EqualFold(s, target.Name) || s == target.BundleID {
+			return target.Name
+		}
+	}
+	return s
+}
+
+func readSource(source string) (string, error) {
+	if source == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(source)
+	return string(data), err
+}
+
+// displayName is how a source appears in reports.
+func displayName(source string) string {
+	if source == "-" {
+		return "stdin"
+	}
+	return source
+}
+
+func writeHuman(w io.Writer, results []analyzeResult, quiet bool) error {
+	for _, r := range results {
+		if quiet && r.Approved && len(r.Findings) == 0 {
+			continue
+		}
+		verdict := "approved"
+		if !r.Approved {
+			verdict = "needs work"
+		}
+		fmt.Fprintf(w, "%s: %s (%d words, ~%ds read, grade %.1f)\n",
+			displayName(r.Source), verdict, r.WordCount, r.ReadTimeSeconds, r.GradeLevel)
+		if len(r.Findings) > 0 {
+			for _, f := range r.Findings {
+				where := ""
+				if f.Span != nil {
+					line, col := position(r.text, f.Span.Start)
+					where = fmt.Sprintf("%d:%d: ", line, col)
+				}
+				fmt.Fprintf(w, "  - %s%s: %s [%s]\n", where, f.Severity, f.Message, f.Rule)
+			}
+		} else {
+			for _, issue := range r.Issues {
+				fmt.Fprintf(w, "  - %s\n", issue)
+			}
+		}
+		if r.Suggestion != "" {
+			fmt.Fprintf(w, "  suggestion: %s\n", r.Suggestion)
+		}
+	}
+	return nil
+}
+
+// position converts a byte offset to a 1-based line and column, counting
+// columns in characters.
+func position(text string, offset int) (line, col int) {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	before := text[:offset]
+	line = strings.Count(before, "\n") + 1
+	col = utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return line, col
+}
+
+func writeJSON(w io.Writer, results []analyzeResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
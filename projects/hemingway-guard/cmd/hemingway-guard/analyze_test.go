@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// runMain runs the test binary as hemingway-guard with args and returns its
+// standard output and exit status. It reads no user config.
+func runMain(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(),
+		testMainEnv+"=1",
+		"HEMINGWAY_GUARD_CONFIG="+filepath.Join(t.TempDir(), "config.json"),
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return stdout.String(), exit.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return stdout.String(), 0
+}
+
+// golden compares got with the file at name under testdata.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run with -update to accept):\n%s", path, got)
+	}
+}
+
+func TestAnalyzeGolden(t *testing.T) {
+	commits := []string{"testdata/analyze/good.txt", "testdata/analyze/bad.txt"}
+	tests := []struct {
+		golden string
+		args   []string
+		exit   int
+	}{
+		{"git.txt.golden", append([]string{"analyze", "-app", "git"}, commits...), 1},
+		{"git.json.golden", append([]string{"analyze", "-app", "git", "-format", "json"}, commits...), 1},
+		{"git.sarif.golden", append([]string{"analyze", "-app", "git", "-format", "sarif"}, commits...), 1},
+		{"chat.txt.golden", []string{"analyze", "-app", "Slack", "testdata/analyze/chat.txt"}, 0},
+		{"chat.sarif.golden", []string{"analyze", "-app", "Slack", "-format", "sarif", "testdata/analyze/chat.txt"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			out, exit := runMain(t, tt.args...)
+			if exit != tt.exit {
+				t.Errorf("exit status %d, want %d", exit, tt.exit)
+			}
+			golden(t, filepath.Join("analyze", tt.golden), out)
+		})
+	}
+}
+
+func TestAnalyzeExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"approved", []string{"analyze", "-app", "git", "testdata/analyze/good.txt"}, 0},
+		{"not approved", []string{"analyze", "-app", "git", "testdata/analyze/bad.txt"}, 1},
+		{"any not approved", []string{"analyze", "-app", "git", "-q", "testdata/analyze/good.txt", "testdata/analyze/bad.txt"}, 1},
+		{"approved with findings", []string{"analyze", "-app", "Slack", "testdata/analyze/chat.txt"}, 0},
+		{"missing file", []string{"analyze", "testdata/analyze/missing.txt"}, 2},
+		{"unknown format", []string{"analyze", "-format", "xml", "testdata/analyze/good.txt"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, exit := runMain(t, tt.args...); exit != tt.want {
+				t.Errorf("exit status %d, want %d", exit, tt.want)
+			}
+		})
+	}
+
+	// -q says nothing about approved messages
+	if out, _ := runMain(t, "analyze", "-app", "git", "-q", "testdata/analyze/good.txt"); out != "" {
+		t.Errorf("-q printed %q", out)
+	}
+}
+
+func TestSARIFStructure(t *testing.T) {
+	out, _ := runMain(t, "analyze", "-app", "git", "-format", "sarif", "testdata/analyze/good.txt", "testdata/analyze/bad.txt")
+	var log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []struct {
+			ColumnKind string `json:"columnKind"`
+			Tool       struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region *sarifRegion `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, out)
+	}
+	if log.Schema != "https://json.schemastore.org/sarif-2.1.0.json" || log.Version != "2.1.0" {
+		t.Errorf("$schema %q, version %q", log.Schema, log.Version)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("%d runs, want 1", len(log.Runs))
+	}
+	run := log.Runs[0]
+	if run.ColumnKind != "unicodeCodePoints" {
+		t.Errorf("columnKind = %q", run.ColumnKind)
+	}
+	if len(run.Results) != 4 {
+		t.Errorf("%d results, want 4", len(run.Results))
+	}
+	ids := make(map[string]bool)
+	for _, rule := range run.Tool.Driver.Rules {
+		if ids[rule.ID] {
+			t.Errorf("rule %s listed twice", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	for _, r := range run.Results {
+		rules := run.Tool.Driver.Rules
+		if r.RuleIndex < 0 || r.RuleIndex >= len(rules) || rules[r.RuleIndex].ID != r.RuleID {
+			t.Errorf("result for %s has ruleIndex %d", r.RuleID, r.RuleIndex)
+		}
+		loc := r.Locations[0].PhysicalLocation
+		if loc.ArtifactLocation.URI != "testdata/analyze/bad.txt" {
+			t.Errorf("result for %s is in %s", r.RuleID, loc.ArtifactLocation.URI)
+		}
+		if reg := loc.Region; reg == nil || reg.StartLine < 1 || reg.StartColumn < 1 ||
+			reg.EndLine < reg.StartLine || (reg.EndLine == reg.StartLine && reg.EndColumn < reg.StartColumn) {
+			t.Errorf("result for %s has region %+v", r.RuleID, reg)
+		}
+	}
+}
+
+// Issues from the provider have no rule or span; they're reported against
+// the whole file, once per issue.
+func TestSARIFProviderIssues(t *testing.T) {
+	results := []analyzeResult{
+		{Source: "-", Analysis: &analyzer.Analysis{Issues: []string{"too long", "unclear ask"}, Suggestion: "Ship it Friday?"}},
+		{Source: "ok.txt", Analysis: &analyzer.Analysis{Approved: true, Issues: []string{"ignored"}}},
+	}
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != sarifRuleID {
+		t.Errorf("rules = %+v, want just %s", run.Tool.Driver.Rules, sarifRuleID)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("%d results, want one per issue of the unapproved message", len(run.Results))
+	}
+	for i, want := range []string{"too long", "unclear ask"} {
+		r := run.Results[i]
+		loc := r.Locations[0].PhysicalLocation
+		if r.Message.Text != want || r.RuleIndex != 0 || loc.ArtifactLocation.URI != "stdin" || loc.Region != nil {
+			t.Errorf("result %d = %+v", i, r)
+		}
+		if r.Properties["suggestion"] != "Ship it Friday?" {
+			t.Errorf("result %d properties = %v", i, r.Properties)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	const text = "héllo\nwörld 👍 ok"
+	tests := []struct {
+		offset    int
+		line, col int
+	}{
+		{0, 1, 1},
+		{len("hé"), 1, 3},
+		{len("héllo"), 1, 6},
+		{len("héllo\n"), 2, 1},
+		{len("héllo\nwörld 👍 "), 2, 9},
+		{len(text) + 10, 2, 11},
+	}
+	for _, tt := range tests {
+		if line, col := position(text, tt.offset); line != tt.line || col != tt.col {
+			t.Errorf("position(%d) = %d:%d, want %d:%d", tt.offset, line, col, tt.line, tt.col)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+)
+
+func runAXDump(args []string) error {
+	defaults := accessibility.DefaultCaptureOptions()
+
+	fs := flag.NewFlagSet("ax-dump", flag.ContinueOnError)
+	out := fs.String("o", "-", "output file (- for stdout)")
+	delay := fs.Duration("delay", 3*time.Second, "wait before capturing, to focus the target app")
+	depth := fs.Int("depth", defaults.MaxDepth, "maximum tree depth")
+	maxNodes := fs.Int("max-nodes", defaults.MaxNodes, "maximum number of elements")
+	redact := fs.Bool("redact", false, "replace text values with placeholder characters")
+	redactLabels := fs.Bool("redact-labels", false, "also mask titles, descriptions and placeholders (implies -redact)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *delay > 0 {
+		fmt.Fprintf(os.Stderr, "Focus the text field to record; capturing in %s...\n", *delay)
+		time.Sleep(*delay)
+	}
+
+	system := accessibility.SystemWideElement()
+	if system == nil {
+		return accessibility.ErrAccessibilityNotEnabled
+	}
+	defer system.Release()
+
+	focused, err := system.FocusedElement()
+	if err != nil {
+		return fmt.Errorf("no focused element: %w", err)
+	}
+	defer focused.Release()
+
+	snap, err := accessibility.CaptureSnapshot(focused, accessibility.CaptureOptions{
+		MaxDepth: *depth,
+		MaxNodes: *maxNodes,
+	})
+	if err != nil {
+		return err
+	}
+	if *redact || *redactLabels {
+		redactSnapshot(snap.Window, *redactLabels)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := snap.WriteJSON(w); err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Wrote %s snapshot to %s\n", snap.BundleID, *out)
+	}
+	return nil
+}
+
+// redactSnapshot masks message text so snapshots can be shared as fixtures.
+// Letters and digits become "x" and "0"; structure and length are kept.
+//
+// With labels set it also masks titles, descriptions and placeholders, which
+// carry contact and channel names such as "Jane Appleseed (DM) - Acme - Slack".
+// Composer and conversation rules read those labels, so a fixture redacted
+// this way may no longer classify the same. Roles, subroles, identifiers,
+// frames and the shape of the tree are never masked.
+func redactSnapshot(root *accessibility.Node, labels bool) {
+	mask := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= '0' && r <= '9':
+				return '0'
+			case r == ' ' || r == '\n' || r == '\t':
+				return r
+			case r > ' ':
+				return 'x'
+			}
+			return r
+		}, s)
+	}
+	root.Walk(func(n *accessibility.Node) bool {
+		n.Value = mask(n.Value)
+		if labels {
+			n.Title = mask(n.Title)
+			n.Description = mask(n.Description)
+			n.Placeholder = mask(n.Placeholder)
+		}
+		return true
+	})
+}
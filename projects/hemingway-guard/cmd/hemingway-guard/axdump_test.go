@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+)
+
+func dmWindow() *accessibility.Node {
+	return &accessibility.Node{
+		Role:  "AXWindow",
+		Title: "Jane Appleseed (DM) - Acme - Slack",
+		Children: []*accessibility.Node{
+			{Role: "AXStaticText", Value: "see you at 3pm"},
+			{
+				Role:        "AXTextArea",
+				Identifier:  "message-input",
+				Description: "Message to Jane Appleseed",
+				Placeholder: "Message Jane Appleseed",
+				Value:       "running late",
+			},
+		},
+	}
+}
+
+func TestRedactSnapshot(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels bool
+		gone   []string
+		kept   []string
+	}{
+		{"values", false, []string{"3pm", "running late"}, []string{"Jane Appleseed", "message-input"}},
+		{"labels", true, []string{"3pm", "running late", "Jane", "Acme", "DM"}, []string{"message-input", "AXTextArea"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := dmWindow()
+			redactSnapshot(window, tt.labels)
+			raw, err := json.Marshal(window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.gone {
+				if strings.Contains(string(raw), s) {
+					t.Errorf("redacted snapshot still has %q:\n%s", s, raw)
+				}
+			}
+			for _, s := range tt.kept {
+				if !strings.Contains(string(raw), s) {
+					t.Errorf("redacted snapshot lost %q:\n%s", s, raw)
+				}
+			}
+		})
+	}
+
+	// Masking keeps lengths and spacing, and turns digits into 0
+	window := dmWindow()
+	redactSnapshot(window, true)
+	if got, want := window.Title, "xxxx xxxxxxxxx xxxx x xxxx x xxxxx"; got != want {
+		t.Errorf("Title = %q, want %q", got, want)
+	}
+	if got, want := window.Children[0].Value, "xxx xxx xx 0xx"; got != want {
+		t.Errorf("Value = %q, want %q", got, want)
+	}
+}
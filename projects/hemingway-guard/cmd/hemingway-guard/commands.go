@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command is a CLI subcommand. With no subcommand the menubar app runs.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"analyze", "Check messages from files or stdin", runAnalyze},
+	{"apps", "List monitored apps, or enable or disable one in the running app", runApps},
+	{"ax-dump", "Write the focused window's accessibility tree as JSON", runAXDump},
+	{"hook", "Install or uninstall the git commit-msg hook", runHook},
+	{"lint-markdown", "Check Markdown prose, such as PR descriptions", runLintMarkdown},
+	{"lsp", "Serve diagnostics to editors over the Language Server Protocol", runLSP},
+	{"pause", "Stop checking messages for a while, or until resumed", runPause},
+	{"reload", "Make the running app re-read its config file", runReload},
+	{"report", "Summarize the send history week by week", runReport},
+	{"resume", "Resume checking messages after a pause", runResume},
+	{"serve", "Serve the analyzer over HTTP on a loopback address", runServe},
+	{"status", "Show whether the menubar app is running and healthy", runStatus},
+}
+
+// runCommand dispatches a subcommand and returns the process exit code:
+// 0 on success, 1 when analyze rejects a message, 2 on errors.
+func runCommand(name string, args []string) int {
+	if isHelp(name) {
+		usage()
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			if errors.Is(err, errNotApproved) {
+				return 1
+			}
+			fmt.Fprintf(os.Stderr, "hemingway-guard %s: %v\n", name, err)
+			return 2
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "hemingway-guard: unknown command %q\n\n", name)
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: hemingway-guard [command] [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "With no command, runs the menubar app (macOS only).")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+}
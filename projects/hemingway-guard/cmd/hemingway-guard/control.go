@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
+)
+
+// guardState connects the policy to the control socket and the menu bar.
+// It implements ipc.Controller.
+type guardState struct {
+	policy  *policy.Policy
+	started time.Time
+
+	// reload re-reads the config file; hasPopover reports whether a
+	// popover is connected. Both are set by the daemon.
+	reload     func() error
+	hasPopover func() bool
+	// onChange is called after the policy changes, by hand or because a
+	// snooze ran out or a schedule window opened or closed.
+	onChange func()
+}
+
+func newGuardState(p *policy.Policy) *guardState {
+	return &guardState{policy: p, started: time.Now()}
+}
+
+// SetEnabled sets the menu bar switch.
+func (g *guardState) SetEnabled(enabled bool) {
+	g.policy.SetEnabled(enabled)
+	logger.Info("switched", "enabled", enabled)
+	g.changed()
+}
+
+// Snooze pauses checking for d.
+func (g *guardState) Snooze(d time.Duration) {
+	g.Pause(time.Now().Add(d))
+}
+
+// Pause stops checking until the given time, or until Resume if it is
+// zero.
+func (g *guardState) Pause(until time.Time) {
+	g.policy.SnoozeUntil(until)
+	if until.IsZero() {
+		logger.Info("snoozed until resumed")
+	} else {
+		logger.Info("snoozed", "until", until.Format(time.RFC3339))
+	}
+	g.changed()
+}
+
+// Resume ends a snooze.
+func (g *guardState) Resume() {
+	g.policy.Resume()
+	logger.Info("resumed")
+	g.changed()
+}
+
+// Reload re-reads the config file.
+func (g *guardState) Reload() error {
+	if g.reload == nil {
+		return errors.New("reload is not supported")
+	}
+	if err := g.reload(); err != nil {
+		return err
+	}
+	g.changed()
+	return nil
+}
+
+// SetAppEnabled turns checking on or off for one monitored app until the
+// daemon restarts.
+func (g *guardState) SetAppEnabled(bundleID string, enabled bool) error {
+	if !apps.IsTargetApp(bundleID) {
+		return fmt.Errorf("%q is not a monitored app; use one of %s", bundleID, strings.Join(targetBundleIDs(), ", "))
+	}
+	g.policy.SetAppEnabled(bundleID, enabled)
+	logger.Info("app toggled", "bundle_id", bundleID, "enabled", enabled)
+	g.changed()
+	return nil
+}
+
+// Status reports the state for the control socket.
+func (g *guardState) Status() ipc.Status {
+	state := g.policy.State()
+	st := ipc.Status{
+		PID:     os.Getpid(),
+		Started: g.started,
+		Mode:    state.Mode.String(),
+		Enabled: state.Enabled,
+		Paused:  state.Mode == policy.ModeSnoozed,
+		Active:  state.Mode == policy.ModeActive,
+		Apps:    []ipc.AppStatus{},
+	}
+	if !state.SnoozedUntil.IsZero() {
+		st.PausedUntil = &state.SnoozedUntil
+	}
+	if !state.NextChange.IsZero() {
+		st.NextChange = &state.NextChange
+	}
+	for _, target := range apps.DefaultTargets() {
+		st.Apps = append(st.Apps, ipc.AppStatus{
+			Name:        target.Name,
+			BundleID:    target.BundleID,
+			Enabled:     !slices.Contains(state.DisabledApps, target.BundleID),
+			Checked:     g.policy.Enforced(target.BundleID),
+			Enforcement: string(g.policy.Enforcement(target.BundleID)),
+		})
+	}
+	if g.hasPopover != nil {
+		st.PopoverConnected = g.hasPopover()
+	}
+	return st
+}
+
+// watch calls onChange when the policy changes by itself, until ctx is
+// done. It wakes at least once a minute, so a computer waking from sleep
+// catches up.
+func (g *guardState) watch(ctx context.Context) {
+	last := g.policy.State()
+	for {
+		wait := time.Minute
+		if !last.NextChange.IsZero() {
+			wait = min(wait, time.Until(last.NextChange)+time.Second)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		st := g.policy.State()
+		if st.Mode != last.Mode || !st.NextChange.Equal(last.NextChange) {
+			g.changed()
+		}
+		last = st
+	}
+}
+
+func (g *guardState) changed() {
+	if g.onChange != nil {
+		g.onChange()
+	}
+}
+
+func targetBundleIDs() []string {
+	var ids []string
+	for id := range apps.TargetBundleIDs() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sendControl sends req to the running daemon.
+func sendControl(req ipc.ControlRequest) (*ipc.Status, error) {
+	st, err := ipc.SendControl(ipc.DefaultControlSocketPath(), req)
+	if errors.Is(err, ipc.ErrNotRunning) {
+		return nil, fmt.Errorf("%w; start the menubar app first", err)
+	}
+	return st, err
+}
+
+// runPause pauses checking for a duration, until tomorrow or until
+// resumed.
+func runPause(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: hemingway-guard pause [duration | tomorrow]")
+	}
+
+	req := ipc.ControlRequest{Command: ipc.ControlPause}
+	if len(args) == 1 {
+		until, err := pauseEnd(args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		req.Until = &until
+	}
+
+	st, err := sendControl(req)
+	if err != nil {
+		return err
+	}
+	if st.PausedUntil != nil {
+		fmt.Printf("Paused until %s.\n", st.PausedUntil.Local().Format("Mon "+time.Kitchen))
+	} else {
+		fmt.Println("Paused until resumed.")
+	}
+	return nil
+}
+
+// pauseEnd turns "tomorrow" or a duration such as 30m into a time.
+func pauseEnd(arg string, now time.Time) (time.Time, error) {
+	if arg == "tomorrow" {
+		return policy.Tomorrow(now), nil
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil || d < time.Second {
+		return time.Time{}, fmt.Errorf("invalid duration %q; use e.g. 30m, 1h or tomorrow", arg)
+	}
+	return now.Add(d), nil
+}
+
+// runResume ends a pause.
+func runResume(args []string) error {
+	if len(args) > 0 {
+		return errors.New("resume takes no arguments")
+	}
+	st, err := sendControl(ipc.ControlRequest{Command: ipc.ControlResume})
+	if err != nil {
+		return err
+	}
+	if !st.Enabled {
+		fmt.Println("Resumed, but checking is switched off in the menu bar.")
+		return nil
+	}
+	fmt.Println("Resumed.")
+	return nil
+}
+
+// runReload makes the daemon re-read its config file.
+func runReload(args []string) error {
+	if len(args) > 0 {
+		return errors.New("reload takes no arguments")
+	}
+	if _, err := sendControl(ipc.ControlRequest{Command: ipc.ControlReload}); err != nil {
+		return err
+	}
+	fmt.Println("Config reloaded.")
+	return nil
+}
+
+// runApps lists the monitored apps or turns checking on or off for one.
+func runApps(args []string) error {
+	usage := errors.New("usage: hemingway-guard apps [list | enable <bundle-id> | disable <bundle-id>]")
+	req := ipc.ControlRequest{Command: ipc.ControlStatus}
+	switch {
+	case len(args) == 0 || (len(args) == 1 && args[0] == "list"):
+	case len(args) == 2 && args[0] == "enable":
+		req = ipc.ControlRequest{Command: ipc.ControlEnableApp, BundleID: args[1]}
+	case len(args) == 2 && args[0] == "disable":
+		req = ipc.ControlRequest{Command: ipc.ControlDisableApp, BundleID: args[1]}
+	default:
+		return usage
+	}
+
+	st, err := sendControl(req)
+	if err != nil {
+		return err
+	}
+	for _, app := range st.Apps {
+		state := "on"
+		switch {
+		case !app.Enabled:
+			state = "off"
+		case !app.Checked:
+			state = "on, not checked right now"
+		}
+		fmt.Printf("%-10s %-28s %-8s %s\n", app.Name, app.BundleID, app.Enforcement, state)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+)
+
+const slackID = "com.tinyspeck.slackmacgap"
+
+func TestPauseEnd(t *testing.T) {
+	now := time.Date(2026, 3, 11, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		arg  string
+		want time.Time
+		err  bool
+	}{
+		{arg: "30m", want: now.Add(30 * time.Minute)},
+		{arg: "1h30m", want: now.Add(90 * time.Minute)},
+		{arg: "1s", want: now.Add(time.Second)},
+		{arg: "tomorrow", want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{arg: "", err: true},
+		{arg: "soon", err: true},
+		{arg: "30", err: true},
+		{arg: "500ms", err: true},
+		{arg: "0s", err: true},
+		{arg: "-5m", err: true},
+		{arg: "Tomorrow", err: true},
+	}
+	for _, tt := range tests {
+		got, err := pauseEnd(tt.arg, now)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("pauseEnd(%q) = %v, want an error", tt.arg, got)
+		case !tt.err && (err != nil || !got.Equal(tt.want)):
+			t.Errorf("pauseEnd(%q) = %v, %v; want %v", tt.arg, got, err, tt.want)
+		}
+	}
+}
+
+// newTestState returns a guardState and counts its onChange calls.
+func newTestState() (*guardState, *int) {
+	g := newGuardState(policy.New())
+	changes := 0
+	g.onChange = func() { changes++ }
+	return g, &changes
+}
+
+func TestGuardState(t *testing.T) {
+	g, changes := newTestState()
+	st := g.Status()
+	if st.Mode != "active" || !st.Active || st.Paused || !st.Enabled || st.PopoverConnected {
+		t.Errorf("initial status = %+v", st)
+	}
+
+	g.Pause(time.Time{})
+	if st := g.Status(); !st.Paused || st.Active || st.PausedUntil != nil || st.Mode != "snoozed" {
+		t.Errorf("paused until resumed: %+v", st)
+	}
+	until := time.Now().Add(time.Hour)
+	g.Pause(until)
+	if st := g.Status(); !st.Paused || st.PausedUntil == nil || !st.PausedUntil.Equal(until) {
+		t.Errorf("paused for an hour: %+v", st)
+	}
+	g.Resume()
+	if st := g.Status(); st.Paused || !st.Active || st.PausedUntil != nil {
+		t.Errorf("resumed: %+v", st)
+	}
+	if *changes != 3 {
+		t.Errorf("%d changes, want 3", *changes)
+	}
+
+	if err := g.SetAppEnabled("com.example.other", false); err == nil || !strings.Contains(err.Error(), slackID) {
+		t.Errorf("unmonitored app = %v, want an error listing the monitored apps", err)
+	}
+	if err := g.SetAppEnabled(slackID, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, app := range g.Status().Apps {
+		if off := app.BundleID == slackID; app.Enabled == off || app.Checked == off {
+			t.Errorf("after disabling Slack: %+v", app)
+		}
+	}
+
+	g.SetEnabled(false)
+	if st := g.Status(); st.Enabled || st.Active || st.Mode != "off" {
+		t.Errorf("switched off: %+v", st)
+	}
+	if *changes != 5 {
+		t.Errorf("%d changes, want 5", *changes)
+	}
+
+	g.hasPopover = func() bool { return true }
+	if !g.Status().PopoverConnected {
+		t.Error("popover not reported")
+	}
+}
+
+func TestGuardStateReload(t *testing.T) {
+	g, changes := newTestState()
+	if err := g.Reload(); err == nil {
+		t.Error("Reload without a reload func succeeded")
+	}
+
+	reloadErr := errors.New("config: bad JSON")
+	g.reload = func() error { return reloadErr }
+	if err := g.Reload(); !errors.Is(err, reloadErr) {
+		t.Errorf("Reload = %v, want the reload error", err)
+	}
+	if *changes != 0 {
+		t.Errorf("failed reloads changed the state %d times", *changes)
+	}
+
+	g.reload = func() error { return nil }
+	if err := g.Reload(); err != nil || *changes != 1 {
+		t.Errorf("Reload = %v with %d changes, want success and one", err, *changes)
+	}
+}
+
+// The pause, resume, reload and apps commands reach a guardState through
+// the control socket.
+func TestControlCommands(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if _, exit := runMain(t, "resume"); exit != 2 {
+		t.Errorf("resume with nothing running: exit status %d, want 2", exit)
+	}
+
+	g := newGuardState(policy.New())
+	reloads := make(chan struct{}, 1)
+	g.reload = func() error { reloads <- struct{}{}; return nil }
+	s := ipc.NewControlServer(ipc.DefaultControlSocketPath(), g)
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve(context.Background())
+
+	tests := []struct {
+		args  []string
+		exit  int
+		out   string
+		check func(policy.State) bool
+	}{
+		{[]string{"pause", "30m"}, 0, "Paused until ", func(st policy.State) bool {
+			d := time.Until(st.SnoozedUntil)
+			return st.Mode == policy.ModeSnoozed && d > 29*time.Minute && d <= 30*time.Minute
+		}},
+		{[]string{"pause"}, 0, "Paused until resumed.\n", func(st policy.State) bool {
+			return st.Mode == policy.ModeSnoozed && st.SnoozedUntil.IsZero()
+		}},
+		{[]string{"resume"}, 0, "Resumed.\n", func(st policy.State) bool { return st.Mode == policy.ModeActive }},
+		{[]string{"pause", "soon"}, 2, "", func(st policy.State) bool { return st.Mode == policy.ModeActive }},
+		{[]string{"reload"}, 0, "Config reloaded.\n", nil},
+		{[]string{"apps", "disable", slackID}, 0, "Slack", func(st policy.State) bool {
+			return len(st.DisabledApps) == 1 && st.DisabledApps[0] == slackID
+		}},
+		{[]string{"apps", "enable", "com.example.other"}, 2, "", func(st policy.State) bool { return len(st.DisabledApps) == 1 }},
+		{[]string{"apps", "enable", slackID}, 0, "Slack", func(st policy.State) bool { return len(st.DisabledApps) == 0 }},
+		{[]string{"apps", "explode"}, 2, "", nil},
+	}
+	for _, tt := range tests {
+		name := strings.Join(tt.args, " ")
+		out, exit := runMain(t, tt.args...)
+		if exit != tt.exit || !strings.Contains(out, tt.out) {
+			t.Errorf("%s: exit status %d, output %q; want %d and %q", name, exit, out, tt.exit, tt.out)
+		}
+		if tt.check != nil && !tt.check(g.policy.State()) {
+			t.Errorf("%s: policy state %+v", name, g.policy.State())
+		}
+	}
+	select {
+	case <-reloads:
+	default:
+		t.Error("reload didn't reach the daemon")
+	}
+
+	out, _ := runMain(t, "apps", "disable", slackID)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.Contains(line, slackID) != strings.HasSuffix(line, " off") {
+			t.Errorf("apps after disabling Slack: %q", line)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework Cocoa
+
+#include <Cocoa/Cocoa.h>
+
+void runApp() {
+    @autoreleasepool {
+        [NSApplication sharedApplication];
+        [NSApp setActivationPolicy:NSApplicationActivationPolicyAccessory];
+        [NSApp run];
+    }
+}
+
+void stopApp() {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        [NSApp terminate:nil];
+    });
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/history"
+	"github.com/lancekrogers/hemingway-guard/internal/httpapi"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+	"github.com/lancekrogers/hemingway-guard/internal/keyboard"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/pipeline"
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+	"github.com/lancekrogers/hemingway-guard/internal/ui"
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
+)
+
+// recentMessageCount is how many messages above the composer are sent to
+// the analyzer as conversation context.
+const recentMessageCount = 5
+
+// runDaemon runs the menubar app until the user quits.
+func runDaemon(args []string) {
+	opts, err := parseDaemonFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hemingway-guard: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, cfgErr := config.LoadDefault()
+	if cfgErr != nil {
+		cfg = config.Default()
+	}
+	closer, err := setupDaemonLogging(cfg, opts)
+	defer closer.Close()
+	if err != nil {
+		logger.Warn("logging set up with errors, logging to stderr only", logging.Err(err))
+	}
+	if cfgErr != nil {
+		logger.Warn("config error, using defaults", logging.Err(cfgErr))
+	}
+	logger.Info("HemingwayGuard starting")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle signals
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		logger.Info("shutting down")
+		if live := accessibility.LiveElements(); live > 0 {
+			logger.Warn("accessibility elements still live at shutdown", "count", live)
+		}
+		cancel()
+		C.stopApp()
+	}()
+
+	// Initialize components. Reload swaps in a new analyzer, for the
+	// HTTP API too. The API is opt-in: it only starts when an address is
+	// configured.
+	var checker atomic.Pointer[analyzer.Analyzer]
+	checker.Store(cfg.NewAnalyzer())
+	var api *httpapi.Server
+	if cfg.API.Addr != "" {
+		api = newAPIServer(cfg, cfg.API.Addr)
+		api.SetAnalyzer(checker.Load())
+	}
+	menuBar := ui.NewMenuBar()
+	focusMonitor := accessibility.NewFocusMonitor(apps.DefaultTargets())
+	interceptor := keyboard.NewInterceptor()
+	popover := ipc.NewServer(ipc.DefaultSocketPath())
+	popover.SetToken(os.Getenv("HEMINGWAY_GUARD_TOKEN"))
+	executor := pipeline.NewExecutor(focusMonitor, interceptor)
+	executor.SetPaster(pipeline.PasterFunc(keyboard.PasteText))
+	executor.SetNotifier(popover)
+	hist := openRecorder(ctx, cfg)
+	defer hist.Close()
+
+	// The menu bar and the control socket both change whether messages
+	// are checked; focusedApp is the monitored field's app, "" for none
+	pol := policy.New()
+	if rules, err := cfg.ScheduleRules(); err == nil {
+		pol.SetRules(rules)
+	}
+	if mode, appModes, err := cfg.EnforcementModes(); err == nil {
+		pol.SetEnforcement(mode, appModes)
+	}
+	state := newGuardState(pol)
+	var focusedApp atomic.Value
+	focusedApp.Store("")
+	refreshMonitoring := func() {
+		bundleID := focusedApp.Load().(string)
+		interceptor.SetMonitoring(bundleID != "" && pol.Enforced(bundleID))
+	}
+	state.onChange = func() {
+		menuBar.SetState(pol.State())
+		refreshMonitoring()
+	}
+	state.hasPopover = popover.HasClient
+	state.reload = func() error {
+		newCfg, err := config.LoadDefault()
+		if err != nil {
+			return err
+		}
+		rules, err := newCfg.ScheduleRules()
+		if err != nil {
+			return err
+		}
+		mode, appModes, err := newCfg.EnforcementModes()
+		if err != nil {
+			return err
+		}
+		checker.Store(newCfg.NewAnalyzer())
+		if api != nil {
+			api.SetAnalyzer(checker.Load())
+		}
+		pol.SetRules(rules)
+		pol.SetEnforcement(mode, appModes)
+		logger.Info("config reloaded")
+		return nil
+	}
+
+	// Set up menu bar
+	ui.SetMenuCallback(func(action ui.MenuAction) {
+		switch action {
+		case ui.MenuActionToggleEnabled:
+			state.SetEnabled(!pol.Enabled())
+
+		case ui.MenuActionSnooze15Min:
+			state.Snooze(15 * time.Minute)
+
+		case ui.MenuActionSnoozeHour:
+			state.Snooze(time.Hour)
+
+		case ui.MenuActionSnoozeDay:
+			state.Pause(policy.Tomorrow(time.Now()))
+
+		case ui.MenuActionResume:
+			state.Resume()
+
+		case ui.MenuActionSettings:
+			logger.Info("settings clicked (not implemented)")
+
+		case ui.MenuActionQuit:
+			cancel()
+			C.stopApp()
+		}
+	})
+
+	// Set up focus monitoring
+	focusMonitor.OnTextFieldFocus(func(element *accessibility.Element, bundleID string) {
+		focusedApp.Store(bundleID)
+		refreshMonitoring()
+		logger.Debug("text field focused", "bundle_id", bundleID, "checked", pol.Enforced(bundleID))
+	})
+
+	focusMonitor.OnTextFieldBlur(func() {
+		focusedApp.Store("")
+		refreshMonitoring()
+		logger.Debug("stopped monitoring text field")
+	})
+
+	// Set up keyboard interception
+	interceptor.SetHandler(func(ctx context.Context) bool {
+		// Monitoring lags a snooze running out or a schedule window
+		// closing by up to a minute; the policy itself doesn't
+		if !pol.Enforced(focusedApp.Load().(string)) {
+			return true
+		}
+
+		text := focusMonitor.CurrentText()
+		if text == "" {
+			return true // Allow empty messages
+		}
+
+		// Get current app context
+		elem := focusMonitor.CurrentElement()
+		appCtx := analyzer.AppContext{}
+		if elem != nil {
+			defer elem.Release()
+			target := apps.FindTarget(elem.BundleID())
+			if target != nil {
+				appCtx.AppName = target.Name
+			}
+
+			conv, err := accessibility.CaptureConversation(elem, recentMessageCount)
+			if err == nil {
+				appCtx.ConversationTitle = conv.Title
+				appCtx.ChannelType = conv.ChannelType
+				appCtx.RecentMessages = conv.RecentMessages
+				appCtx.InThread = conv.InThread
+			}
+		}
+
+		// Analyze the message
+		logger.Info("analyzing message", "app", appCtx.AppName, "text", logging.Content(text))
+		started := time.Now()
+		analysis, err := checker.Load().Analyze(ctx, text, appCtx)
+		if err != nil && (analysis == nil || !analysis.Blocked) {
+			// Of the local rules' results, only a block outlives a failed analysis
+			analysis = nil
+		}
+		rec := history.NewRecord(analysis, text, appCtx, time.Since(started))
+		if err != nil {
+			logger.Error("analysis failed", "blocked", analysis != nil, logging.Err(err))
+		} else {
+			// Issues quote the message, so only rule IDs are logged
+			rules := make([]string, 0, len(analysis.Findings))
+			for _, f := range analysis.Findings {
+				rules = append(rules, f.Rule)
+			}
+			logger.Info("analyzed message", "approved", analysis.Approved, "blocked", analysis.Blocked,
+				"words", analysis.WordCount, "issues", len(analysis.Issues), "rules", rules)
+		}
+
+		// The enforcement mode decides what happens to messages that
+		// weren't approved, and what the popover offers
+		verdict := policy.VerdictOf(analysis, err)
+		mode := pol.Enforcement(focusedApp.Load().(string))
+		decision := policy.Decide(mode, verdict, popover.HasClient() && elem != nil)
+		noReview := "popover not connected"
+		if popover.HasClient() {
+			noReview = "field lost focus"
+		}
+
+		switch decision.Outcome {
+		case policy.OutcomeSend:
+			switch {
+			case verdict == policy.VerdictApproved:
+				hist.record(rec, history.ActionSent)
+				return true // Message is good, allow sending
+			case verdict == policy.VerdictFailed:
+				// Already logged
+			case mode == policy.EnforceObserve:
+				logger.Info("message has issues, observing only")
+			default:
+				logger.Info("message has issues but allowing", "reason", noReview)
+			}
+			hist.record(rec, history.ActionAllowed)
+			return true
+
+		case policy.OutcomeAdvise:
+			if err := popover.Notify(adviceNotice(analysis)); err != nil {
+				logger.Debug("advice not shown", logging.Err(err))
+			}
+			hist.record(rec, history.ActionAdvised)
+			return true
+
+		case policy.OutcomeHold:
+			logger.Warn("message held", "enforcement", mode, "verdict", verdict, "reason", noReview)
+			hist.record(rec, history.ActionHeld)
+			return false
+		}
+
+		// Hold the Enter and let the user decide in the popover. The
+		// fingerprint makes sure the answer lands in this same field.
+		req := ipc.ReviewRequest{Analysis: analysis, OriginalText: text, Actions: decision.Actions}
+		if p, ok := elem.PopoverAnchor(); ok {
+			req.Anchor = &ipc.Anchor{X: p.X, Y: p.Y}
+		}
+		fp := pipeline.CaptureFingerprint(elem, text)
+		go func() {
+			defer fp.Release()
+
+			resp, err := popover.RequestAction(ctx, req)
+			if err != nil {
+				logger.Warn("review failed, message held", logging.Err(err))
+				hist.record(rec, history.ActionHeld)
+				return
+			}
+
+			result, err := executor.Execute(resp, req, fp)
+			if err != nil {
+				logger.Warn("action failed, message held", "action", resp.Action, logging.Err(err))
+				rec.Attempted = string(resp.Action)
+				hist.record(rec, history.ActionFailed)
+				return
+			}
+			hist.record(rec, string(resp.Action))
+			logger.Info("action applied", "action", resp.Action,
+				"replaced", result.Replaced, "pasted", result.Pasted, "sent", result.Sent)
+		}()
+		return false
+	})
+
+	// Start components
+	if err := popover.Listen(); err != nil {
+		logger.Error("failed to start popover server", logging.Err(err))
+		os.Exit(1)
+	}
+	defer popover.Close()
+	go func() {
+		if err := popover.Serve(ctx); err != nil && err != ipc.ErrServerClosed {
+			logger.Error("popover server stopped", logging.Err(err))
+		}
+	}()
+
+	control := ipc.NewControlServer(ipc.DefaultControlSocketPath(), state)
+	if err := control.Listen(); err != nil {
+		logger.Warn("control socket not started", logging.Err(err))
+	} else {
+		defer control.Close()
+		go func() {
+			if err := control.Serve(ctx); err != nil && err != ipc.ErrServerClosed {
+				logger.Error("control server stopped", logging.Err(err))
+			}
+		}()
+	}
+
+	if api != nil {
+		if err := api.Listen(); err != nil {
+			logger.Warn("HTTP API not started", logging.Err(err))
+		} else {
+			defer api.Close()
+			go func() {
+				if err := api.Serve(ctx); err != nil && err != http.ErrServerClosed {
+					logger.Error("HTTP API stopped", logging.Err(err))
+				}
+			}()
+			logger.Info("HTTP API listening", "addr", api.Addr())
+		}
+	}
+
+	// Metrics are on by default so `hemingway-guard status` works
+	defer serveMetrics(ctx, cfg).Close()
+
+	if err := focusMonitor.Start(ctx); err != nil {
+		logger.Error("failed to start focus monitor", logging.Err(err))
+		os.Exit(1)
+	}
+	defer focusMonitor.Stop()
+
+	if err := interceptor.Start(ctx); err != nil {
+		logger.Error("failed to start interceptor", logging.Err(err))
+		os.Exit(1)
+	}
+	defer interceptor.Stop()
+
+	// Show menu bar
+	menuBar.Show(ui.Title(pol.State()))
+	menuBar.SetState(pol.State())
+	go state.watch(ctx)
+
+	logger.Info("HemingwayGuard ready", "apps", "Messages, Slack, Discord")
+
+	// Run the app (blocks until quit)
+	C.runApp()
+}
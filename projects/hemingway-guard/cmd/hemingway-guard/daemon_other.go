@@ -0,0 +1,17 @@
+//go:build !darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// runDaemon explains that the menubar app needs macOS. Subcommands such as
+// analyze work everywhere.
+func runDaemon(args []string) {
+	fmt.Fprintln(os.Stderr, "hemingway-guard: the menubar app requires macOS")
+	fmt.Fprintln(os.Stderr)
+	usage()
+	os.Exit(2)
+}
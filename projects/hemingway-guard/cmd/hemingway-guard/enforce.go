@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+// adviceNotice is what the popover shows about a message sent under the
+// advise enforcement mode. Blocked messages are never sent that way.
+func adviceNotice(a *analyzer.Analysis) string {
+	switch n := len(a.Issues); {
+	case n == 0:
+		return "Sent, but it wasn't approved."
+	case n == 1:
+		return "Sent with one issue: " + a.Issues[0]
+	default:
+		return fmt.Sprintf("Sent with %d issues, first: %s", n, a.Issues[0])
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+func TestAdviceNotice(t *testing.T) {
+	tests := []struct {
+		issues []string
+		want   string
+	}{
+		{nil, "Sent, but it wasn't approved."},
+		{[]string{"too long"}, "Sent with one issue: too long"},
+		{[]string{"too long", "passive voice"}, "Sent with 2 issues, first: too long"},
+	}
+	for _, tt := range tests {
+		if got := adviceNotice(&analyzer.Analysis{Issues: tt.issues}); got != tt.want {
+			t.Errorf("adviceNotice(%q) = %q, want %q", tt.issues, got, tt.want)
+		}
+	}
+}
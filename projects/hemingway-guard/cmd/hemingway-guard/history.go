@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/history"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// pruneInterval is how often the daemon applies history retention.
+const pruneInterval = time.Hour
+
+// recorder appends intercepted sends to the history. The zero recorder,
+// used when history is off or unavailable, records nothing.
+type recorder struct {
+	store *history.Store
+}
+
+// openRecorder opens the history unless the config turns it off, and
+// prunes it every pruneInterval until ctx is done.
+func openRecorder(ctx context.Context, cfg *config.Config) recorder {
+	if cfg.History.Disabled {
+		return recorder{}
+	}
+	store, err := cfg.OpenHistory()
+	if err != nil {
+		logger.Warn("history not recorded", logging.Err(err))
+		return recorder{}
+	}
+
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := store.Prune(now); err != nil {
+					logger.Warn("failed to prune history", logging.Err(err))
+				}
+			}
+		}
+	}()
+	return recorder{store: store}
+}
+
+// record appends rec with the outcome of the send.
+func (r recorder) record(rec history.Record, action string) {
+	if r.store == nil {
+		return
+	}
+	rec.Action = action
+	if err := r.store.Append(rec); err != nil {
+		logger.Warn("failed to record history", logging.Err(err))
+	}
+}
+
+// Close closes the history.
+func (r recorder) Close() error {
+	if r.store == nil {
+		return nil
+	}
+	return r.store.Close()
+}
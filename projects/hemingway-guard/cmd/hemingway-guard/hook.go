@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies hooks written by this command, so they can be
+// replaced or removed without touching anyone else's.
+const hookMarker = "# Installed by hemingway-guard hook install."
+
+func runHook(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: hemingway-guard hook install|uninstall [-force]")
+	}
+
+	fs := flag.NewFlagSet("hook "+args[0], flag.ContinueOnError)
+	force := fs.Bool("force", false, "replace an existing commit-msg hook")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	path, err := commitMsgHookPath()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "install":
+		return installHook(path, *force)
+	case "uninstall":
+		return uninstallHook(path)
+	}
+	return fmt.Errorf("unknown hook action %q", args[0])
+}
+
+// commitMsgHookPath asks git where hooks live, which respects
+// core.hooksPath and worktrees.
+func commitMsgHookPath() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(string(out)), "commit-msg"), nil
+}
+
+// gitCommentChar returns the repository's comment character for commit
+// messages, or empty for git's default. core.commentString is the newer
+// name for the same setting.
+func gitCommentChar() string {
+	for _, key := range []string{"core.commentString", "core.commentChar"} {
+		out, err := exec.Command("git", "config", "--get", key).Output()
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return ""
+}
+
+func installHook(path string, force bool) error {
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil && !bytes.Contains(existing, []byte(hookMarker)) && !force:
+		return fmt.Errorf("%s already exists; pass -force to replace it", path)
+	case err != nil && !os.IsNotExist(err):
+		return err
+	}
+
+	bin, err := hookBinary()
+	if err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf(`#!/bin/sh
+%s
+# Checks commit messages for conciseness. Skip once with: git commit --no-verify
+exec %s analyze -app git -q "$1"
+`, hookMarker, shellQuote(bin))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Installed commit-msg hook at %s\n", path)
+	return nil
+}
+
+func uninstallHook(path string) error {
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(existing, []byte(hookMarker)) {
+		return fmt.Errorf("%s was not installed by hemingway-guard; leaving it alone", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Removed commit-msg hook at %s\n", path)
+	return nil
+}
+
+// hookBinary returns how the hook should invoke us: by name when we're on
+// PATH, so upgrades are picked up, otherwise by absolute path.
+func hookBinary() (string, error) {
+	if path, err := exec.LookPath("hemingway-guard"); err == nil && filepath.IsAbs(path) {
+		return "hemingway-guard", nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(exe, os.TempDir()) {
+		return "", errors.New("refusing to install a hook pointing at a temporary binary (go run); install hemingway-guard first")
+	}
+	return exe, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testMainEnv makes the test binary run as hemingway-guard, so an
+// installed hook can call it.
+const testMainEnv = "HEMINGWAY_GUARD_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// onPath puts the test binary on PATH as hemingway-guard and returns the
+// new PATH.
+func onPath(t *testing.T) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(bin, "hemingway-guard")); err != nil {
+		t.Fatal(err)
+	}
+	path := bin + string(os.PathListSeparator) + os.Getenv("PATH")
+	t.Setenv("PATH", path)
+	return path
+}
+
+func TestInstallHook(t *testing.T) {
+	onPath(t)
+	path := filepath.Join(t.TempDir(), "hooks", "commit-msg")
+
+	if err := installHook(path, false); err != nil {
+		t.Fatalf("installHook: %v", err)
+	}
+	script, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(script), hookMarker) || !strings.Contains(string(script), `'hemingway-guard' analyze -app git -q "$1"`) {
+		t.Errorf("hook script:\n%s", script)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm()&0o111 == 0 {
+		t.Errorf("hook isn't executable: %v", fi.Mode())
+	}
+
+	// Reinstalling over our own hook is fine
+	if err := installHook(path, false); err != nil {
+		t.Errorf("reinstall: %v", err)
+	}
+	if err := uninstallHook(path); err != nil {
+		t.Fatalf("uninstallHook: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("hook still there after uninstall: %v", err)
+	}
+	if err := uninstallHook(path); err != nil {
+		t.Errorf("uninstall without a hook: %v", err)
+	}
+}
+
+func TestInstallHookKeepsOtherHooks(t *testing.T) {
+	onPath(t)
+	path := filepath.Join(t.TempDir(), "commit-msg")
+	const theirs = "#!/bin/sh\nexec commitlint --edit \"$1\"\n"
+	if err := os.WriteFile(path, []byte(theirs), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := installHook(path, false); err == nil {
+		t.Error("replaced another hook without -force")
+	}
+	if err := uninstallHook(path); err == nil {
+		t.Error("removed another hook")
+	}
+	if got, _ := os.ReadFile(path); string(got) != theirs {
+		t.Errorf("other hook changed to:\n%s", got)
+	}
+
+	if err := installHook(path, true); err != nil {
+		t.Fatalf("installHook -force: %v", err)
+	}
+	if got, _ := os.ReadFile(path); !strings.Contains(string(got), hookMarker) {
+		t.Error("-force didn't install the hook")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got, want := shellQuote("/Applications/It's Here/bin"), `'/Applications/It'\''s Here/bin'`; got != want {
+		t.Errorf("shellQuote = %s, want %s", got, want)
+	}
+}
+
+// git runs git in dir with a clean environment and returns its combined
+// output.
+func git(t *testing.T, dir, path string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + path,
+		"HOME=" + dir,
+		"XDG_CONFIG_HOME=" + filepath.Join(dir, ".config"),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		testMainEnv + "=1",
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// The installed hook runs analyze on each commit message and stops commits
+// that aren't approved.
+func TestInstalledHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	path := onPath(t)
+	repo := t.TempDir()
+	if out, err := git(t, repo, path, "init", "-q"); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	if err := installHook(filepath.Join(repo, ".git", "hooks", "commit-msg"), false); err != nil {
+		t.Fatalf("installHook: %v", err)
+	}
+
+	commit := func(msg string) (string, error) {
+		file := filepath.Join(repo, ".git", "MSG")
+		if err := os.WriteFile(file, []byte(msg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return git(t, repo, path, "commit", "-q", "--allow-empty", "-F", file)
+	}
+
+	if out, err := commit("Add the parser\n\nIt reads the config file.\n"); err != nil {
+		t.Fatalf("good message refused: %v\n%s", err, out)
+	}
+	out, err := commit("Add the parser\nno blank line before the body\n")
+	if err == nil {
+		t.Fatal("message without a blank line was committed")
+	}
+	if !strings.Contains(out, "blank line") {
+		t.Errorf("hook output doesn't explain the refusal:\n%s", out)
+	}
+
+	// Comment lines use the configured character and aren't checked
+	if out, err := git(t, repo, path, "config", "core.commentChar", ";"); err != nil {
+		t.Fatalf("git config: %v\n%s", err, out)
+	}
+	if out, err := commit("Add the lexer\n; Please enter the commit message for your changes.\n"); err != nil {
+		t.Errorf("comment line was checked: %v\n%s", err, out)
+	}
+}
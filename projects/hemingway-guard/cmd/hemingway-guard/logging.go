@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// logger logs the menubar app's events.
+var logger = logging.Component("daemon")
+
+// daemonOptions are the menubar app's flags.
+type daemonOptions struct {
+	logLevel   string
+	logContent time.Duration
+}
+
+func parseDaemonFlags(args []string) (daemonOptions, error) {
+	var opts daemonOptions
+	fs := flag.NewFlagSet("hemingway-guard", flag.ContinueOnError)
+	fs.StringVar(&opts.logLevel, "log-level", "", "log `level`: debug, info, warn or error (default from config)")
+	fs.DurationVar(&opts.logContent, "log-content", 0, fmt.Sprintf("log message text for `duration`, at most %v", logging.MaxContentWindow))
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: hemingway-guard [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if _, err := logging.ParseLevel(opts.logLevel); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// setupDaemonLogging logs to stderr and the rotating log file, as the
+// config and flags say. It opens a content window if -log-content is set.
+// If the log options are invalid or the file can't be opened, it still
+// logs to stderr with the flags applied, and returns the error with a
+// closer.
+func setupDaemonLogging(cfg *config.Config, opts daemonOptions) (io.Closer, error) {
+	logOpts, optsErr := cfg.LogOptions()
+	if optsErr != nil {
+		logOpts = logging.Options{Level: slog.LevelInfo}
+	}
+	if opts.logLevel != "" {
+		logOpts.Level, _ = logging.ParseLevel(opts.logLevel) // checked by parseDaemonFlags
+	}
+	logOpts.Stderr = true
+	closer, err := logging.Setup(logOpts)
+	if err != nil {
+		logOpts.File = ""
+		closer, _ = logging.Setup(logOpts) // stderr only; can't fail
+	}
+	if opts.logContent > 0 {
+		until := logging.EnableContent(opts.logContent)
+		logger.Warn("logging message text", "until", until.Format(time.TimeOnly))
+	}
+	return closer, errors.Join(optsErr, err)
+}
+
+// setupCommandLogging keeps commands quiet: their output is the result,
+// and lsp's stdout carries the protocol. Only warnings reach stderr.
+func setupCommandLogging() {
+	logging.Setup(logging.Options{Level: slog.LevelWarn, Stderr: true})
+}
+
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "--help"
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// When logging can't be set up as configured, -log-content still opens a
+// content window and the error comes back.
+func TestSetupDaemonLoggingErrors(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		logging.EnableContent(0)
+	})
+
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	// A file where the log directory goes stops the log file opening
+	if err := os.WriteFile(filepath.Join(state, "hemingway-guard"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	badPrivacy := config.Default()
+	badPrivacy.Log.Privacy = "plain"
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{"log file", config.Default()},
+		{"log options", badPrivacy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logging.EnableContent(0)
+			closer, err := setupDaemonLogging(tt.cfg, daemonOptions{logLevel: "debug", logContent: time.Minute})
+			if err == nil {
+				t.Error("setupDaemonLogging succeeded")
+			}
+			if closer == nil {
+				t.Fatal("no closer")
+			}
+			defer closer.Close()
+			if !logging.ContentEnabled() {
+				t.Error("-log-content was dropped")
+			}
+			if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+				t.Error("-log-level was dropped")
+			}
+		})
+	}
+}
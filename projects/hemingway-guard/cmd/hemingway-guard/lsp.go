@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/lsp"
+)
+
+// runLSP serves diagnostics to an editor over stdio, with the same rules
+// the menubar app uses.
+func runLSP(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	app := fs.String("app", "", "app context for plain text documents, as for analyze")
+	channel := fs.String("channel", "", "where in the app, e.g. DM, channel or group")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return err
+	}
+	server := lsp.NewServer(cfg.NewAnalyzer())
+	server.SetAppContext(analyzer.AppContext{AppName: appName(*app), ChannelType: *channel})
+	server.SetCommentChar(gitCommentChar())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return server.Serve(ctx, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/history"
+)
+
+// runReport summarizes the send history week by week.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	weeks := fs.Int("weeks", 4, "number of weeks to cover, ending with this one")
+	app := fs.String("app", "", "only count messages sent in this app")
+	format := fs.String("format", "human", "output format: human, markdown or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *weeks < 1 {
+		return errors.New("-weeks must be at least 1")
+	}
+
+	var write func(io.Writer, []history.Week) error
+	switch *format {
+	case "human":
+		write = writeReportHuman
+	case "markdown":
+		write = writeReportMarkdown
+	case "json":
+		write = writeReportJSON
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	path, err := history.DefaultPath()
+	if err != nil {
+		return err
+	}
+	// Read-only: the menubar app may be appending as the report runs
+	store, err := history.OpenReadOnly(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no history at %s yet; it is recorded while the menubar app runs", path)
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	now := time.Now()
+	records, err := store.Query(history.Query{
+		Since: history.WeekStart(now).AddDate(0, 0, -7*(*weeks-1)),
+		App:   appName(*app),
+	})
+	if err != nil {
+		return err
+	}
+	return write(os.Stdout, history.Weekly(records, *weeks, now))
+}
+
+func writeReportJSON(w io.Writer, weeks []history.Week) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{"weeks": weeks})
+}
+
+func writeReportHuman(w io.Writer, weeks []history.Week) error {
+	for i := len(weeks) - 1; i >= 0; i-- {
+		week := weeks[i]
+		fmt.Fprintf(w, "Week of %s: %s\n", week.Start.Format("Mon Jan 2"), weekSummary(week))
+		if week.Messages == 0 {
+			fmt.Fprintln(w)
+			continue
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  App\tMessages\tAvg words\tAvg grade")
+		for _, a := range week.Apps {
+			fmt.Fprintf(tw, "  %s\t%d\t%.1f\t%.1f\n", appLabel(a.App), a.Messages, a.AvgWords, a.AvgGrade)
+		}
+		tw.Flush()
+		if len(week.TopRules) > 0 {
+			fmt.Fprintf(w, "  Top rules: %s\n", ruleList(week.TopRules, "%s (%d)"))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(weeks) > 1 {
+		fmt.Fprintln(w, "Trends, oldest to newest:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, t := range trends(weeks) {
+			fmt.Fprintf(tw, "  %s\t%s\t%s -> %s\n", t.name, sparkline(t.values), t.format(t.values[0]), t.format(t.values[len(t.values)-1]))
+		}
+		tw.Flush()
+	}
+	return nil
+}
+
+func writeReportMarkdown(w io.Writer, weeks []history.Week) error {
+	fmt.Fprintln(w, "# HemingwayGuard report")
+	for i := len(weeks) - 1; i >= 0; i-- {
+		week := weeks[i]
+		fmt.Fprintf(w, "\n## Week of %s\n\n", week.Start.Format(time.DateOnly))
+		fmt.Fprintln(w, capitalize(weekSummary(week))+".")
+		if week.Messages == 0 {
+			continue
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| App | Messages | Avg words | Avg grade |")
+		fmt.Fprintln(w, "| --- | ---: | ---: | ---: |")
+		for _, a := range week.Apps {
+			fmt.Fprintf(w, "| %s | %d | %.1f | %.1f |\n", appLabel(a.App), a.Messages, a.AvgWords, a.AvgGrade)
+		}
+		if len(week.TopRules) > 0 {
+			fmt.Fprintf(w, "\nTop rules: %s\n", ruleList(week.TopRules, "`%s` (%d)"))
+		}
+	}
+
+	if len(weeks) > 1 {
+		fmt.Fprintln(w, "\n## Trends")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "| Metric | Trend | Week of %s | This week |\n", weeks[0].Start.Format(time.DateOnly))
+		fmt.Fprintln(w, "| --- | --- | ---: | ---: |")
+		for _, t := range trends(weeks) {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", t.name, sparkline(t.values), t.format(t.values[0]), t.format(t.values[len(t.values)-1]))
+		}
+	}
+	return nil
+}
+
+// weekSummary describes a week in a line, without the date.
+func weekSummary(week history.Week) string {
+	if week.Messages == 0 {
+		return "no messages"
+	}
+	s := fmt.Sprintf("%d messages, avg %.0f words, grade %.1f", week.Messages, week.AvgWords, week.AvgGrade)
+	if week.Reviewed > 0 {
+		s += fmt.Sprintf("; %d reviewed, %s took the suggestion, %s sent anyway",
+			week.Reviewed, percent(week.SuggestionRate), percent(week.OverrideRate))
+		if week.Failed > 0 {
+			s += fmt.Sprintf(", %d not applied", week.Failed)
+		}
+	}
+	return s
+}
+
+// trend is one metric across the report's weeks.
+type trend struct {
+	name   string
+	values []float64
+	format func(float64) string
+}
+
+func trends(weeks []history.Week) []trend {
+	metric := func(name string, value func(history.Week) float64, format func(float64) string) trend {
+		t := trend{name: name, format: format}
+		for _, week := range weeks {
+			t.values = append(t.values, value(week))
+		}
+		return t
+	}
+	count := func(v float64) string { return fmt.Sprintf("%.0f", v) }
+	decimal := func(v float64) string { return fmt.Sprintf("%.1f", v) }
+	return []trend{
+		metric("Messages", func(w history.Week) float64 { return float64(w.Messages) }, count),
+		metric("Avg words", func(w history.Week) float64 { return w.AvgWords }, decimal),
+		metric("Avg grade", func(w history.Week) float64 { return w.AvgGrade }, decimal),
+		metric("Suggestions taken", func(w history.Week) float64 { return w.SuggestionRate }, percent),
+		metric("Sent anyway", func(w history.Week) float64 { return w.OverrideRate }, percent),
+	}
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values as bars scaled from zero to the largest value.
+func sparkline(values []float64) string {
+	top := 0.0
+	for _, v := range values {
+		top = max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if top > 0 {
+			i = int(v / top * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+func ruleList(rules []history.RuleHits, format string) string {
+	parts := make([]string, len(rules))
+	for i, r := range rules {
+		parts[i] = fmt.Sprintf(format, r.Rule, r.Messages)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.0f%%", v*100)
+}
+
+func appLabel(app string) string {
+	if app == "" {
+		return "(unknown)"
+	}
+	return app
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/history"
+)
+
+// reportWeeks is two weeks of history summarized on a fixed day.
+func reportWeeks() []history.Week {
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)
+	lastWeek := time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)
+	records := []history.Record{
+		{Time: lastWeek, App: "Slack", Verdict: history.VerdictApproved, Action: history.ActionSent, Words: 12, GradeLevel: 5},
+		{Time: lastWeek, App: "Slack", Verdict: history.VerdictRejected, Action: "send_anyway", Words: 80, GradeLevel: 11, Rules: []string{"chat/length"}},
+		{Time: now, App: "Slack", Verdict: history.VerdictRejected, Action: "use_suggestion", Words: 40, GradeLevel: 7, Rules: []string{"chat/length", "style/passive"}},
+		{Time: now, App: "Discord", Verdict: history.VerdictRejected, Action: history.ActionFailed, Attempted: "send_anyway", Words: 20, GradeLevel: 6, Rules: []string{"style/passive"}},
+		{Time: now, App: "", Verdict: history.VerdictApproved, Action: history.ActionSent, Words: 6, GradeLevel: 3},
+	}
+	return history.Weekly(records, 2, now)
+}
+
+func TestReportFormats(t *testing.T) {
+	tests := []struct {
+		golden string
+		write  func(io.Writer, []history.Week) error
+	}{
+		{"report.txt.golden", writeReportHuman},
+		{"report.md.golden", writeReportMarkdown},
+		{"report.json.golden", writeReportJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf, reportWeeks()); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, filepath.Join("report", tt.golden), buf.String())
+		})
+	}
+}
+
+func TestReportCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+
+	if _, exit := runMain(t, "report"); exit != 2 {
+		t.Errorf("report without history: exit status %d, want 2", exit)
+	}
+
+	path, err := history.DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, rec := range []history.Record{
+		{Time: now, App: "Slack", Verdict: history.VerdictApproved, Action: history.ActionSent},
+		{Time: now, App: "Discord", Verdict: history.VerdictApproved, Action: history.ActionSent},
+		{Time: history.WeekStart(now).AddDate(0, 0, -7), App: "Slack", Verdict: history.VerdictApproved, Action: history.ActionSent},
+	} {
+		if err := store.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	out, exit := runMain(t, "report", "-weeks", "2", "-app", "slack", "-format", "json")
+	if exit != 0 {
+		t.Fatalf("exit status %d", exit)
+	}
+	var report struct {
+		Weeks []history.Week `json:"weeks"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, out)
+	}
+	if len(report.Weeks) != 2 || report.Weeks[0].Messages != 1 || report.Weeks[1].Messages != 1 {
+		t.Errorf("report = %s, want one Slack message in each week", out)
+	}
+
+	for _, args := range [][]string{{"report", "-weeks", "0"}, {"report", "-format", "csv"}} {
+		if _, exit := runMain(t, args...); exit != 2 {
+			t.Errorf("%s: exit status %d, want 2", strings.Join(args, " "), exit)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   string
+	}{
+		{[]float64{0, 0, 0}, "▁▁▁"},
+		{[]float64{0, 1, 2, 4}, "▁▂▄█"},
+		{[]float64{3}, "█"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.values); got != tt.want {
+			t.Errorf("sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+// SARIF 2.1.0, trimmed to what code scanning tools need to show findings.
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+// sarifRuleID covers issues that didn't come from a local rule.
+const sarifRuleID = "hemingway"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// writeSARIF reports each finding as a result at its location. Issues
+// without a local rule are reported against the whole file, and only for
+// unapproved messages.
+func writeSARIF(w io.Writer, results []analyzeResult) error {
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: "hemingway-guard", Rules: []sarifRule{}}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	index := make(map[string]int)
+	addRule := func(id, description string) int {
+		i, ok := index[id]
+		if !ok {
+			i = len(run.Tool.Driver.Rules)
+			index[id] = i
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               id,
+				ShortDescription: sarifMessage{Text: description},
+			})
+		}
+		return i
+	}
+
+	for _, r := range results {
+		var properties map[string]string
+		if r.Suggestion != "" {
+			properties = map[string]string{"suggestion": r.Suggestion}
+		}
+		locate := func(span *analyzer.Span) []sarifLocation {
+			loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: displayName(r.Source)}}
+			if span != nil {
+				startLine, startCol := position(r.text, span.Start)
+				endLine, endCol := position(r.text, span.End)
+				loc.Region = &sarifRegion{StartLine: startLine, StartColumn: startCol, EndLine: endLine, EndColumn: endCol}
+			}
+			return []sarifLocation{{PhysicalLocation: loc}}
+		}
+
+		for _, f := range r.Findings {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:     f.Rule,
+				RuleIndex:  addRule(f.Rule, f.Message),
+				Level:      sarifLevel(f.Severity),
+				Message:    sarifMessage{Text: f.Message},
+				Locations:  locate(f.Span),
+				Properties: properties,
+			})
+		}
+		if len(r.Findings) > 0 || r.Approved {
+			continue
+		}
+
+		issues := r.Issues
+		if len(issues) == 0 {
+			issues = []string{"message not approved"}
+		}
+		ruleIndex := addRule(sarifRuleID, "Message is not concise or clear enough to send")
+		for _, issue := range issues {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:     sarifRuleID,
+				RuleIndex:  ruleIndex,
+				Level:      "warning",
+				Message:    sarifMessage{Text: issue},
+				Locations:  locate(nil),
+				Properties: properties,
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func sarifLevel(s analyzer.Severity) string {
+	switch s {
+	case analyzer.SeverityError, analyzer.SeverityBlock:
+		return "error"
+	case analyzer.SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/httpapi"
+)
+
+// runServe runs the HTTP API on its own, without the menubar app.
+func runServe(args []string) error {
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return err
+	}
+	defaultAddr := cfg.API.Addr
+	if defaultAddr == "" {
+		defaultAddr = httpapi.DefaultAddr
+	}
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr, "loopback address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	api := newAPIServer(cfg, *addr)
+	if err := api.Listen(); err != nil {
+		if errors.Is(err, httpapi.ErrNoToken) {
+			return fmt.Errorf("%w; set HEMINGWAY_GUARD_API_TOKEN or api.token in the config file", err)
+		}
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Serving the HTTP API on http://%s\n", api.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := api.Serve(ctx); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newAPIServer creates the HTTP API with the configured rules and token.
+func newAPIServer(cfg *config.Config, addr string) *httpapi.Server {
+	api := httpapi.NewServer(addr, cfg.NewAnalyzer())
+	api.SetToken(cfg.APIToken())
+	api.SetAppResolver(appName)
+	return api
+}
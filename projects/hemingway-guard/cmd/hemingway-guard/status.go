@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/config"
+	"github.com/lancekrogers/hemingway-guard/internal/httpapi"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+)
+
+// serveMetrics serves /metrics on the configured loopback address until
+// ctx is done. The returned closer stops it; it is a no-op when metrics
+// are off or the address couldn't be bound.
+func serveMetrics(ctx context.Context, cfg *config.Config) io.Closer {
+	addr := cfg.MetricsAddr()
+	if addr == "" {
+		return io.NopCloser(nil)
+	}
+	srv := httpapi.NewMetricsServer(addr, metrics.Default)
+	if err := srv.Listen(); err != nil {
+		logger.Warn("metrics not served", logging.Err(err))
+		return io.NopCloser(nil)
+	}
+	go func() {
+		if err := srv.Serve(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server stopped", logging.Err(err))
+		}
+	}()
+	logger.Info("metrics listening", "addr", srv.Addr())
+	return srv
+}
+
+// runStatus reports the running daemon's state and, when its metrics are
+// served, how it has been doing.
+func runStatus(args []string) error {
+	cfg, err := config.LoadDefault()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	addr := fs.String("addr", cfg.MetricsAddr(), "address of the daemon's metrics endpoint")
+	asJSON := fs.Bool("json", false, "print the daemon's state as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st, err := sendControl(ipc.ControlRequest{Command: ipc.ControlStatus})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	}
+
+	now := time.Now()
+	writeState(os.Stdout, st, now)
+	if *addr == "" {
+		return nil
+	}
+	url := "http://" + *addr + "/metrics"
+	samples, err := fetchMetrics(url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nNo metrics: %v\n", err)
+		return nil
+	}
+	fmt.Println()
+	writeStatus(os.Stdout, samples)
+	return nil
+}
+
+// writeState describes whether and where the daemon checks messages.
+func writeState(w io.Writer, st *ipc.Status, now time.Time) {
+	fmt.Fprintf(w, "HemingwayGuard is running (pid %d, up %s)\n", st.PID, now.Sub(st.Started).Round(time.Second))
+	switch {
+	case !st.Enabled:
+		fmt.Fprintln(w, "Checking is switched off in the menu bar.")
+	case st.Paused && st.PausedUntil != nil:
+		fmt.Fprintf(w, "Paused until %s (%s left).\n", st.PausedUntil.Local().Format(time.Kitchen),
+			st.PausedUntil.Sub(now).Round(time.Second))
+	case st.Paused:
+		fmt.Fprintln(w, "Paused until resumed.")
+	case st.Mode == policy.ModeOutsideSchedule.String() && st.NextChange != nil:
+		fmt.Fprintf(w, "Outside the schedule until %s.\n", st.NextChange.Local().Format("Mon "+time.Kitchen))
+	case st.Mode == policy.ModeOutsideSchedule.String():
+		fmt.Fprintln(w, "Outside the schedule.")
+	default:
+		fmt.Fprintln(w, "Checking messages.")
+	}
+
+	var checked, idle, off []string
+	for _, app := range st.Apps {
+		switch {
+		case !app.Enabled:
+			off = append(off, app.Name)
+		case app.Checked:
+			checked = append(checked, app.Name)
+		default:
+			idle = append(idle, app.Name)
+		}
+	}
+	if len(checked) > 0 {
+		fmt.Fprintf(w, "Checking: %s\n", strings.Join(checked, ", "))
+	}
+	if st.Active && len(idle) > 0 {
+		fmt.Fprintf(w, "Outside their schedule: %s\n", strings.Join(idle, ", "))
+	}
+	if len(off) > 0 {
+		fmt.Fprintf(w, "Turned off in: %s\n", strings.Join(off, ", "))
+	}
+	if line := enforcementLine(st.Apps); line != "" {
+		fmt.Fprintln(w, line)
+	}
+	if !st.PopoverConnected {
+		fmt.Fprintln(w, "No popover is connected, so messages with findings can't be reviewed.")
+	}
+}
+
+// enforcementLine names the enforcement mode, and the apps with a mode of
+// their own.
+func enforcementLine(apps []ipc.AppStatus) string {
+	if len(apps) == 0 {
+		return ""
+	}
+	count := make(map[string]int)
+	for _, app := range apps {
+		count[app.Enforcement]++
+	}
+	common := apps[0].Enforcement
+	for mode, n := range count {
+		if n > count[common] || (n == count[common] && mode < common) {
+			common = mode
+		}
+	}
+	line := "Enforcement: " + common
+	for _, app := range apps {
+		if app.Enforcement != common {
+			line += fmt.Sprintf(", %s in %s", app.Enforcement, app.Name)
+		}
+	}
+	return line
+}
+
+func fetchMetrics(url string) (metrics.Samples, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("nothing serving metrics at %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return metrics.ParseText(resp.Body)
+}
+
+// writeStatus summarizes the daemon's metrics and warns about what needs
+// attention.
+func writeStatus(w io.Writer, s metrics.Samples) {
+	intercepts := s.Get("hemingway_guard_intercepts_total")
+	analyses := s.Sum("hemingway_guard_analyses_total")
+	failed := s.Sum("hemingway_guard_analyses_total", "result", "error")
+	timedOut := s.Sum("hemingway_guard_analyses_total", "result", "timeout")
+	reenables := s.Sum("hemingway_guard_tap_reenables_total")
+	ipcErrors := s.Sum("hemingway_guard_ipc_errors_total")
+
+	avg := "-"
+	if n := s.Get("hemingway_guard_analysis_duration_seconds_count"); n > 0 {
+		mean := s.Get("hemingway_guard_analysis_duration_seconds_sum") / n
+		d := time.Duration(mean * float64(time.Second))
+		if d >= time.Millisecond {
+			d = d.Round(time.Millisecond)
+		} else {
+			d = d.Round(time.Microsecond)
+		}
+		avg = d.String()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  Intercepted sends\t%.0f\n", intercepts)
+	fmt.Fprintf(tw, "  Analyses\t%.0f ok, %.0f failed, %.0f timed out\n", analyses-failed-timedOut, failed, timedOut)
+	fmt.Fprintf(tw, "  Avg analysis time\t%s\n", avg)
+	fmt.Fprintf(tw, "  Review timeouts\t%.0f\n", s.Get("hemingway_guard_review_timeouts_total"))
+	fmt.Fprintf(tw, "  Tap re-enables\t%.0f\n", reenables)
+	fmt.Fprintf(tw, "  IPC errors\t%.0f\n", ipcErrors)
+	fmt.Fprintf(tw, "  Focus changes\t%.0f\n", s.Sum("hemingway_guard_focus_transitions_total"))
+	tw.Flush()
+
+	var warnings []string
+	if reenables > 0 {
+		warnings = append(warnings, fmt.Sprintf("macOS disabled the keyboard tap %s; it was switched back on each time", times(reenables)))
+	}
+	if failed+timedOut > 0 {
+		warnings = append(warnings, fmt.Sprintf("%.0f of %.0f analyses failed or timed out; those messages went through unchecked", failed+timedOut, analyses))
+	}
+	if len(warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+}
+
+func times(n float64) string {
+	if n == 1 {
+		return "once"
+	}
+	return fmt.Sprintf("%.0f times", n)
+}
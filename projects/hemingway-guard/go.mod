@@ -1,3 +1,5 @@
 module github.com/lancekrogers/hemingway-guard
 
-go 1.23
+go 1.23.0
+
+require golang.org/x/text v0.28.0
@@ -0,0 +1,56 @@
+package accessibility
+
+// ref is an opaque handle to an accessibility object. On macOS it holds an
+// AXUIElementRef; other backends are free to use any non-zero value.
+type ref uintptr
+
+// backend performs the raw accessibility calls for an Element. Functions that
+// return a ref return it retained; the caller must release it.
+type backend interface {
+	retain(r ref)
+	release(r ref)
+
+	systemWide() ref
+	focusedApplication() ref
+	focusedElement(r ref) ref
+	elementAttribute(r ref, name string) ref
+	elementsAttribute(r ref, name string) []ref
+	equal(a, b ref) bool
+
+	stringAttribute(r ref, name string) (string, bool)
+	boolAttribute(r ref, name string) (bool, bool)
+	setStringAttribute(r ref, name, value string) error
+	frame(r ref) (Rect, bool)
+	rangeAttribute(r ref, name string) (Range, bool)
+	boundsForRange(r ref, rng Range) (Rect, bool)
+
+	pid(r ref) int
+	bundleID(pid int) string
+}
+
+// defaultBackend is used for elements created from the live system.
+// Platform files replace it during init.
+var defaultBackend backend = unsupportedBackend{}
+
+// unsupportedBackend is used on platforms without an Accessibility API.
+// Every lookup fails, so callers see ErrAccessibilityNotEnabled.
+type unsupportedBackend struct{}
+
+func (unsupportedBackend) retain(ref)                                 {}
+func (unsupportedBackend) release(ref)                                {}
+func (unsupportedBackend) systemWide() ref                            { return 0 }
+func (unsupportedBackend) focusedApplication() ref                    { return 0 }
+func (unsupportedBackend) focusedElement(ref) ref                     { return 0 }
+func (unsupportedBackend) elementAttribute(ref, string) ref           { return 0 }
+func (unsupportedBackend) elementsAttribute(ref, string) []ref        { return nil }
+func (unsupportedBackend) equal(a, b ref) bool                        { return a == b }
+func (unsupportedBackend) stringAttribute(ref, string) (string, bool) { return "", false }
+func (unsupportedBackend) boolAttribute(ref, string) (bool, bool)     { return false, false }
+func (unsupportedBackend) setStringAttribute(ref, string, string) error {
+	return ErrAccessibilityNotEnabled
+}
+func (unsupportedBackend) frame(ref) (Rect, bool)                   { return Rect{}, false }
+func (unsupportedBackend) rangeAttribute(ref, string) (Range, bool) { return Range{}, false }
+func (unsupportedBackend) boundsForRange(ref, Range) (Rect, bool)   { return Rect{}, false }
+func (unsupportedBackend) pid(ref) int                              { return -1 }
+func (unsupportedBackend) bundleID(int) string                      { return "" }
@@ -0,0 +1,154 @@
+package accessibility
+
+import "github.com/lancekrogers/hemingway-guard/pkg/apps"
+
+// FieldKind classifies a focused element for interception.
+type FieldKind int
+
+const (
+	// FieldOther is not a text input.
+	FieldOther FieldKind = iota
+	// FieldSecure is a password field. Its value is never read.
+	FieldSecure
+	// FieldExcluded is a text input that isn't a message composer, such as a
+	// search bar or a rename dialog.
+	FieldExcluded
+	// FieldComposer is a message composer.
+	FieldComposer
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case FieldSecure:
+		return "secure"
+	case FieldExcluded:
+		return "excluded"
+	case FieldComposer:
+		return "composer"
+	default:
+		return "other"
+	}
+}
+
+// defaultTextFieldRoles applies when a target lists no roles of its own.
+var defaultTextFieldRoles = []string{"AXTextArea", "AXTextField"}
+
+// Rules that hold in every app.
+var (
+	excludedSubroles      = []string{"AXSearchField"}
+	excludedPlaceholders  = []string{"Search"}
+	excludedAncestorRoles = []string{"AXSheet", "AXPopover"}
+	excludedAncestorSubs  = []string{"AXDialog", "AXSystemDialog"}
+)
+
+// composerAncestorDepth bounds how far up ClassifyElement looks.
+const composerAncestorDepth = 12
+
+// IsSecure reports whether the element is a password field.
+func (e *Element) IsSecure() bool {
+	return isSecure(e.Role(), e.Subrole())
+}
+
+func isSecure(role, subrole string) bool {
+	return subrole == "AXSecureTextField" || role == "AXSecureTextField"
+}
+
+// ClassifyField decides whether a snapshot node is a message composer in
+// target. The node's ancestors must be linked, as they are in a Capture or
+// a loaded Snapshot.
+func ClassifyField(n *Node, target *apps.TargetApp) FieldKind {
+	if isSecure(n.Role, n.Subrole) {
+		return FieldSecure
+	}
+
+	roles := defaultTextFieldRoles
+	if target != nil && len(target.TextFieldRoles) > 0 {
+		roles = target.TextFieldRoles
+	}
+	if !contains(roles, n.Role) {
+		return FieldOther
+	}
+
+	var rules apps.ComposerRules
+	if target != nil {
+		rules = target.ComposerRules
+	}
+
+	switch {
+	case contains(excludedSubroles, n.Subrole):
+		return FieldExcluded
+	case apps.MatchesPrefix(n.Placeholder, excludedPlaceholders),
+		apps.MatchesPrefix(n.Placeholder, rules.ExcludedPlaceholders):
+		return FieldExcluded
+	case apps.MatchesPrefix(n.Description, excludedPlaceholders),
+		apps.MatchesPrefix(n.Description, rules.ExcludedPlaceholders):
+		return FieldExcluded
+	case apps.MatchesPrefix(n.Identifier, rules.ExcludedIdentifiers):
+		return FieldExcluded
+	}
+
+	inExcludedContainer := n.HasAncestor(func(a *Node) bool {
+		return contains(excludedAncestorRoles, a.Role) ||
+			contains(excludedAncestorSubs, a.Subrole) ||
+			contains(rules.ExcludedAncestorRoles, a.Role)
+	})
+	if inExcludedContainer {
+		return FieldExcluded
+	}
+
+	return FieldComposer
+}
+
+// ClassifyElement classifies a live element. It reads only the attributes
+// the rules need, walking up to the window for ancestor checks, and never
+// reads the value of the field.
+func ClassifyElement(e *Element, target *apps.TargetApp) FieldKind {
+	n := describe(e)
+	if isSecure(n.Role, n.Subrole) {
+		return FieldSecure
+	}
+
+	// Link a chain of ancestors so ClassifyField can inspect them.
+	child := n
+	parent := e.Parent()
+	for depth := 0; parent != nil && depth < composerAncestorDepth; depth++ {
+		p := &Node{Role: parent.Role(), Subrole: parent.Subrole()}
+		child.parent = p
+		child = p
+
+		next := parent.Parent()
+		parent.Release()
+		parent = next
+		if p.Role == "AXWindow" {
+			break
+		}
+	}
+	if parent != nil {
+		parent.Release()
+	}
+
+	return ClassifyField(n, target)
+}
+
+// describe copies the classification attributes of a single element.
+func describe(e *Element) *Node {
+	return &Node{
+		Role:        e.Role(),
+		Subrole:     e.Subrole(),
+		Description: e.Description(),
+		Identifier:  e.Identifier(),
+		Placeholder: e.Placeholder(),
+	}
+}
+
+func contains(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
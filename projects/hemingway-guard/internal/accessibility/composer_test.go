@@ -0,0 +1,138 @@
+package accessibility
+
+import (
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
+)
+
+// label names a text field in a fixture by its most specific attribute.
+func label(n *Node) string {
+	for _, s := range []string{n.Identifier, n.Placeholder, n.Description} {
+		if s != "" {
+			return s
+		}
+	}
+	return n.Role
+}
+
+// textFields returns every node in the fixture the classifier might see.
+func textFields(window *Node) []*Node {
+	var fields []*Node
+	window.Walk(func(n *Node) bool {
+		if contains(defaultTextFieldRoles, n.Role) || isSecure(n.Role, n.Subrole) {
+			fields = append(fields, n)
+		}
+		return true
+	})
+	return fields
+}
+
+func TestClassifyFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want map[string]FieldKind
+	}{
+		{"slack_channel.json", map[string]FieldKind{
+			"search":           FieldExcluded,
+			"Message #general": FieldComposer,
+			"channel-name":     FieldExcluded,
+		}},
+		{"slack_thread.json", map[string]FieldKind{
+			"search":           FieldExcluded,
+			"Message #general": FieldComposer,
+			"Reply…":           FieldComposer,
+		}},
+		{"discord_channel.json", map[string]FieldKind{
+			"Find or start a conversation": FieldExcluded,
+			"search":                       FieldExcluded,
+			"Message #general":             FieldComposer,
+			"channel-name":                 FieldExcluded,
+		}},
+		{"messages_chat.json", map[string]FieldKind{
+			"To:":      FieldExcluded,
+			"Search":   FieldExcluded,
+			"iMessage": FieldComposer,
+			"Password": FieldSecure,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			checkNoLeaks(t)
+			b := newCountingBackend(loadFixture(t, tt.file))
+			snap := b.Snapshot()
+			target := apps.FindTarget(snap.BundleID)
+			if target == nil {
+				t.Fatalf("no target for %s", snap.BundleID)
+			}
+
+			fields := textFields(snap.Window)
+			if len(fields) != len(tt.want) {
+				t.Errorf("fixture has %d text fields, want %d", len(fields), len(tt.want))
+			}
+			for _, n := range fields {
+				name := label(n)
+				want, ok := tt.want[name]
+				if !ok {
+					t.Errorf("unexpected field %q", name)
+					continue
+				}
+				if got := ClassifyField(n, target); got != want {
+					t.Errorf("ClassifyField(%q) = %v, want %v", name, got, want)
+				}
+
+				elem := ownElement(b, b.refs[n])
+				if got := ClassifyElement(elem, target); got != want {
+					t.Errorf("ClassifyElement(%q) = %v, want %v", name, got, want)
+				}
+				elem.Release()
+			}
+			if b.valueReads != 0 {
+				t.Errorf("ClassifyElement read %d values", b.valueReads)
+			}
+		})
+	}
+}
+
+// Without a target only the global rules apply, so app-specific fields
+// such as Discord's conversation finder look like composers.
+func TestClassifyWithoutTarget(t *testing.T) {
+	snap := loadFixture(t, "discord_channel.json").Snapshot()
+	want := map[string]FieldKind{
+		"Find or start a conversation": FieldComposer,
+		"search":                       FieldExcluded,
+		"Message #general":             FieldComposer,
+		"channel-name":                 FieldComposer,
+	}
+	for _, n := range textFields(snap.Window) {
+		if got := ClassifyField(n, nil); got != want[label(n)] {
+			t.Errorf("ClassifyField(%q, nil) = %v, want %v", label(n), got, want[label(n)])
+		}
+	}
+}
+
+func TestClassifyField(t *testing.T) {
+	slack := apps.FindTarget("com.tinyspeck.slackmacgap")
+	tests := []struct {
+		name string
+		node *Node
+		want FieldKind
+	}{
+		{"button", &Node{Role: "AXButton", Title: "Send"}, FieldOther},
+		{"secure role", &Node{Role: "AXSecureTextField"}, FieldSecure},
+		{"search subrole", &Node{Role: "AXTextField", Subrole: "AXSearchField"}, FieldExcluded},
+		{"search described", &Node{Role: "AXTextField", Description: "Search messages"}, FieldExcluded},
+		{"slack topic", &Node{Role: "AXTextArea", Placeholder: "Add a topic"}, FieldExcluded},
+		{"slack status", &Node{Role: "AXTextField", Identifier: "status-input"}, FieldExcluded},
+		{"popover", &Node{Role: "AXTextArea", parent: &Node{Role: "AXPopover"}}, FieldExcluded},
+		{"dialog", &Node{Role: "AXTextArea", parent: &Node{Role: "AXWindow", Subrole: "AXDialog"}}, FieldExcluded},
+		{"composer", &Node{Role: "AXTextArea", Description: "Message #random"}, FieldComposer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyField(tt.node, slack); got != tt.want {
+				t.Errorf("ClassifyField = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package accessibility
+
+import (
+	"strings"
+
+	"github.com/lancekrogers/hemingway-guard/internal/textutil"
+)
+
+// Conversation describes where a message is being written: the conversation
+// it belongs to and what was said just before it.
+type Conversation struct {
+	Title          string
+	ChannelType    string   // "DM", "group" or "channel"; empty when the app doesn't say
+	RecentMessages []string // oldest first
+	InThread       bool
+}
+
+// ContextStrategy extracts conversation details for one app from a snapshot
+// of the window containing the composer.
+type ContextStrategy func(window, composer *Node, maxMessages int) Conversation
+
+// maxMessageChars caps each recent message so a long paste above the
+// composer doesn't dominate the analysis prompt.
+const maxMessageChars = 280
+
+var contextStrategies = map[string]ContextStrategy{
+	"com.apple.MobileSMS":       messagesContext,
+	"com.tinyspeck.slackmacgap": slackContext,
+	"com.hnc.Discord":           discordContext,
+}
+
+// ExtractConversation runs the strategy for bundleID over a window snapshot.
+// The composer is the snapshot's focused node.
+func ExtractConversation(bundleID string, window *Node, maxMessages int) Conversation {
+	if window == nil {
+		return Conversation{}
+	}
+	composer := window.FindFocused()
+	if composer == nil {
+		return Conversation{Title: strings.TrimSpace(window.Title)}
+	}
+
+	strategy, ok := contextStrategies[bundleID]
+	if !ok {
+		strategy = genericContext
+	}
+	return strategy(window, composer, maxMessages)
+}
+
+// CaptureConversation reads the conversation context around a live
+// composer. It runs while Enter is held, so instead of the whole window it
+// reads only the composer's ancestors and what comes just before it: the
+// last maxMessages rows of a list, and otherwise the nearest elements, up
+// to contextNodeBudget in total.
+func CaptureConversation(composer *Element, maxMessages int) (Conversation, error) {
+	window := captureContext(composer, maxMessages)
+	if window == nil {
+		return Conversation{}, ErrElementNotFound
+	}
+	return ExtractConversation(composer.BundleID(), window, maxMessages), nil
+}
+
+// Limits for captureContext. Each element read costs several AX calls.
+const (
+	contextNodeBudget    = 200 // elements read beside the composer's ancestors
+	contextAncestorDepth = 40  // ancestors walked looking for the window
+	contextSiblingDepth  = 8   // levels read below an element above the composer
+	contextRowDepth      = 4   // levels read below a message list row
+)
+
+// captureContext copies the composer, its ancestors up to the window, and
+// the elements that precede it at each level. Elements after the composer
+// are skipped, as are all but the last maxMessages rows of any list. The
+// result is rooted at the window, with the composer marked Focused.
+func captureContext(composer *Element, maxMessages int) *Node {
+	elem := composer.Retain()
+	if elem == nil {
+		return nil
+	}
+	node := contextNode(elem)
+	node.Focused = true
+	budget := contextNodeBudget
+
+	for depth := 0; depth < contextAncestorDepth && node.Role != "AXWindow"; depth++ {
+		parent := elem.Parent()
+		if parent == nil {
+			break
+		}
+		p := contextNode(parent)
+		p.Children = append(precedingSiblings(parent, elem, maxMessages, &budget), node)
+		elem.Release()
+		elem, node = parent, p
+	}
+	elem.Release()
+
+	node.link(nil)
+	return node
+}
+
+// precedingSiblings copies the children of parent that come before child,
+// reading the nearest ones first so the budget goes to what is closest to
+// the composer.
+func precedingSiblings(parent, child *Element, maxMessages int, budget *int) []*Node {
+	children := parent.Children()
+	defer releaseAll(children)
+
+	before := -1
+	for i, c := range children {
+		if c.Equal(child) {
+			before = i
+			break
+		}
+	}
+	if before < 0 {
+		return nil
+	}
+	return captureNearest(children[:before], maxMessages, contextSiblingDepth, budget)
+}
+
+// captureNearest copies elems, last first, and returns them in document order.
+func captureNearest(elems []*Element, maxMessages, depth int, budget *int) []*Node {
+	var nodes []*Node
+	for i := len(elems) - 1; i >= 0 && *budget > 0; i-- {
+		nodes = append(nodes, captureContextTree(elems[i], maxMessages, depth, budget))
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return nodes
+}
+
+// captureContextTree copies the subtree under e within the budget. Lists
+// keep only their last maxMessages rows.
+func captureContextTree(e *Element, maxMessages, depth int, budget *int) *Node {
+	*budget--
+	node := contextNode(e)
+	if depth <= 0 || *budget <= 0 {
+		return node
+	}
+
+	children := e.Children()
+	defer releaseAll(children)
+	if node.Role == "AXList" {
+		if len(children) > maxMessages {
+			children = children[len(children)-maxMessages:]
+		}
+		depth = contextRowDepth + 1
+	}
+	node.Children = captureNearest(children, maxMessages, depth-1, budget)
+	return node
+}
+
+// contextNode copies the attributes conversation strategies read. Values
+// are only read from text elements.
+func contextNode(e *Element) *Node {
+	role, subrole := e.Role(), e.Subrole()
+	node := &Node{
+		Role:        role,
+		Subrole:     subrole,
+		Title:       e.Title(),
+		Description: e.Description(),
+		Identifier:  e.Identifier(),
+		Placeholder: e.Placeholder(),
+	}
+	switch role {
+	case "AXStaticText", "AXTextArea", "AXTextField":
+		if !isSecure(role, subrole) {
+			node.Value = e.stringAttribute("AXValue")
+		}
+	}
+	return node
+}
+
+func releaseAll(elems []*Element) {
+	for _, e := range elems {
+		e.Release()
+	}
+}
+
+func genericContext(window, composer *Node, maxMessages int) Conversation {
+	return Conversation{
+		Title:          strings.TrimSpace(window.Title),
+		RecentMessages: messagesAbove(composer, maxMessages),
+	}
+}
+
+// Slack window titles look like "general (Channel) - Acme - Slack" or
+// "Jane Appleseed (DM) - Acme - Slack". Thread composers are labelled
+// "Reply…".
+func slackContext(window, composer *Node, maxMessages int) Conversation {
+	title := strings.TrimSuffix(strings.TrimSpace(window.Title), " - Slack")
+	if i := strings.LastIndex(title, " - "); i >= 0 {
+		title = title[:i]
+	}
+	return Conversation{
+		Title:          title,
+		ChannelType:    slackChannelType(title),
+		RecentMessages: messagesAbove(composer, maxMessages),
+		InThread:       isThreadComposer(composer, "reply"),
+	}
+}
+
+// slackChannelTypes maps the kind Slack puts after a conversation's name.
+var slackChannelTypes = map[string]string{
+	"channel":         "channel",
+	"private channel": "channel",
+	"dm":              "DM",
+	"group dm":        "group",
+}
+
+func slackChannelType(title string) string {
+	i := strings.LastIndex(title, " (")
+	if i < 0 || !strings.HasSuffix(title, ")") {
+		return ""
+	}
+	return slackChannelTypes[strings.ToLower(title[i+2:len(title)-1])]
+}
+
+// Discord window titles look like "#general | Server - Discord" for
+// channels and "@jane - Discord" for direct messages.
+func discordContext(window, composer *Node, maxMessages int) Conversation {
+	title := strings.TrimSuffix(strings.TrimSpace(window.Title), " - Discord")
+	var channelType string
+	switch {
+	case strings.HasPrefix(title, "#"):
+		channelType = "channel"
+	case strings.HasPrefix(title, "@"):
+		channelType = "DM"
+	}
+	return Conversation{
+		Title:          title,
+		ChannelType:    channelType,
+		RecentMessages: messagesAbove(composer, maxMessages),
+		InThread:       isThreadComposer(composer, "thread"),
+	}
+}
+
+// Messages shows the recipient in the window title; inline replies use a
+// composer labelled "Reply".
+func messagesContext(window, composer *Node, maxMessages int) Conversation {
+	return Conversation{
+		Title:          strings.TrimSpace(window.Title),
+		RecentMessages: messagesAbove(composer, maxMessages),
+		InThread:       isThreadComposer(composer, "reply"),
+	}
+}
+
+// isThreadComposer reports whether the composer or a nearby container is
+// labelled with marker.
+func isThreadComposer(composer *Node, marker string) bool {
+	labelled := func(n *Node) bool {
+		for _, s := range []string{n.Placeholder, n.Description, n.Title, n.Identifier} {
+			if strings.Contains(strings.ToLower(s), marker) {
+				return true
+			}
+		}
+		return false
+	}
+	if labelled(composer) {
+		return true
+	}
+	return composer.HasAncestor(func(n *Node) bool {
+		return n.Role != "AXWindow" && labelled(n)
+	})
+}
+
+// messagesAbove returns up to max messages that precede the composer in
+// document order. It searches the composer's nearest enclosing container
+// first, so a thread pane wins over the main channel beside it.
+func messagesAbove(composer *Node, max int) []string {
+	if max <= 0 {
+		return nil
+	}
+
+	for scope := composer.parent; scope != nil; scope = scope.parent {
+		messages := precedingMessages(scope, composer)
+		if len(messages) == 0 {
+			continue
+		}
+		if len(messages) > max {
+			messages = messages[len(messages)-max:]
+		}
+		return messages
+	}
+	return nil
+}
+
+// precedingMessages collects message text from scope up to the composer.
+// Rows of the last list above the composer are preferred; apps without
+// lists fall back to individual static text elements.
+func precedingMessages(scope, composer *Node) []string {
+	var lastList *Node
+	var texts []string
+	reached := false
+
+	scope.Walk(func(n *Node) bool {
+		if reached {
+			return false
+		}
+		if n == composer {
+			reached = true
+			return false
+		}
+		switch n.Role {
+		case "AXList":
+			lastList = n
+		case "AXStaticText":
+			if text := n.Text(); text != "" {
+				texts = append(texts, clipMessage(text))
+			}
+		}
+		return true
+	})
+
+	if lastList != nil {
+		var rows []string
+		for _, row := range lastList.Children {
+			if text := row.JoinedText(); text != "" {
+				rows = append(rows, clipMessage(text))
+			}
+		}
+		if len(rows) > 0 {
+			return rows
+		}
+	}
+	return texts
+}
+
+func clipMessage(s string) string {
+	return textutil.Truncate(s, maxMessageChars)
+}
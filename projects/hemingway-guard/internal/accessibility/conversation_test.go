@@ -0,0 +1,201 @@
+package accessibility
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExtractConversation(t *testing.T) {
+	tests := []struct {
+		file string
+		want Conversation
+	}{
+		{"slack_channel.json", Conversation{
+			Title:          "general (Channel)",
+			ChannelType:    "channel",
+			RecentMessages: []string{"Ben Ode Thanks! Anything I should check?", "Ana Lima Only the billing dashboard."},
+		}},
+		{"slack_thread.json", Conversation{
+			Title:          "general (Channel)",
+			ChannelType:    "channel",
+			RecentMessages: []string{"Ana Lima Release notes are in the doc.", "Chen Wu I can proofread them today."},
+			InThread:       true,
+		}},
+		{"discord_channel.json", Conversation{
+			Title:          "#general | Acme",
+			ChannelType:    "channel",
+			RecentMessages: []string{"tobias maybe after 9", "mira works for me"},
+		}},
+		{"messages_chat.json", Conversation{
+			Title:          "Jane Appleseed",
+			RecentMessages: []string{"Yes! 7pm at the usual place", "Perfect, see you there"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			snap := loadFixture(t, tt.file).Snapshot()
+			got := ExtractConversation(snap.BundleID, snap.Window, 2)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractConversation = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationChannelType(t *testing.T) {
+	tests := []struct {
+		bundleID, title, want string
+	}{
+		{"com.tinyspeck.slackmacgap", "general (Channel) - Acme - Slack", "channel"},
+		{"com.tinyspeck.slackmacgap", "launch-war-room (Private Channel) - Acme - Slack", "channel"},
+		{"com.tinyspeck.slackmacgap", "Jane Appleseed (DM) - Acme - Slack", "DM"},
+		{"com.tinyspeck.slackmacgap", "Jane, Ben (Group DM) - Acme - Slack", "group"},
+		{"com.tinyspeck.slackmacgap", "Threads - Acme - Slack", ""},
+		{"com.tinyspeck.slackmacgap", "Launch (2026) (Huddle) - Acme - Slack", ""},
+		{"com.hnc.Discord", "#general | Acme - Discord", "channel"},
+		{"com.hnc.Discord", "@mira - Discord", "DM"},
+		{"com.hnc.Discord", "Friends - Discord", ""},
+		{"com.apple.MobileSMS", "Jane Appleseed", ""},
+	}
+	for _, tt := range tests {
+		composer := &Node{Role: "AXTextArea", Focused: true}
+		window := &Node{Role: "AXWindow", Title: tt.title, Children: []*Node{composer}}
+		window.link(nil)
+		if got := ExtractConversation(tt.bundleID, window, 5).ChannelType; got != tt.want {
+			t.Errorf("%s %q: ChannelType = %q, want %q", tt.bundleID, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestExtractConversationWithoutComposer(t *testing.T) {
+	window := &Node{Role: "AXWindow", Title: " Jane Appleseed "}
+	got := ExtractConversation("com.apple.MobileSMS", window, 5)
+	if want := (Conversation{Title: "Jane Appleseed"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractConversation = %+v, want %+v", got, want)
+	}
+}
+
+// The bounded live capture must find the same context as a full snapshot.
+func TestCaptureConversationMatchesSnapshot(t *testing.T) {
+	for _, tt := range fixtures {
+		for _, max := range []int{1, 2, 5} {
+			t.Run(fmt.Sprintf("%s/%d", tt.file, max), func(t *testing.T) {
+				checkNoLeaks(t)
+				replay := loadFixture(t, tt.file)
+				focused := replay.Focused()
+				defer focused.Release()
+
+				got, err := CaptureConversation(focused, max)
+				if err != nil {
+					t.Fatalf("CaptureConversation: %v", err)
+				}
+				snap := replay.Snapshot()
+				if want := ExtractConversation(snap.BundleID, snap.Window, max); !reflect.DeepEqual(got, want) {
+					t.Errorf("CaptureConversation = %+v\nsnapshot has %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestCaptureContextSkipsFollowingElements(t *testing.T) {
+	checkNoLeaks(t)
+	replay := loadFixture(t, "slack_channel.json")
+	focused := replay.Focused()
+	defer focused.Release()
+
+	window := captureContext(focused, 5)
+	if window.Role != "AXWindow" {
+		t.Fatalf("root role = %q, want AXWindow", window.Role)
+	}
+	composer := window.FindFocused()
+	if composer == nil || composer.Description != "Message #general" {
+		t.Fatalf("focused node = %+v", composer)
+	}
+	window.Walk(func(n *Node) bool {
+		if n.Role == "AXSheet" || n.Identifier == "channel-name" {
+			t.Errorf("captured %s %q after the composer", n.Role, n.Identifier)
+		}
+		if n.Frame != nil || n.SelectedRange != nil {
+			t.Errorf("captured geometry of %s", n.Role)
+		}
+		return true
+	})
+}
+
+// countingBackend records which elements have attributes read.
+type countingBackend struct {
+	*Replay
+	read       map[ref]bool
+	valueReads int
+}
+
+func newCountingBackend(r *Replay) *countingBackend {
+	return &countingBackend{Replay: r, read: make(map[ref]bool)}
+}
+
+func (b *countingBackend) stringAttribute(h ref, name string) (string, bool) {
+	b.read[h] = true
+	if name == "AXValue" {
+		b.valueReads++
+	}
+	return b.Replay.stringAttribute(h, name)
+}
+
+// bigWindow builds a Slack-like window with a long channel history, a large
+// sidebar before the channel and a large pane after it.
+func bigWindow(rows int) *Snapshot {
+	sidebar := &Node{Role: "AXGroup", Description: "Sidebar"}
+	for i := 0; i < 2000; i++ {
+		sidebar.Children = append(sidebar.Children, &Node{Role: "AXStaticText", Value: fmt.Sprintf("channel-%d", i)})
+	}
+	history := &Node{Role: "AXList", Description: "Messages"}
+	for i := 0; i < rows; i++ {
+		history.Children = append(history.Children, &Node{Role: "AXGroup", Children: []*Node{
+			{Role: "AXStaticText", Value: "someone"},
+			{Role: "AXGroup", Children: []*Node{{Role: "AXStaticText", Value: fmt.Sprintf("message %d", i)}}},
+		}})
+	}
+	composer := &Node{Role: "AXTextArea", Description: "Message #general", Editable: true, Focused: true, Value: "hi"}
+	channel := &Node{Role: "AXGroup", Description: "Channel", Children: []*Node{
+		{Role: "AXStaticText", Value: "#general"},
+		history,
+		{Role: "AXGroup", Children: []*Node{composer}},
+	}}
+	after := &Node{Role: "AXGroup", Description: "Members"}
+	for i := 0; i < 1000; i++ {
+		after.Children = append(after.Children, &Node{Role: "AXStaticText", Value: fmt.Sprintf("member-%d", i)})
+	}
+
+	window := &Node{Role: "AXWindow", Title: "general (Channel) - Acme - Slack", Children: []*Node{sidebar, channel, after}}
+	window.link(nil)
+	return &Snapshot{Version: SnapshotVersion, BundleID: "com.tinyspeck.slackmacgap", Window: window}
+}
+
+func TestCaptureContextIsBounded(t *testing.T) {
+	checkNoLeaks(t)
+	snap := bigWindow(5000)
+	b := newCountingBackend(NewReplay(snap))
+	focused := ownElement(b, b.focusedElement(replaySystemWide))
+	defer focused.Release()
+
+	conv, err := CaptureConversation(focused, 3)
+	if err != nil {
+		t.Fatalf("CaptureConversation: %v", err)
+	}
+	want := []string{"someone message 4997", "someone message 4998", "someone message 4999"}
+	if !reflect.DeepEqual(conv.RecentMessages, want) {
+		t.Errorf("RecentMessages = %q, want %q", conv.RecentMessages, want)
+	}
+
+	// The composer and its three ancestors are always read.
+	if limit := contextNodeBudget + 4; len(b.read) > limit {
+		t.Errorf("read %d elements, want at most %d", len(b.read), limit)
+	}
+	for h := range b.read {
+		if n := b.node(h); n != nil && n.Value == "member-0" {
+			t.Error("read an element after the composer")
+		}
+	}
+}
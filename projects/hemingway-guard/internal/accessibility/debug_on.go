@@ -0,0 +1,7 @@
+//go:build debug
+
+package accessibility
+
+// debugRefs enables use-after-release and over-release panics.
+// Build with -tags debug to turn it on.
+const debugRefs = true
@@ -1,226 +1,357 @@
 // Package accessibility provides wrappers for macOS Accessibility APIs.
 package accessibility
 
-/*
-#cgo CFLAGS: -x objective-c
-#cgo LDFLAGS: -framework ApplicationServices -framework AppKit
-
-#include <ApplicationServices/ApplicationServices.h>
-#import <AppKit/AppKit.h>
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
 
-// Get the system-wide accessibility element
-AXUIElementRef createSystemWideElement() {
-    return AXUIElementCreateSystemWide();
-}
-
-// Get the focused element from a given element
-AXUIElementRef getFocusedElement(AXUIElementRef element) {
-    AXUIElementRef focusedElement = NULL;
-    AXError error = AXUIElementCopyAttributeValue(
-        element,
-        kAXFocusedUIElementAttribute,
-        (CFTypeRef *)&focusedElement
-    );
-    if (error != kAXErrorSuccess) {
-        return NULL;
-    }
-    return focusedElement;
+// Element wraps an AXUIElementRef.
+//
+// An Element is either owned or borrowed. Owned elements hold a reference
+// count and free the underlying ref when the last holder calls Release.
+// Borrowed elements wrap a ref owned by someone else (for example an observer
+// callback) and are only valid for the duration of that call; use Retain to
+// keep one longer.
+type Element struct {
+	mu       sync.Mutex
+	b        backend
+	ref      ref
+	owned    bool
+	refs     int
+	released bool
+
+	// releasedAt records where the element was released in debug builds.
+	releasedAt string
 }
 
-// Get string attribute from an element
-char* getStringAttribute(AXUIElementRef element, CFStringRef attribute) {
-    CFTypeRef value = NULL;
-    AXError error = AXUIElementCopyAttributeValue(element, attribute, &value);
-    if (error != kAXErrorSuccess || value == NULL) {
-        return NULL;
-    }
+// ErrAccessibilityNotEnabled indicates accessibility permissions are not granted.
+var ErrAccessibilityNotEnabled = errors.New("accessibility permissions not enabled")
+
+// ErrElementNotFound indicates the requested element was not found.
+var ErrElementNotFound = errors.New("element not found")
 
-    if (CFGetTypeID(value) != CFStringGetTypeID()) {
-        CFRelease(value);
-        return NULL;
-    }
+// ErrElementReleased indicates an Element was used after its final Release.
+var ErrElementReleased = errors.New("element already released")
 
-    CFStringRef stringValue = (CFStringRef)value;
-    CFIndex length = CFStringGetLength(stringValue);
-    CFIndex maxSize = CFStringGetMaximumSizeForEncoding(length, kCFStringEncodingUTF8) + 1;
-    char *buffer = malloc(maxSize);
+// RefStats reports Element lifetime counters for diagnostics.
+type RefStats struct {
+	Created  int64 // owned elements created
+	Released int64 // owned elements fully released
+	Live     int64 // owned elements not yet released; a steady climb means a leak
+}
 
-    if (!CFStringGetCString(stringValue, buffer, maxSize, kCFStringEncodingUTF8)) {
-        free(buffer);
-        CFRelease(value);
-        return NULL;
-    }
+var (
+	elementsCreated  atomic.Int64
+	elementsReleased atomic.Int64
+)
 
-    CFRelease(value);
-    return buffer;
+// Stats returns the current Element lifetime counters.
+func Stats() RefStats {
+	created := elementsCreated.Load()
+	released := elementsReleased.Load()
+	return RefStats{Created: created, Released: released, Live: created - released}
 }
 
-// Get the role of an element
-char* getRole(AXUIElementRef element) {
-    return getStringAttribute(element, kAXRoleAttribute);
+// LiveElements returns the number of owned elements that have not been released.
+func LiveElements() int64 {
+	return Stats().Live
 }
 
-// Get the value (text content) of an element
-char* getValue(AXUIElementRef element) {
-    return getStringAttribute(element, kAXValueAttribute);
+// ownElement wraps a ref the caller already holds a retain on.
+func ownElement(b backend, r ref) *Element {
+	if r == 0 {
+		return nil
+	}
+	elementsCreated.Add(1)
+	return &Element{b: b, ref: r, owned: true, refs: 1}
 }
 
-// Set the value of an element
-int setValue(AXUIElementRef element, const char* value) {
-    CFStringRef cfValue = CFStringCreateWithCString(NULL, value, kCFStringEncodingUTF8);
-    if (cfValue == NULL) {
-        return -1;
-    }
+// borrowElement wraps a ref owned by someone else.
+func borrowElement(b backend, r ref) *Element {
+	if r == 0 {
+		return nil
+	}
+	return &Element{b: b, ref: r}
+}
 
-    AXError error = AXUIElementSetAttributeValue(element, kAXValueAttribute, cfValue);
-    CFRelease(cfValue);
+// SystemWideElement returns the system-wide accessibility element.
+func SystemWideElement() *Element {
+	return ownElement(defaultBackend, defaultBackend.systemWide())
+}
 
-    return error == kAXErrorSuccess ? 0 : -1;
+// FocusedApplication returns the currently focused application element.
+func FocusedApplication() *Element {
+	return ownElement(defaultBackend, defaultBackend.focusedApplication())
 }
 
-// Get the PID of the process owning the element
-pid_t getPID(AXUIElementRef element) {
-    pid_t pid = 0;
-    AXError error = AXUIElementGetPid(element, &pid);
-    if (error != kAXErrorSuccess) {
-        return -1;
-    }
-    return pid;
+// live returns the element's ref, or false if it has been released.
+// Debug builds panic instead, pointing at the release site.
+func (e *Element) live() (ref, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.liveLocked()
 }
 
-// Get bundle identifier for a PID
-char* getBundleIDForPID(pid_t pid) {
-    NSRunningApplication *app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
-    if (app == nil || app.bundleIdentifier == nil) {
-        return NULL;
-    }
+// liveLocked is live for callers holding e.mu.
+func (e *Element) liveLocked() (ref, bool) {
+	if e.released {
+		if debugRefs {
+			panic(fmt.Sprintf("accessibility: use of released Element\nreleased at:\n%s", e.releasedAt))
+		}
+		return 0, false
+	}
+	return e.ref, true
+}
 
-    const char *bundleID = [app.bundleIdentifier UTF8String];
-    return strdup(bundleID);
+// IsOwned reports whether the element holds its own reference.
+func (e *Element) IsOwned() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.owned
 }
 
-// Check if the element is editable
-int isEditable(AXUIElementRef element) {
-    CFTypeRef value = NULL;
-    AXError error = AXUIElementCopyAttributeValue(element, CFSTR("AXEditable"), &value);
-    if (error != kAXErrorSuccess || value == NULL) {
-        // If we can't determine, assume not editable
-        return 0;
-    }
+// Retain returns an owned reference to the element. For an owned element it
+// bumps the reference count and returns e; for a borrowed element it retains
+// the underlying ref and returns a new owned Element. Every Retain must be
+// paired with a Release.
+func (e *Element) Retain() *Element {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-    int editable = 0;
-    if (CFGetTypeID(value) == CFBooleanGetTypeID()) {
-        editable = CFBooleanGetValue((CFBooleanRef)value) ? 1 : 0;
-    }
-
-    CFRelease(value);
-    return editable;
-}
-
-// Release an AXUIElement
-void releaseElement(AXUIElementRef element) {
-    if (element != NULL) {
-        CFRelease(element);
-    }
+	r, ok := e.liveLocked()
+	if !ok {
+		return nil
+	}
+	if e.owned {
+		e.refs++
+		return e
+	}
+	e.b.retain(r)
+	return ownElement(e.b, r)
 }
 
-// Free a C string
-void freeString(char* str) {
-    if (str != NULL) {
-        free(str);
-    }
+// Release drops one reference. The underlying AXUIElementRef is freed when the
+// last reference is released. Borrowed elements must not be released: in
+// release builds it does nothing, and debug builds panic.
+func (e *Element) Release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.owned {
+		if debugRefs {
+			panic("accessibility: Release of borrowed Element")
+		}
+		return
+	}
+	if e.released {
+		if debugRefs {
+			panic(fmt.Sprintf("accessibility: Element released twice\nfirst released at:\n%s", e.releasedAt))
+		}
+		return
+	}
+
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+
+	e.b.release(e.ref)
+	e.ref = 0
+	e.released = true
+	elementsReleased.Add(1)
+	if debugRefs {
+		e.releasedAt = callers()
+	}
 }
-*/
-import "C"
-import (
-	"errors"
-	"unsafe"
-)
 
-// Element wraps an AXUIElementRef.
-type Element struct {
-	ref C.AXUIElementRef
+// FocusedElement returns the currently focused UI element.
+// The caller owns the result and must Release it.
+func (e *Element) FocusedElement() (*Element, error) {
+	r, ok := e.live()
+	if !ok {
+		return nil, ErrElementReleased
+	}
+	focused := ownElement(e.b, e.b.focusedElement(r))
+	if focused == nil {
+		return nil, ErrElementNotFound
+	}
+	return focused, nil
 }
 
-// ErrAccessibilityNotEnabled indicates accessibility permissions are not granted.
-var ErrAccessibilityNotEnabled = errors.New("accessibility permissions not enabled")
+// Parent returns the element's parent. The caller owns the result.
+func (e *Element) Parent() *Element {
+	return e.elementAttribute("AXParent")
+}
 
-// ErrElementNotFound indicates the requested element was not found.
-var ErrElementNotFound = errors.New("element not found")
+// Window returns the window containing the element. The caller owns the result.
+func (e *Element) Window() *Element {
+	return e.elementAttribute("AXWindow")
+}
 
-// SystemWideElement returns the system-wide accessibility element.
-func SystemWideElement() *Element {
-	ref := C.createSystemWideElement()
-	if uintptr(ref) == 0 {
+// Children returns the element's children. The caller owns each result.
+func (e *Element) Children() []*Element {
+	r, ok := e.live()
+	if !ok {
 		return nil
 	}
-	return &Element{ref: ref}
+
+	refs := e.b.elementsAttribute(r, "AXChildren")
+	children := make([]*Element, 0, len(refs))
+	for _, child := range refs {
+		if elem := ownElement(e.b, child); elem != nil {
+			children = append(children, elem)
+		}
+	}
+	return children
 }
 
-// FocusedElement returns the currently focused UI element.
-func (e *Element) FocusedElement() (*Element, error) {
-	focused := C.getFocusedElement(e.ref)
-	if uintptr(focused) == 0 {
-		return nil, ErrElementNotFound
+// Equal reports whether e and other refer to the same UI element.
+func (e *Element) Equal(other *Element) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+	a, ok := e.live()
+	if !ok {
+		return false
+	}
+	b, ok := other.live()
+	if !ok {
+		return false
 	}
-	return &Element{ref: focused}, nil
+	return e.b.equal(a, b)
 }
 
 // Role returns the AX role of the element.
 func (e *Element) Role() string {
-	cStr := C.getRole(e.ref)
-	if cStr == nil {
-		return ""
-	}
-	defer C.freeString(cStr)
-	return C.GoString(cStr)
+	return e.stringAttribute("AXRole")
+}
+
+// Subrole returns the AX subrole of the element.
+func (e *Element) Subrole() string {
+	return e.stringAttribute("AXSubrole")
+}
+
+// Title returns the AX title of the element.
+func (e *Element) Title() string {
+	return e.stringAttribute("AXTitle")
+}
+
+// Description returns the AX description of the element.
+func (e *Element) Description() string {
+	return e.stringAttribute("AXDescription")
+}
+
+// Identifier returns the developer-assigned AX identifier of the element.
+func (e *Element) Identifier() string {
+	return e.stringAttribute("AXIdentifier")
+}
+
+// Placeholder returns the placeholder text shown in an empty text field.
+func (e *Element) Placeholder() string {
+	return e.stringAttribute("AXPlaceholderValue")
 }
 
-// Value returns the text value of the element.
+// Value returns the text value of the element. Password fields always
+// report an empty value.
 func (e *Element) Value() string {
-	cStr := C.getValue(e.ref)
-	if cStr == nil {
+	if e.IsSecure() {
 		return ""
 	}
-	defer C.freeString(cStr)
-	return C.GoString(cStr)
+	return e.stringAttribute("AXValue")
 }
 
 // SetValue sets the text value of the element.
 func (e *Element) SetValue(value string) error {
-	cStr := C.CString(value)
-	defer C.free(unsafe.Pointer(cStr))
-
-	result := C.setValue(e.ref, cStr)
-	if result != 0 {
-		return errors.New("failed to set value")
+	r, ok := e.live()
+	if !ok {
+		return ErrElementReleased
 	}
-	return nil
+	return e.b.setStringAttribute(r, "AXValue", value)
 }
 
 // PID returns the process ID of the application owning this element.
 func (e *Element) PID() int {
-	return int(C.getPID(e.ref))
+	r, ok := e.live()
+	if !ok {
+		return -1
+	}
+	return e.b.pid(r)
 }
 
 // BundleID returns the bundle identifier of the application owning this element.
 func (e *Element) BundleID() string {
-	pid := C.getPID(e.ref)
+	pid := e.PID()
 	if pid < 0 {
 		return ""
 	}
+	return e.b.bundleID(pid)
+}
+
+// Frame returns the element's position and size in AX screen coordinates.
+func (e *Element) Frame() (Rect, bool) {
+	r, ok := e.live()
+	if !ok {
+		return Rect{}, false
+	}
+	return e.b.frame(r)
+}
 
-	cStr := C.getBundleIDForPID(pid)
-	if cStr == nil {
-		return ""
+// SelectedTextRange returns the element's selection, or the caret position
+// as a zero-length range.
+func (e *Element) SelectedTextRange() (Range, bool) {
+	r, ok := e.live()
+	if !ok {
+		return Range{}, false
+	}
+	return e.b.rangeAttribute(r, "AXSelectedTextRange")
+}
+
+// BoundsForRange returns the screen bounds of a range of the element's text
+// in AX coordinates (AXBoundsForRange).
+func (e *Element) BoundsForRange(rng Range) (Rect, bool) {
+	r, ok := e.live()
+	if !ok {
+		return Rect{}, false
+	}
+	return e.b.boundsForRange(r, rng)
+}
+
+// CaretBounds returns the bounds of the selection or caret.
+func (e *Element) CaretBounds() (Rect, bool) {
+	rng, ok := e.SelectedTextRange()
+	if !ok {
+		return Rect{}, false
+	}
+	return e.BoundsForRange(rng)
+}
+
+// PopoverAnchor returns the AppKit point at which to show the approval
+// popover for this element: at the caret when the app reports its bounds,
+// otherwise at the top of the text field.
+func (e *Element) PopoverAnchor() (Point, bool) {
+	var caret, field *Rect
+	if r, ok := e.CaretBounds(); ok {
+		caret = &r
+	}
+	if r, ok := e.Frame(); ok {
+		field = &r
 	}
-	defer C.freeString(cStr)
-	return C.GoString(cStr)
+	return PopoverAnchor(caret, field, Screens())
 }
 
 // IsEditable returns whether the element is editable.
 func (e *Element) IsEditable() bool {
-	return C.isEditable(e.ref) == 1
+	r, ok := e.live()
+	if !ok {
+		return false
+	}
+	// If we can't determine, assume not editable
+	editable, _ := e.b.boolAttribute(r, "AXEditable")
+	return editable
 }
 
 // IsTextField returns whether the element is a text field or text area.
@@ -229,10 +360,36 @@ func (e *Element) IsTextField() bool {
 	return role == "AXTextField" || role == "AXTextArea"
 }
 
-// Release frees the underlying AXUIElementRef.
-func (e *Element) Release() {
-	if uintptr(e.ref) != 0 {
-		C.releaseElement(e.ref)
-		e.ref = C.AXUIElementRef(uintptr(0))
+func (e *Element) elementAttribute(name string) *Element {
+	r, ok := e.live()
+	if !ok {
+		return nil
+	}
+	return ownElement(e.b, e.b.elementAttribute(r, name))
+}
+
+func (e *Element) stringAttribute(name string) string {
+	r, ok := e.live()
+	if !ok {
+		return ""
+	}
+	value, _ := e.b.stringAttribute(r, name)
+	return value
+}
+
+// callers formats the stack of the caller's caller for debug reports.
+func callers() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var out string
+	for {
+		frame, more := frames.Next()
+		out += fmt.Sprintf("  %s\n    %s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
 	}
+	return out
 }
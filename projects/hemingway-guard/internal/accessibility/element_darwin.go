@@ -0,0 +1,493 @@
+package accessibility
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework AppKit
+
+#include <ApplicationServices/ApplicationServices.h>
+#import <AppKit/AppKit.h>
+
+// Get the system-wide accessibility element
+AXUIElementRef createSystemWideElement() {
+    return AXUIElementCreateSystemWide();
+}
+
+// Get the focused application element (caller owns the result)
+AXUIElementRef getFocusedApplication() {
+    AXUIElementRef systemWide = AXUIElementCreateSystemWide();
+    AXUIElementRef focusedApp = NULL;
+    AXError error = AXUIElementCopyAttributeValue(
+        systemWide,
+        kAXFocusedApplicationAttribute,
+        (CFTypeRef *)&focusedApp
+    );
+    CFRelease(systemWide);
+
+    if (error != kAXErrorSuccess) {
+        return NULL;
+    }
+    return focusedApp;
+}
+
+// Get the focused element from a given element (caller owns the result)
+AXUIElementRef getFocusedElement(AXUIElementRef element) {
+    AXUIElementRef focusedElement = NULL;
+    AXError error = AXUIElementCopyAttributeValue(
+        element,
+        kAXFocusedUIElementAttribute,
+        (CFTypeRef *)&focusedElement
+    );
+    if (error != kAXErrorSuccess) {
+        return NULL;
+    }
+    return focusedElement;
+}
+
+// Create a CFString for an attribute name (caller releases)
+CFStringRef createAttributeName(const char* name) {
+    return CFStringCreateWithCString(NULL, name, kCFStringEncodingUTF8);
+}
+
+// Get an element-valued attribute (caller owns the result)
+AXUIElementRef getElementAttribute(AXUIElementRef element, const char* name) {
+    CFStringRef attribute = createAttributeName(name);
+    if (attribute == NULL) {
+        return NULL;
+    }
+
+    CFTypeRef value = NULL;
+    AXError error = AXUIElementCopyAttributeValue(element, attribute, &value);
+    CFRelease(attribute);
+    if (error != kAXErrorSuccess || value == NULL) {
+        return NULL;
+    }
+
+    if (CFGetTypeID(value) != AXUIElementGetTypeID()) {
+        CFRelease(value);
+        return NULL;
+    }
+    return (AXUIElementRef)value;
+}
+
+// Get an array of elements from an attribute such as AXChildren.
+// Returns the count and stores a malloc'd array of retained refs in out;
+// the caller releases each ref and frees the array.
+int getElementsAttribute(AXUIElementRef element, const char* name, AXUIElementRef** out) {
+    *out = NULL;
+    CFStringRef attribute = createAttributeName(name);
+    if (attribute == NULL) {
+        return 0;
+    }
+
+    CFTypeRef value = NULL;
+    AXError error = AXUIElementCopyAttributeValue(element, attribute, &value);
+    CFRelease(attribute);
+    if (error != kAXErrorSuccess || value == NULL) {
+        return 0;
+    }
+
+    if (CFGetTypeID(value) != CFArrayGetTypeID()) {
+        CFRelease(value);
+        return 0;
+    }
+
+    CFArrayRef array = (CFArrayRef)value;
+    CFIndex count = CFArrayGetCount(array);
+    AXUIElementRef *refs = malloc(sizeof(AXUIElementRef) * (count > 0 ? count : 1));
+    int n = 0;
+    for (CFIndex i = 0; i < count; i++) {
+        CFTypeRef item = CFArrayGetValueAtIndex(array, i);
+        if (item != NULL && CFGetTypeID(item) == AXUIElementGetTypeID()) {
+            CFRetain(item);
+            refs[n++] = (AXUIElementRef)item;
+        }
+    }
+
+    CFRelease(value);
+    *out = refs;
+    return n;
+}
+
+// Check whether two refs point at the same UI element
+int elementsEqual(AXUIElementRef a, AXUIElementRef b) {
+    if (a == NULL || b == NULL) {
+        return a == b;
+    }
+    return CFEqual(a, b) ? 1 : 0;
+}
+
+// Get string attribute from an element
+char* getStringAttribute(AXUIElementRef element, const char* name) {
+    CFStringRef attribute = createAttributeName(name);
+    if (attribute == NULL) {
+        return NULL;
+    }
+
+    CFTypeRef value = NULL;
+    AXError error = AXUIElementCopyAttributeValue(element, attribute, &value);
+    CFRelease(attribute);
+    if (error != kAXErrorSuccess || value == NULL) {
+        return NULL;
+    }
+
+    if (CFGetTypeID(value) != CFStringGetTypeID()) {
+        CFRelease(value);
+        return NULL;
+    }
+
+    CFStringRef stringValue = (CFStringRef)value;
+    CFIndex length = CFStringGetLength(stringValue);
+    CFIndex maxSize = CFStringGetMaximumSizeForEncoding(length, kCFStringEncodingUTF8) + 1;
+    char *buffer = malloc(maxSize);
+
+    if (!CFStringGetCString(stringValue, buffer, maxSize, kCFStringEncodingUTF8)) {
+        free(buffer);
+        CFRelease(value);
+        return NULL;
+    }
+
+    CFRelease(value);
+    return buffer;
+}
+
+// Get boolean attribute from an element.
+// Returns 1 or 0, or -1 if the attribute is missing or not a boolean.
+int getBoolAttribute(AXUIElementRef element, const char* name) {
+    CFStringRef attribute = createAttributeName(name);
+    if (attribute == NULL) {
+        return -1;
+    }
+
+    CFTypeRef value = NULL;
+    AXError error = AXUIElementCopyAttributeValue(element, attribute, &value);
+    CFRelease(attribute);
+    if (error != kAXErrorSuccess || value == NULL) {
+        return -1;
+    }
+
+    int result = -1;
+    if (CFGetTypeID(value) == CFBooleanGetTypeID()) {
+        result = CFBooleanGetValue((CFBooleanRef)value) ? 1 : 0;
+    }
+
+    CFRelease(value);
+    return result;
+}
+
+// Set a string attribute on an element
+int setStringAttribute(AXUIElementRef element, const char* name, const char* value) {
+    CFStringRef attribute = createAttributeName(name);
+    if (attribute == NULL) {
+        return -1;
+    }
+
+    CFStringRef cfValue = CFStringCreateWithCString(NULL, value, kCFStringEncodingUTF8);
+    if (cfValue == NULL) {
+        CFRelease(attribute);
+        return -1;
+    }
+
+    AXError error = AXUIElementSetAttributeValue(element, attribute, cfValue);
+    CFRelease(cfValue);
+    CFRelease(attribute);
+
+    return error == kAXErrorSuccess ? 0 : -1;
+}
+
+// Get the frame (AXPosition + AXSize) of an element. Returns 0 on success.
+int getFrame(AXUIElementRef element, CGRect *out) {
+    CFTypeRef position = NULL;
+    CFTypeRef size = NULL;
+
+    AXError error = AXUIElementCopyAttributeValue(element, kAXPositionAttribute, &position);
+    if (error != kAXErrorSuccess || position == NULL) {
+        return -1;
+    }
+    error = AXUIElementCopyAttributeValue(element, kAXSizeAttribute, &size);
+    if (error != kAXErrorSuccess || size == NULL) {
+        CFRelease(position);
+        return -1;
+    }
+
+    CGPoint origin;
+    CGSize extent;
+    int ok = AXValueGetValue((AXValueRef)position, kAXValueCGPointType, &origin) &&
+             AXValueGetValue((AXValueRef)size, kAXValueCGSizeType, &extent);
+    CFRelease(position);
+    CFRelease(size);
+    if (!ok) {
+        return -1;
+    }
+
+    out->origin = origin;
+    out->size = extent;
+    return 0;
+}
+
+// Get a CFRange attribute such as AXSelectedTextRange. Returns 0 on success.
+int getRangeAttribute(AXUIElementRef element, const char* name, CFRange *out) {
+    CFStringRef attribute = createAttributeName(name);
+    if (attribute == NULL) {
+        return -1;
+    }
+
+    CFTypeRef value = NULL;
+    AXError error = AXUIElementCopyAttributeValue(element, attribute, &value);
+    CFRelease(attribute);
+    if (error != kAXErrorSuccess || value == NULL) {
+        return -1;
+    }
+
+    int ok = CFGetTypeID(value) == AXValueGetTypeID() &&
+             AXValueGetValue((AXValueRef)value, kAXValueCFRangeType, out);
+    CFRelease(value);
+    return ok ? 0 : -1;
+}
+
+// Get the screen bounds of a text range (AXBoundsForRange). Returns 0 on success.
+int getBoundsForRange(AXUIElementRef element, CFRange range, CGRect *out) {
+    AXValueRef rangeValue = AXValueCreate(kAXValueCFRangeType, &range);
+    if (rangeValue == NULL) {
+        return -1;
+    }
+
+    CFTypeRef value = NULL;
+    AXError error = AXUIElementCopyParameterizedAttributeValue(
+        element,
+        kAXBoundsForRangeParameterizedAttribute,
+        rangeValue,
+        &value
+    );
+    CFRelease(rangeValue);
+    if (error != kAXErrorSuccess || value == NULL) {
+        return -1;
+    }
+
+    int ok = CFGetTypeID(value) == AXValueGetTypeID() &&
+             AXValueGetValue((AXValueRef)value, kAXValueCGRectType, out);
+    CFRelease(value);
+    return ok ? 0 : -1;
+}
+
+// Copy the frames of all screens into out (AppKit coordinates, primary first).
+// Returns the number of screens written.
+int getScreens(CGRect *frames, CGRect *visibleFrames, int max) {
+    NSArray<NSScreen *> *screens = [NSScreen screens];
+    int n = 0;
+    for (NSScreen *screen in screens) {
+        if (n >= max) {
+            break;
+        }
+        frames[n] = NSRectToCGRect(screen.frame);
+        visibleFrames[n] = NSRectToCGRect(screen.visibleFrame);
+        n++;
+    }
+    return n;
+}
+
+// Get the PID of the process owning the element
+pid_t getPID(AXUIElementRef element) {
+    pid_t pid = 0;
+    AXError error = AXUIElementGetPid(element, &pid);
+    if (error != kAXErrorSuccess) {
+        return -1;
+    }
+    return pid;
+}
+
+// Get bundle identifier for a PID
+char* getBundleIDForPID(pid_t pid) {
+    NSRunningApplication *app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
+    if (app == nil || app.bundleIdentifier == nil) {
+        return NULL;
+    }
+
+    const char *bundleID = [app.bundleIdentifier UTF8String];
+    return strdup(bundleID);
+}
+
+// Retain an AXUIElement
+void retainElement(AXUIElementRef element) {
+    if (element != NULL) {
+        CFRetain(element);
+    }
+}
+
+// Release an AXUIElement
+void releaseElement(AXUIElementRef element) {
+    if (element != NULL) {
+        CFRelease(element);
+    }
+}
+
+// Free a C string
+void freeString(char* str) {
+    if (str != NULL) {
+        free(str);
+    }
+}
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// axBackend talks to the real macOS Accessibility API.
+type axBackend struct{}
+
+// maxScreens bounds the display list; macOS supports far fewer.
+const maxScreens = 16
+
+func init() {
+	defaultBackend = axBackend{}
+	listScreens = screens
+}
+
+func screens() []Screen {
+	var frames, visible [maxScreens]C.CGRect
+	n := int(C.getScreens(&frames[0], &visible[0], maxScreens))
+
+	result := make([]Screen, n)
+	for i := range n {
+		result[i] = Screen{Frame: goRect(frames[i]), VisibleFrame: goRect(visible[i])}
+	}
+	return result
+}
+
+func goRect(rect C.CGRect) Rect {
+	return Rect{
+		X:      float64(rect.origin.x),
+		Y:      float64(rect.origin.y),
+		Width:  float64(rect.size.width),
+		Height: float64(rect.size.height),
+	}
+}
+
+func (axBackend) retain(r ref) {
+	C.retainElement(C.AXUIElementRef(r))
+}
+
+func (axBackend) release(r ref) {
+	C.releaseElement(C.AXUIElementRef(r))
+}
+
+func (axBackend) systemWide() ref {
+	return ref(C.createSystemWideElement())
+}
+
+func (axBackend) focusedApplication() ref {
+	return ref(C.getFocusedApplication())
+}
+
+func (axBackend) focusedElement(r ref) ref {
+	return ref(C.getFocusedElement(C.AXUIElementRef(r)))
+}
+
+func (axBackend) elementAttribute(r ref, name string) ref {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	return ref(C.getElementAttribute(C.AXUIElementRef(r), cName))
+}
+
+func (axBackend) elementsAttribute(r ref, name string) []ref {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var out *C.AXUIElementRef
+	n := int(C.getElementsAttribute(C.AXUIElementRef(r), cName, &out))
+	if out == nil {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(out))
+
+	refs := make([]ref, n)
+	for i, elementRef := range unsafe.Slice(out, n) {
+		refs[i] = ref(elementRef)
+	}
+	return refs
+}
+
+func (axBackend) equal(a, b ref) bool {
+	return C.elementsEqual(C.AXUIElementRef(a), C.AXUIElementRef(b)) == 1
+}
+
+func (axBackend) stringAttribute(r ref, name string) (string, bool) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	cStr := C.getStringAttribute(C.AXUIElementRef(r), cName)
+	if cStr == nil {
+		return "", false
+	}
+	defer C.freeString(cStr)
+	return C.GoString(cStr), true
+}
+
+func (axBackend) boolAttribute(r ref, name string) (bool, bool) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	switch C.getBoolAttribute(C.AXUIElementRef(r), cName) {
+	case 1:
+		return true, true
+	case 0:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func (axBackend) setStringAttribute(r ref, name, value string) error {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	cValue := C.CString(value)
+	defer C.free(unsafe.Pointer(cValue))
+
+	if C.setStringAttribute(C.AXUIElementRef(r), cName, cValue) != 0 {
+		return errors.New("failed to set value")
+	}
+	return nil
+}
+
+func (axBackend) frame(r ref) (Rect, bool) {
+	var rect C.CGRect
+	if C.getFrame(C.AXUIElementRef(r), &rect) != 0 {
+		return Rect{}, false
+	}
+	return goRect(rect), true
+}
+
+func (axBackend) boundsForRange(r ref, rng Range) (Rect, bool) {
+	cfRange := C.CFRange{location: C.CFIndex(rng.Location), length: C.CFIndex(rng.Length)}
+
+	var rect C.CGRect
+	if C.getBoundsForRange(C.AXUIElementRef(r), cfRange, &rect) != 0 {
+		return Rect{}, false
+	}
+	return goRect(rect), true
+}
+
+func (axBackend) rangeAttribute(r ref, name string) (Range, bool) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var cfRange C.CFRange
+	if C.getRangeAttribute(C.AXUIElementRef(r), cName, &cfRange) != 0 {
+		return Range{}, false
+	}
+	return Range{Location: int(cfRange.location), Length: int(cfRange.length)}, true
+}
+
+func (axBackend) pid(r ref) int {
+	return int(C.getPID(C.AXUIElementRef(r)))
+}
+
+func (axBackend) bundleID(pid int) string {
+	cStr := C.getBundleIDForPID(C.pid_t(pid))
+	if cStr == nil {
+		return ""
+	}
+	defer C.freeString(cStr)
+	return C.GoString(cStr)
+}
@@ -0,0 +1,47 @@
+//go:build debug
+
+package accessibility
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustPanic(t *testing.T, want string, f func()) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatalf("no panic, want one mentioning %q", want)
+		}
+		if msg, _ := r.(string); !strings.Contains(msg, want) {
+			t.Fatalf("panic %v, want one mentioning %q", r, want)
+		}
+	}()
+	f()
+}
+
+func TestUseAfterReleasePanics(t *testing.T) {
+	b := newFakeBackend()
+	e := b.own(b.add(0, map[string]string{"AXRole": "AXTextArea"}))
+	e.Release()
+
+	mustPanic(t, "use of released Element", func() { e.Role() })
+	mustPanic(t, "use of released Element", func() { e.Retain() })
+	// The report points at the release site
+	mustPanic(t, "TestUseAfterReleasePanics", func() { e.Value() })
+}
+
+func TestDoubleReleasePanics(t *testing.T) {
+	b := newFakeBackend()
+	e := b.own(b.add(0, nil))
+	e.Release()
+	mustPanic(t, "released twice", e.Release)
+}
+
+func TestReleaseOfBorrowedPanics(t *testing.T) {
+	b := newFakeBackend()
+	r := b.add(0, nil)
+	b.retain(r)
+	mustPanic(t, "Release of borrowed Element", borrowElement(b, r).Release)
+}
@@ -0,0 +1,168 @@
+package accessibility
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestOwnedRetainRelease(t *testing.T) {
+	b := newFakeBackend()
+	r := b.add(0, map[string]string{"AXRole": "AXTextArea"})
+	e := b.own(r)
+
+	if !e.IsOwned() {
+		t.Fatal("lookup result is not owned")
+	}
+	if again := e.Retain(); again != e {
+		t.Fatal("Retain of an owned element returned a new element")
+	}
+	if got := b.count(r); got != 1 {
+		t.Fatalf("backend retains = %d, want 1: owned elements count references themselves", got)
+	}
+
+	e.Release()
+	if got := e.Role(); got != "AXTextArea" {
+		t.Fatalf("Role after first of two releases = %q", got)
+	}
+	if got := b.count(r); got != 1 {
+		t.Fatalf("ref freed with a reference left; retains = %d", got)
+	}
+
+	e.Release()
+	if got := b.count(r); got != 0 {
+		t.Fatalf("backend retains after last release = %d, want 0", got)
+	}
+	if debugRefs {
+		return // use after release panics; see element_debug_test.go
+	}
+	if got := e.Role(); got != "" {
+		t.Errorf("Role after release = %q, want empty", got)
+	}
+	if err := e.SetValue("x"); !errors.Is(err, ErrElementReleased) {
+		t.Errorf("SetValue after release = %v, want ErrElementReleased", err)
+	}
+	if e.Retain() != nil {
+		t.Error("Retain after release returned an element")
+	}
+}
+
+func TestBorrowedElement(t *testing.T) {
+	b := newFakeBackend()
+	r := b.add(0, map[string]string{"AXRole": "AXWindow"})
+	b.retain(r) // held by the caller, e.g. an observer callback
+
+	borrowed := borrowElement(b, r)
+	if borrowed.IsOwned() {
+		t.Fatal("borrowed element reports owned")
+	}
+
+	kept := borrowed.Retain()
+	if kept == borrowed || !kept.IsOwned() {
+		t.Fatal("Retain of a borrowed element must return a new owned element")
+	}
+	if got := b.count(r); got != 2 {
+		t.Fatalf("backend retains = %d, want 2", got)
+	}
+	if !kept.Equal(borrowed) {
+		t.Error("retained copy is not Equal to the borrowed element")
+	}
+
+	kept.Release()
+	if got := b.count(r); got != 1 {
+		t.Fatalf("backend retains after release = %d, want the caller's 1", got)
+	}
+	if !debugRefs {
+		borrowed.Release() // a mistake, but harmless in release builds
+		if got := b.count(r); got != 1 {
+			t.Fatalf("releasing a borrowed element freed the ref; retains = %d", got)
+		}
+	}
+}
+
+func TestNavigationReturnsOwnedElements(t *testing.T) {
+	b := newFakeBackend()
+	root := b.add(0, map[string]string{"AXRole": "AXWindow"})
+	b.add(root, map[string]string{"AXRole": "AXGroup"})
+	child := b.add(root, map[string]string{"AXRole": "AXTextArea"})
+
+	window := b.own(root)
+	children := window.Children()
+	if len(children) != 2 {
+		t.Fatalf("got %d children, want 2", len(children))
+	}
+	parent := children[1].Parent()
+	if !parent.Equal(window) {
+		t.Error("Parent of a child is not its window")
+	}
+	if b.count(child) != 1 {
+		t.Errorf("child retains = %d, want 1", b.count(child))
+	}
+
+	for _, c := range children {
+		c.Release()
+	}
+	parent.Release()
+	window.Release()
+	if n := b.outstanding(); n != 0 {
+		t.Fatalf("%d retains leaked", n)
+	}
+}
+
+func TestStatsCountsLiveElements(t *testing.T) {
+	b := newFakeBackend()
+	before := Stats()
+
+	var elems []*Element
+	for i := 0; i < 3; i++ {
+		elems = append(elems, b.own(b.add(0, nil)))
+	}
+	borrowElement(b, elems[0].ref) // borrowed elements aren't counted
+	elems[0].Release()
+	elems[1].Release()
+
+	got := Stats()
+	if d := got.Created - before.Created; d != 3 {
+		t.Errorf("Created grew by %d, want 3", d)
+	}
+	if d := got.Released - before.Released; d != 2 {
+		t.Errorf("Released grew by %d, want 2", d)
+	}
+	if d := got.Live - before.Live; d != 1 {
+		t.Errorf("Live grew by %d, want the 1 leaked element", d)
+	}
+	if LiveElements() != got.Live {
+		t.Error("LiveElements disagrees with Stats")
+	}
+
+	elems[2].Release()
+	if d := Stats().Live - before.Live; d != 0 {
+		t.Errorf("Live is %d above the start after releasing everything", d)
+	}
+}
+
+func TestConcurrentRetainRelease(t *testing.T) {
+	b := newFakeBackend()
+	r := b.add(0, nil)
+	e := b.own(r)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				e.Retain().Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := b.count(r); got != 1 {
+		t.Fatalf("backend retains = %d, want 1", got)
+	}
+	e.Release()
+	if got := b.count(r); got != 0 {
+		t.Fatalf("backend retains after last release = %d, want 0", got)
+	}
+}
@@ -0,0 +1,147 @@
+package accessibility
+
+import (
+	"sync"
+)
+
+// fakeBackend is an in-memory backend that counts retains, so tests can
+// check that every ref handed out is released exactly once.
+type fakeBackend struct {
+	mu       sync.Mutex
+	next     ref
+	retained map[ref]int
+	strings  map[ref]map[string]string
+	parents  map[ref]ref
+	children map[ref][]ref
+	frames   map[ref]Rect
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		retained: make(map[ref]int),
+		strings:  make(map[ref]map[string]string),
+		parents:  make(map[ref]ref),
+		children: make(map[ref][]ref),
+		frames:   make(map[ref]Rect),
+	}
+}
+
+// add creates a node with the given attributes under parent, or a root if
+// parent is zero. The backend keeps its own reference.
+func (b *fakeBackend) add(parent ref, attrs map[string]string) ref {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	r := b.next
+	b.strings[r] = attrs
+	if parent != 0 {
+		b.parents[r] = parent
+		b.children[parent] = append(b.children[parent], r)
+	}
+	return r
+}
+
+// handOut returns r retained, as the backend's lookups do.
+func (b *fakeBackend) handOut(r ref) ref {
+	if r == 0 {
+		return 0
+	}
+	b.retained[r]++
+	return r
+}
+
+// outstanding returns the number of retains not yet released.
+func (b *fakeBackend) outstanding() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, c := range b.retained {
+		n += c
+	}
+	return n
+}
+
+func (b *fakeBackend) count(r ref) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retained[r]
+}
+
+func (b *fakeBackend) retain(r ref) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retained[r]++
+}
+
+func (b *fakeBackend) release(r ref) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.retained[r] == 0 {
+		panic("fake backend: release of a ref that isn't retained")
+	}
+	b.retained[r]--
+}
+
+func (b *fakeBackend) systemWide() ref         { return 0 }
+func (b *fakeBackend) focusedApplication() ref { return 0 }
+func (b *fakeBackend) focusedElement(r ref) ref {
+	return 0
+}
+
+func (b *fakeBackend) elementAttribute(r ref, name string) ref {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if name == "AXParent" {
+		return b.handOut(b.parents[r])
+	}
+	return 0
+}
+
+func (b *fakeBackend) elementsAttribute(r ref, name string) []ref {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if name != "AXChildren" {
+		return nil
+	}
+	var refs []ref
+	for _, c := range b.children[r] {
+		refs = append(refs, b.handOut(c))
+	}
+	return refs
+}
+
+func (b *fakeBackend) equal(a, c ref) bool { return a == c }
+
+func (b *fakeBackend) stringAttribute(r ref, name string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.strings[r][name]
+	return v, ok
+}
+
+func (b *fakeBackend) boolAttribute(ref, string) (bool, bool) { return false, false }
+
+func (b *fakeBackend) setStringAttribute(r ref, name, value string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.strings[r][name] = value
+	return nil
+}
+
+func (b *fakeBackend) frame(r ref) (Rect, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.frames[r]
+	return f, ok
+}
+
+func (b *fakeBackend) rangeAttribute(ref, string) (Range, bool) { return Range{}, false }
+func (b *fakeBackend) boundsForRange(ref, Range) (Rect, bool)   { return Rect{}, false }
+func (b *fakeBackend) pid(ref) int                              { return 1 }
+func (b *fakeBackend) bundleID(int) string                      { return "com.example.chat" }
+
+// own returns an owned Element for r, retained as if returned by a lookup.
+func (b *fakeBackend) own(r ref) *Element {
+	b.retain(r)
+	return ownElement(b, r)
+}
@@ -2,34 +2,47 @@ package accessibility
 
 import (
 	"context"
-	"log"
 	"sync"
 	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
 )
 
-// FocusMonitor monitors system-wide focus changes and identifies text fields in target apps.
+// logger logs focus changes. Field contents are never logged.
+var logger = logging.Component("accessibility")
+
+// FocusMonitor monitors system-wide focus changes and identifies message
+// composers in target apps.
 type FocusMonitor struct {
-	mu              sync.RWMutex
-	targetBundleIDs map[string]bool
-	currentElement  *Element
+	mu               sync.RWMutex
+	targets          map[string]apps.TargetApp
+	currentElement   *Element
 	onTextFieldFocus func(element *Element, bundleID string)
 	onTextFieldBlur  func()
 
 	pollInterval time.Duration
 	running      bool
+	stopped      bool // set by Stop; no element is stored after it
 	stopCh       chan struct{}
 }
 
-// NewFocusMonitor creates a new focus monitor.
-func NewFocusMonitor(targetBundleIDs map[string]bool) *FocusMonitor {
+// NewFocusMonitor creates a new focus monitor for the given apps.
+func NewFocusMonitor(targets []apps.TargetApp) *FocusMonitor {
+	byBundleID := make(map[string]apps.TargetApp, len(targets))
+	for _, t := range targets {
+		byBundleID[t.BundleID] = t
+	}
 	return &FocusMonitor{
-		targetBundleIDs: targetBundleIDs,
-		pollInterval:    100 * time.Millisecond,
-		stopCh:          make(chan struct{}),
+		targets:      byBundleID,
+		pollInterval: 100 * time.Millisecond,
+		stopCh:       make(chan struct{}),
 	}
 }
 
 // OnTextFieldFocus sets the callback for when a text field in a target app gains focus.
+// The element is owned by the monitor; Retain it to keep it past the callback.
 func (m *FocusMonitor) OnTextFieldFocus(cb func(element *Element, bundleID string)) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -69,8 +82,6 @@ func (m *FocusMonitor) pollLoop(ctx context.Context, systemElement *Element) {
 	defer ticker.Stop()
 	defer systemElement.Release()
 
-	var lastWasTextField bool
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -82,81 +93,103 @@ func (m *FocusMonitor) pollLoop(ctx context.Context, systemElement *Element) {
 			if err != nil {
 				continue
 			}
+			m.checkFocus(focused)
+		}
+	}
+}
 
-			bundleID := focused.BundleID()
-			isTarget := m.targetBundleIDs[bundleID]
-			isTextField := focused.IsTextField()
-
-			m.mu.RLock()
-			onFocus := m.onTextFieldFocus
-			onBlur := m.onTextFieldBlur
-			m.mu.RUnlock()
-
-			// Transitioned into a monitored text field
-			if isTarget && isTextField && !lastWasTextField {
-				log.Printf("Focus: text field in %s", bundleID)
-				m.mu.Lock()
-				m.currentElement = focused
-				m.mu.Unlock()
-
-				if onFocus != nil {
-					onFocus(focused, bundleID)
-				}
-			}
+// checkFocus handles one focus sample. It takes ownership of focused.
+func (m *FocusMonitor) checkFocus(focused *Element) {
+	current := m.CurrentElement()
+	if current != nil {
+		defer current.Release()
+	}
 
-			// Transitioned out of a monitored text field
-			if lastWasTextField && (!isTarget || !isTextField) {
-				log.Printf("Blur: left monitored text field")
-				m.mu.Lock()
-				if m.currentElement != nil {
-					m.currentElement.Release()
-					m.currentElement = nil
-				}
-				m.mu.Unlock()
-
-				if onBlur != nil {
-					onBlur()
-				}
-			}
+	bundleID := focused.BundleID()
+
+	m.mu.RLock()
+	target, isTarget := m.targets[bundleID]
+	onFocus := m.onTextFieldFocus
+	onBlur := m.onTextFieldBlur
+	m.mu.RUnlock()
 
-			lastWasTextField = isTarget && isTextField
+	// Only classify new fields; the walk up the tree is too costly per tick
+	sameField := current != nil && current.Equal(focused)
+	isComposer := sameField
+	if !sameField && isTarget {
+		kind := ClassifyElement(focused, &target)
+		isComposer = kind == FieldComposer
+		if kind == FieldSecure || kind == FieldExcluded {
+			logger.Debug("ignoring text field", "kind", kind, "bundle_id", bundleID)
+		}
+	}
 
-			// Release if not storing
-			if m.currentElement != focused {
-				focused.Release()
+	// Left the monitored composer, possibly straight into another field
+	if current != nil && !sameField {
+		logger.Debug("left monitored text field")
+		metrics.FocusTransitions.With("away").Inc()
+		m.mu.Lock()
+		if m.currentElement != nil {
+			m.currentElement.Release()
+			m.currentElement = nil
+		}
+		m.mu.Unlock()
+
+		if onBlur != nil {
+			onBlur()
+		}
+	}
+
+	// Entered a composer in a monitored app. The monitor keeps its own
+	// reference, so Stop can release it while the callback still runs.
+	if isComposer && !sameField {
+		m.mu.Lock()
+		stored := !m.stopped
+		if stored {
+			m.currentElement = focused.Retain()
+		}
+		m.mu.Unlock()
+
+		if stored {
+			logger.Debug("entered text field", "bundle_id", bundleID)
+			metrics.FocusTransitions.With("field").Inc()
+			if onFocus != nil {
+				onFocus(focused, bundleID)
 			}
 		}
 	}
+
+	focused.Release()
 }
 
 // CurrentElement returns the currently focused text field element, if any.
+// The result is retained; the caller must Release it.
 func (m *FocusMonitor) CurrentElement() *Element {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.currentElement
+	if m.currentElement == nil {
+		return nil
+	}
+	return m.currentElement.Retain()
 }
 
 // CurrentText returns the text in the currently focused field.
 func (m *FocusMonitor) CurrentText() string {
-	m.mu.RLock()
-	elem := m.currentElement
-	m.mu.RUnlock()
-
+	elem := m.CurrentElement()
 	if elem == nil {
 		return ""
 	}
+	defer elem.Release()
 	return elem.Value()
 }
 
 // SetCurrentText sets the text in the currently focused field.
 func (m *FocusMonitor) SetCurrentText(text string) error {
-	m.mu.RLock()
-	elem := m.currentElement
-	m.mu.RUnlock()
-
+	elem := m.CurrentElement()
 	if elem == nil {
 		return ErrElementNotFound
 	}
+	defer elem.Release()
 	return elem.SetValue(text)
 }
 
@@ -171,6 +204,7 @@ func (m *FocusMonitor) Stop() {
 
 	close(m.stopCh)
 	m.running = false
+	m.stopped = true
 
 	if m.currentElement != nil {
 		m.currentElement.Release()
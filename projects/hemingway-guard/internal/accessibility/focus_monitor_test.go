@@ -0,0 +1,126 @@
+package accessibility
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
+)
+
+// chatWindow builds a window with two composers and a button.
+func chatWindow(b *fakeBackend) (composer, other, button ref) {
+	window := b.add(0, map[string]string{"AXRole": "AXWindow"})
+	composer = b.add(window, map[string]string{"AXRole": "AXTextArea", "AXPlaceholderValue": "Message #general"})
+	other = b.add(window, map[string]string{"AXRole": "AXTextArea", "AXPlaceholderValue": "Reply…"})
+	button = b.add(window, map[string]string{"AXRole": "AXButton"})
+	return composer, other, button
+}
+
+func newTestMonitor() *FocusMonitor {
+	return NewFocusMonitor([]apps.TargetApp{{Name: "Chat", BundleID: "com.example.chat"}})
+}
+
+func TestFocusMonitorTransitions(t *testing.T) {
+	b := newFakeBackend()
+	composer, other, button := chatWindow(b)
+	m := newTestMonitor()
+	m.running = true
+
+	var focused, blurred int
+	m.OnTextFieldFocus(func(e *Element, bundleID string) {
+		focused++
+		if bundleID != "com.example.chat" {
+			t.Errorf("focus callback bundle ID = %q", bundleID)
+		}
+	})
+	m.OnTextFieldBlur(func() { blurred++ })
+
+	m.checkFocus(b.own(composer))
+	if !m.IsMonitoring() || focused != 1 {
+		t.Fatalf("after focusing the composer: monitoring %v, %d focus callbacks", m.IsMonitoring(), focused)
+	}
+	if got := b.count(composer); got != 1 {
+		t.Errorf("composer retains = %d, want the monitor's 1", got)
+	}
+
+	// The same field again is not a new focus
+	m.checkFocus(b.own(composer))
+	if focused != 1 || blurred != 0 || b.count(composer) != 1 {
+		t.Errorf("same field: %d focus, %d blur callbacks, %d retains", focused, blurred, b.count(composer))
+	}
+
+	// Straight into another composer
+	m.checkFocus(b.own(other))
+	if focused != 2 || blurred != 1 {
+		t.Errorf("other composer: %d focus, %d blur callbacks; want 2 and 1", focused, blurred)
+	}
+	if b.count(composer) != 0 || b.count(other) != 1 {
+		t.Errorf("retains: composer %d, other %d; want 0 and 1", b.count(composer), b.count(other))
+	}
+
+	m.checkFocus(b.own(button))
+	if m.IsMonitoring() || blurred != 2 {
+		t.Errorf("after a button: monitoring %v, %d blur callbacks", m.IsMonitoring(), blurred)
+	}
+	if n := b.outstanding(); n != 0 {
+		t.Errorf("%d retains leaked", n)
+	}
+}
+
+func TestFocusMonitorStopReleases(t *testing.T) {
+	b := newFakeBackend()
+	composer, _, _ := chatWindow(b)
+	m := newTestMonitor()
+	m.running = true
+
+	m.checkFocus(b.own(composer))
+	m.Stop()
+	if m.IsMonitoring() {
+		t.Error("still monitoring after Stop")
+	}
+	if n := b.outstanding(); n != 0 {
+		t.Fatalf("%d retains left after Stop", n)
+	}
+
+	// A sample that was in flight when Stop ran isn't stored
+	called := false
+	m.OnTextFieldFocus(func(*Element, string) { called = true })
+	m.checkFocus(b.own(composer))
+	if m.IsMonitoring() || called {
+		t.Errorf("sample after Stop: monitoring %v, callback %v", m.IsMonitoring(), called)
+	}
+	if n := b.outstanding(); n != 0 {
+		t.Errorf("%d retains leaked by a sample after Stop", n)
+	}
+}
+
+func TestFocusMonitorStopDuringCheck(t *testing.T) {
+	for range 50 {
+		b := newFakeBackend()
+		composer, other, _ := chatWindow(b)
+		m := newTestMonitor()
+		m.running = true
+		m.OnTextFieldFocus(func(e *Element, _ string) {
+			e.Role() // the element stays valid for the callback
+		})
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 20 {
+				r := composer
+				if i%2 == 1 {
+					r = other
+				}
+				m.checkFocus(b.own(r))
+			}
+		}()
+		m.Stop()
+		wg.Wait()
+
+		if n := b.outstanding(); n != 0 {
+			t.Fatalf("%d retains leaked when Stop raced a focus check", n)
+		}
+	}
+}
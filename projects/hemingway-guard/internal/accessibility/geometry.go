@@ -0,0 +1,132 @@
+package accessibility
+
+// Rect is a rectangle. Element frames and text bounds are in AX screen
+// coordinates: origin at the top-left of the primary display, y growing
+// downward. AppKit uses the bottom-left of the primary display with y
+// growing upward; use ToAppKit to convert.
+type Rect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Point is a location in screen coordinates.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Range is a span of characters in an element's value, as used by
+// AXSelectedTextRange. Location and Length count UTF-16 code units.
+type Range struct {
+	Location int `json:"location"`
+	Length   int `json:"length"`
+}
+
+// Screen describes one display in AppKit coordinates. VisibleFrame excludes
+// the menu bar and Dock.
+type Screen struct {
+	Frame        Rect
+	VisibleFrame Rect
+}
+
+// MaxX returns the right edge of r.
+func (r Rect) MaxX() float64 { return r.X + r.Width }
+
+// MaxY returns the bottom edge of r in AX coordinates (top edge in AppKit).
+func (r Rect) MaxY() float64 { return r.Y + r.Height }
+
+// IsEmpty reports whether r has no area. Some apps report a zero rect
+// instead of failing when they can't compute text bounds.
+func (r Rect) IsEmpty() bool { return r.Width <= 0 || r.Height <= 0 }
+
+// Center returns the midpoint of r.
+func (r Rect) Center() Point {
+	return Point{X: r.X + r.Width/2, Y: r.Y + r.Height/2}
+}
+
+// Contains reports whether p lies inside r.
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.X && p.X < r.MaxX() && p.Y >= r.Y && p.Y < r.MaxY()
+}
+
+// ToAppKit converts a rect from AX coordinates to AppKit coordinates.
+// Both systems are anchored to the primary display, so only its height is
+// needed, whatever the arrangement of the other displays.
+func ToAppKit(r Rect, primaryHeight float64) Rect {
+	return Rect{X: r.X, Y: primaryHeight - r.MaxY(), Width: r.Width, Height: r.Height}
+}
+
+// ToAX converts a rect from AppKit coordinates to AX coordinates.
+// The flip is its own inverse.
+func ToAX(r Rect, primaryHeight float64) Rect {
+	return ToAppKit(r, primaryHeight)
+}
+
+// ScreenAt returns the screen containing p (AppKit coordinates), or the
+// screen nearest to it when p is off every screen. The first screen is the
+// primary display.
+func ScreenAt(p Point, screens []Screen) (Screen, bool) {
+	if len(screens) == 0 {
+		return Screen{}, false
+	}
+
+	best, bestDist := screens[0], -1.0
+	for _, s := range screens {
+		if s.Frame.Contains(p) {
+			return s, true
+		}
+		c := s.Frame.Center()
+		dx, dy := c.X-p.X, c.Y-p.Y
+		if d := dx*dx + dy*dy; bestDist < 0 || d < bestDist {
+			best, bestDist = s, d
+		}
+	}
+	return best, true
+}
+
+// PopoverAnchor picks the AppKit point to show the approval popover at.
+// It prefers the caret bounds, falling back to the top-centre of the text
+// field, and keeps the point inside the visible area of its screen. Both
+// rects are in AX coordinates; pass nil for either that is unknown. A caret
+// has no width, so only its height has to be positive.
+func PopoverAnchor(caret, field *Rect, screens []Screen) (Point, bool) {
+	if len(screens) == 0 {
+		return Point{}, false
+	}
+
+	var target Rect
+	switch {
+	case caret != nil && caret.Height > 0:
+		target = *caret
+	case field != nil && !field.IsEmpty():
+		target = Rect{X: field.Center().X, Y: field.Y}
+	default:
+		return Point{}, false
+	}
+
+	// The popover opens above the anchor, so use the target's top edge.
+	flipped := ToAppKit(target, screens[0].Frame.Height)
+	p := Point{X: flipped.X, Y: flipped.MaxY()}
+
+	screen, _ := ScreenAt(p, screens)
+	return clamp(p, screen.VisibleFrame), true
+}
+
+func clamp(p Point, r Rect) Point {
+	if r.IsEmpty() {
+		return p
+	}
+	p.X = min(max(p.X, r.X), r.MaxX()-1)
+	p.Y = min(max(p.Y, r.Y), r.MaxY()-1)
+	return p
+}
+
+// listScreens returns the current displays. Platform files replace it.
+var listScreens = func() []Screen { return nil }
+
+// Screens returns the current displays in AppKit coordinates, primary first.
+func Screens() []Screen {
+	return listScreens()
+}
@@ -0,0 +1,146 @@
+package accessibility
+
+import "testing"
+
+// Three displays in AppKit coordinates: a 1440x900 primary with a menu bar,
+// a 1920x1080 display to its left extending below it, and a 2560x1440
+// display above it. In AX coordinates the left display starts at x -1920
+// and the upper one at y -1440.
+var (
+	primary = Screen{
+		Frame:        Rect{X: 0, Y: 0, Width: 1440, Height: 900},
+		VisibleFrame: Rect{X: 0, Y: 0, Width: 1440, Height: 875},
+	}
+	leftOfPrimary = Screen{
+		Frame:        Rect{X: -1920, Y: -180, Width: 1920, Height: 1080},
+		VisibleFrame: Rect{X: -1920, Y: -180, Width: 1920, Height: 1080},
+	}
+	abovePrimary = Screen{
+		Frame:        Rect{X: 0, Y: 900, Width: 2560, Height: 1440},
+		VisibleFrame: Rect{X: 0, Y: 900, Width: 2560, Height: 1415},
+	}
+	screens = []Screen{primary, leftOfPrimary, abovePrimary}
+)
+
+func TestRectIsEmpty(t *testing.T) {
+	tests := []struct {
+		r    Rect
+		want bool
+	}{
+		{Rect{}, true},
+		{Rect{X: 10, Y: 10, Width: 0, Height: 18}, true},
+		{Rect{X: 10, Y: 10, Width: 40, Height: 0}, true},
+		{Rect{Width: -5, Height: 10}, true},
+		{Rect{X: -100, Y: -100, Width: 1, Height: 1}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.IsEmpty(); got != tt.want {
+			t.Errorf("%+v.IsEmpty() = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestToAppKit(t *testing.T) {
+	tests := []struct {
+		ax, appKit Rect
+	}{
+		{Rect{X: 10, Y: 20, Width: 100, Height: 50}, Rect{X: 10, Y: 830, Width: 100, Height: 50}},
+		// Below the primary display, on the left one.
+		{Rect{X: -1510, Y: 1022, Width: 2, Height: 18}, Rect{X: -1510, Y: -140, Width: 2, Height: 18}},
+		// Above the primary display.
+		{Rect{X: 100, Y: -500, Width: 0, Height: 20}, Rect{X: 100, Y: 1380, Width: 0, Height: 20}},
+	}
+	for _, tt := range tests {
+		got := ToAppKit(tt.ax, primary.Frame.Height)
+		if got != tt.appKit {
+			t.Errorf("ToAppKit(%+v) = %+v, want %+v", tt.ax, got, tt.appKit)
+		}
+		if back := ToAX(got, primary.Frame.Height); back != tt.ax {
+			t.Errorf("ToAX(%+v) = %+v, want %+v", got, back, tt.ax)
+		}
+	}
+}
+
+func TestScreenAt(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Point
+		want Screen
+	}{
+		{"primary", Point{X: 720, Y: 450}, primary},
+		{"negative origin", Point{X: -10, Y: -100}, leftOfPrimary},
+		{"above", Point{X: 2000, Y: 2000}, abovePrimary},
+		{"left edge belongs to the right screen", Point{X: 0, Y: 10}, primary},
+		{"off screen picks nearest", Point{X: 1500, Y: 100}, primary},
+		{"off screen below left", Point{X: -1000, Y: -900}, leftOfPrimary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ScreenAt(tt.p, screens)
+			if !ok || got != tt.want {
+				t.Errorf("ScreenAt(%+v) = %+v, %v; want %+v", tt.p, got, ok, tt.want.Frame)
+			}
+		})
+	}
+
+	if _, ok := ScreenAt(Point{}, nil); ok {
+		t.Error("ScreenAt found a screen without displays")
+	}
+}
+
+func TestPopoverAnchor(t *testing.T) {
+	field := &Rect{X: 200, Y: 700, Width: 400, Height: 40}
+
+	tests := []struct {
+		name   string
+		caret  *Rect
+		field  *Rect
+		want   Point
+		wantOK bool
+	}{
+		{"caret", &Rect{X: 300, Y: 710, Width: 2, Height: 18}, field, Point{X: 300, Y: 190}, true},
+		{"zero-width caret", &Rect{X: 300, Y: 710, Width: 0, Height: 18}, field, Point{X: 300, Y: 190}, true},
+		{"zero caret falls back to field", &Rect{}, field, Point{X: 400, Y: 200}, true},
+		{"no caret", nil, field, Point{X: 400, Y: 200}, true},
+		{"nothing known", nil, nil, Point{}, false},
+		{"empty field", nil, &Rect{X: 200, Y: 700}, Point{}, false},
+		{"left display", &Rect{X: -1510, Y: 1022, Width: 2, Height: 18}, nil, Point{X: -1510, Y: -122}, true},
+		{"upper display", &Rect{X: 100, Y: -500, Width: 0, Height: 20}, nil, Point{X: 100, Y: 1400}, true},
+		{"clamped below menu bar", &Rect{X: 50, Y: 5, Width: 0, Height: 16}, nil, Point{X: 50, Y: 874}, true},
+		{"clamped to right edge", &Rect{X: 1439.5, Y: 400, Width: 0, Height: 18}, nil, Point{X: 1439, Y: 500}, true},
+		{"clamped on upper display", &Rect{X: 10, Y: -1440, Width: 0, Height: 10}, nil, Point{X: 10, Y: 2314}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PopoverAnchor(tt.caret, tt.field, screens)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("PopoverAnchor = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := PopoverAnchor(field, field, nil); ok {
+		t.Error("PopoverAnchor succeeded without displays")
+	}
+}
+
+func TestClamp(t *testing.T) {
+	visible := Rect{X: -1920, Y: -180, Width: 1920, Height: 1080}
+	tests := []struct {
+		p, want Point
+	}{
+		{Point{X: -100, Y: 0}, Point{X: -100, Y: 0}},
+		{Point{X: -2500, Y: -500}, Point{X: -1920, Y: -180}},
+		{Point{X: 50, Y: 1000}, Point{X: -1, Y: 899}},
+	}
+	for _, tt := range tests {
+		if got := clamp(tt.p, visible); got != tt.want {
+			t.Errorf("clamp(%+v) = %+v, want %+v", tt.p, got, tt.want)
+		}
+	}
+
+	p := Point{X: 5000, Y: 5000}
+	if got := clamp(p, Rect{X: 0, Y: 0, Width: 1440}); got != p {
+		t.Errorf("clamp to an empty rect moved the point to %+v", got)
+	}
+}
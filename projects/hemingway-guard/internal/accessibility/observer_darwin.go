@@ -39,23 +39,6 @@ static inline int addNotification(AXObserverRef observer, AXUIElementRef element
 static inline CFRunLoopSourceRef getRunLoopSource(AXObserverRef observer) {
     return AXObserverGetRunLoopSource(observer);
 }
-
-// Get focused application element
-static inline AXUIElementRef getFocusedApplication() {
-    AXUIElementRef systemWide = AXUIElementCreateSystemWide();
-    AXUIElementRef focusedApp = NULL;
-    AXError error = AXUIElementCopyAttributeValue(
-        systemWide,
-        kAXFocusedApplicationAttribute,
-        (CFTypeRef *)&focusedApp
-    );
-    CFRelease(systemWide);
-
-    if (error != kAXErrorSuccess) {
-        return NULL;
-    }
-    return focusedApp;
-}
 */
 import "C"
 
@@ -65,6 +48,7 @@ import (
 )
 
 // FocusCallback is called when focus changes to a new element.
+// The element is borrowed and only valid during the call.
 type FocusCallback func(element *Element)
 
 var (
@@ -80,14 +64,14 @@ func SetFocusCallback(cb FocusCallback) {
 }
 
 //export goFocusCallback
-func goFocusCallback(ref C.AXUIElementRef) {
+func goFocusCallback(elementRef C.AXUIElementRef) {
 	focusCallbackMu.RLock()
 	cb := focusCallback
 	focusCallbackMu.RUnlock()
 
-	if cb != nil && uintptr(ref) != 0 {
-		// Note: We don't own this ref, so don't release it
-		cb(&Element{ref: ref})
+	if cb != nil && uintptr(elementRef) != 0 {
+		// We don't own this ref; the callback must Retain to keep it
+		cb(borrowElement(defaultBackend, ref(elementRef)))
 	}
 }
 
@@ -99,16 +83,20 @@ type Observer struct {
 
 // NewObserver creates a new observer for the given process ID.
 func NewObserver(pid int) (*Observer, error) {
-	ref := C.createObserver(C.pid_t(pid))
-	if uintptr(ref) == 0 {
+	observerRef := C.createObserver(C.pid_t(pid))
+	if uintptr(observerRef) == 0 {
 		return nil, ErrAccessibilityNotEnabled
 	}
-	return &Observer{ref: ref, pid: pid}, nil
+	return &Observer{ref: observerRef, pid: pid}, nil
 }
 
 // AddFocusNotification registers for focus change notifications on the element.
 func (o *Observer) AddFocusNotification(element *Element) error {
-	result := C.addNotification(o.ref, element.ref, C.CFStringRef(C.kAXFocusedUIElementChangedNotification))
+	r, ok := element.live()
+	if !ok {
+		return ErrElementReleased
+	}
+	result := C.addNotification(o.ref, C.AXUIElementRef(r), C.CFStringRef(C.kAXFocusedUIElementChangedNotification))
 	if result != 0 {
 		return errors.New("failed to add focus notification")
 	}
@@ -126,12 +114,3 @@ func (o *Observer) Stop() {
 	source := C.getRunLoopSource(o.ref)
 	C.CFRunLoopRemoveSource(C.CFRunLoopGetCurrent(), source, C.kCFRunLoopDefaultMode)
 }
-
-// FocusedApplication returns the currently focused application element.
-func FocusedApplication() *Element {
-	ref := C.getFocusedApplication()
-	if uintptr(ref) == 0 {
-		return nil
-	}
-	return &Element{ref: ref}
-}
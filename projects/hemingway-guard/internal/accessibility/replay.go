@@ -0,0 +1,268 @@
+package accessibility
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SnapshotVersion is the current snapshot file format version.
+const SnapshotVersion = 1
+
+// Snapshot is a recorded AX window tree, as written by `hemingway-guard ax-dump`.
+type Snapshot struct {
+	Version    int       `json:"version"`
+	BundleID   string    `json:"bundle_id"`
+	PID        int       `json:"pid"`
+	CapturedAt time.Time `json:"captured_at"`
+	Window     *Node     `json:"window"`
+}
+
+// CaptureSnapshot records the window containing the focused element.
+func CaptureSnapshot(focused *Element, opts CaptureOptions) (*Snapshot, error) {
+	window := focused.Window()
+	if window == nil {
+		return nil, ErrElementNotFound
+	}
+	defer window.Release()
+
+	return &Snapshot{
+		Version:    SnapshotVersion,
+		BundleID:   focused.BundleID(),
+		PID:        focused.PID(),
+		CapturedAt: time.Now().UTC(),
+		Window:     Capture(window, focused, opts),
+	}, nil
+}
+
+// WriteJSON writes the snapshot as indented JSON.
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadSnapshot decodes a snapshot written by WriteJSON.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	if snap.Window == nil {
+		return nil, errors.New("snapshot has no window")
+	}
+	snap.Window.link(nil)
+	return &snap, nil
+}
+
+// LoadSnapshot reads a snapshot file from disk.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+// Replay serves Elements backed by a snapshot instead of the live system, so
+// code written against Element can run on any machine. Values written with
+// SetValue update the snapshot in place.
+type Replay struct {
+	snap  *Snapshot
+	nodes []*Node
+	refs  map[*Node]ref
+}
+
+// replaySystemWide is the ref of the synthetic system-wide element.
+const replaySystemWide ref = 1
+
+// NewReplay indexes a snapshot for replay.
+func NewReplay(snap *Snapshot) *Replay {
+	r := &Replay{snap: snap, refs: make(map[*Node]ref)}
+	snap.Window.Walk(func(n *Node) bool {
+		r.nodes = append(r.nodes, n)
+		r.refs[n] = replaySystemWide + ref(len(r.nodes))
+		return true
+	})
+	return r
+}
+
+// LoadReplay reads a snapshot file and indexes it for replay.
+func LoadReplay(path string) (*Replay, error) {
+	snap, err := LoadSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplay(snap), nil
+}
+
+// Snapshot returns the underlying snapshot.
+func (r *Replay) Snapshot() *Snapshot {
+	return r.snap
+}
+
+// SystemWide returns the replay's system-wide element. Its focused element
+// is the snapshot's focused node.
+func (r *Replay) SystemWide() *Element {
+	return ownElement(r, replaySystemWide)
+}
+
+// Window returns the recorded window.
+func (r *Replay) Window() *Element {
+	return ownElement(r, r.refs[r.snap.Window])
+}
+
+// Focused returns the recorded focused element, or nil if none was marked.
+func (r *Replay) Focused() *Element {
+	return ownElement(r, r.focusedElement(replaySystemWide))
+}
+
+func (r *Replay) node(h ref) *Node {
+	i := int(h - replaySystemWide - 1)
+	if i < 0 || i >= len(r.nodes) {
+		return nil
+	}
+	return r.nodes[i]
+}
+
+func (r *Replay) retain(ref)  {}
+func (r *Replay) release(ref) {}
+
+func (r *Replay) systemWide() ref {
+	return replaySystemWide
+}
+
+func (r *Replay) focusedApplication() ref {
+	return 0
+}
+
+func (r *Replay) focusedElement(ref) ref {
+	if focused := r.snap.Window.FindFocused(); focused != nil {
+		return r.refs[focused]
+	}
+	return 0
+}
+
+func (r *Replay) elementAttribute(h ref, name string) ref {
+	n := r.node(h)
+	if n == nil {
+		return 0
+	}
+	switch name {
+	case "AXParent":
+		if n.parent != nil {
+			return r.refs[n.parent]
+		}
+	case "AXWindow":
+		return r.refs[r.snap.Window]
+	}
+	return 0
+}
+
+func (r *Replay) elementsAttribute(h ref, name string) []ref {
+	n := r.node(h)
+	if n == nil || name != "AXChildren" {
+		return nil
+	}
+	children := make([]ref, len(n.Children))
+	for i, child := range n.Children {
+		children[i] = r.refs[child]
+	}
+	return children
+}
+
+func (r *Replay) equal(a, b ref) bool {
+	return a == b
+}
+
+func (r *Replay) stringAttribute(h ref, name string) (string, bool) {
+	n := r.node(h)
+	if n == nil {
+		return "", false
+	}
+	var value string
+	switch name {
+	case "AXRole":
+		value = n.Role
+	case "AXSubrole":
+		value = n.Subrole
+	case "AXTitle":
+		value = n.Title
+	case "AXValue":
+		value = n.Value
+	case "AXDescription":
+		value = n.Description
+	case "AXIdentifier":
+		value = n.Identifier
+	case "AXPlaceholderValue":
+		value = n.Placeholder
+	}
+	return value, value != ""
+}
+
+func (r *Replay) boolAttribute(h ref, name string) (bool, bool) {
+	n := r.node(h)
+	if n == nil {
+		return false, false
+	}
+	switch name {
+	case "AXEditable":
+		return n.Editable, true
+	case "AXFocused":
+		return n.Focused, true
+	}
+	return false, false
+}
+
+func (r *Replay) setStringAttribute(h ref, name, value string) error {
+	n := r.node(h)
+	if n == nil || name != "AXValue" || !n.Editable {
+		return errors.New("failed to set value")
+	}
+	n.Value = value
+	return nil
+}
+
+func (r *Replay) frame(h ref) (Rect, bool) {
+	n := r.node(h)
+	if n == nil || n.Frame == nil {
+		return Rect{}, false
+	}
+	return *n.Frame, true
+}
+
+func (r *Replay) rangeAttribute(h ref, name string) (Range, bool) {
+	n := r.node(h)
+	if n == nil || name != "AXSelectedTextRange" || n.SelectedRange == nil {
+		return Range{}, false
+	}
+	return *n.SelectedRange, true
+}
+
+// boundsForRange only knows the bounds recorded for the selection at
+// capture time.
+func (r *Replay) boundsForRange(h ref, rng Range) (Rect, bool) {
+	n := r.node(h)
+	if n == nil || n.CaretBounds == nil || n.SelectedRange == nil || *n.SelectedRange != rng {
+		return Rect{}, false
+	}
+	return *n.CaretBounds, true
+}
+
+func (r *Replay) pid(h ref) int {
+	if h == 0 {
+		return -1
+	}
+	return r.snap.PID
+}
+
+func (r *Replay) bundleID(int) string {
+	return r.snap.BundleID
+}
@@ -0,0 +1,271 @@
+package accessibility
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtures are recorded windows of the supported apps, with the composer
+// focused.
+var fixtures = []struct {
+	file         string
+	bundleID     string
+	windowTitle  string
+	composerRole string
+	composerText string
+}{
+	{"slack_channel.json", "com.tinyspeck.slackmacgap", "general (Channel) - Acme - Slack", "AXTextArea", "Will do, I'll check it after lunch"},
+	{"slack_thread.json", "com.tinyspeck.slackmacgap", "general (Channel) - Acme - Slack", "AXTextArea", "Thanks, I'll take the second half"},
+	{"discord_channel.json", "com.hnc.Discord", "#general | Acme - Discord", "AXTextArea", "count me in"},
+	{"messages_chat.json", "com.apple.MobileSMS", "Jane Appleseed", "AXTextField", "Running ten minutes late"},
+}
+
+func loadFixture(t *testing.T, file string) *Replay {
+	t.Helper()
+	replay, err := LoadReplay(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("LoadReplay(%s): %v", file, err)
+	}
+	return replay
+}
+
+// checkNoLeaks fails the test if it created owned elements it didn't release.
+func checkNoLeaks(t *testing.T) {
+	t.Helper()
+	before := LiveElements()
+	t.Cleanup(func() {
+		if leaked := LiveElements() - before; leaked != 0 {
+			t.Errorf("%d elements not released", leaked)
+		}
+	})
+}
+
+func TestReplayFixtures(t *testing.T) {
+	for _, tt := range fixtures {
+		t.Run(tt.file, func(t *testing.T) {
+			checkNoLeaks(t)
+			replay := loadFixture(t, tt.file)
+
+			focused := replay.Focused()
+			if focused == nil {
+				t.Fatal("no focused element")
+			}
+			defer focused.Release()
+
+			if got := focused.Role(); got != tt.composerRole {
+				t.Errorf("focused role = %q, want %q", got, tt.composerRole)
+			}
+			if got := focused.Value(); got != tt.composerText {
+				t.Errorf("focused value = %q, want %q", got, tt.composerText)
+			}
+			if !focused.IsEditable() {
+				t.Error("focused element isn't editable")
+			}
+			if got := focused.BundleID(); got != tt.bundleID {
+				t.Errorf("BundleID = %q, want %q", got, tt.bundleID)
+			}
+
+			window := focused.Window()
+			if window == nil {
+				t.Fatal("no window")
+			}
+			defer window.Release()
+			if got := window.Title(); got != tt.windowTitle {
+				t.Errorf("window title = %q, want %q", got, tt.windowTitle)
+			}
+
+			// The parent chain ends at the window.
+			var top *Element
+			for p := focused.Parent(); p != nil; {
+				if top != nil {
+					top.Release()
+				}
+				top = p
+				p = p.Parent()
+			}
+			if top == nil || !top.Equal(window) {
+				t.Error("parent chain doesn't reach the window")
+			}
+			if top != nil {
+				top.Release()
+			}
+		})
+	}
+}
+
+func TestReplayFocusedFromSystemWide(t *testing.T) {
+	checkNoLeaks(t)
+	replay := loadFixture(t, "discord_channel.json")
+
+	system := replay.SystemWide()
+	defer system.Release()
+	focused, err := system.FocusedElement()
+	if err != nil {
+		t.Fatalf("FocusedElement: %v", err)
+	}
+	defer focused.Release()
+
+	direct := replay.Focused()
+	defer direct.Release()
+	if !focused.Equal(direct) {
+		t.Error("system-wide focus differs from Replay.Focused")
+	}
+}
+
+func TestReplayChildren(t *testing.T) {
+	checkNoLeaks(t)
+	replay := loadFixture(t, "messages_chat.json")
+
+	window := replay.Window()
+	defer window.Release()
+
+	var roles []string
+	for _, child := range window.Children() {
+		roles = append(roles, child.Role())
+		child.Release()
+	}
+	if got, want := strings.Join(roles, ","), "AXToolbar,AXSplitGroup,AXSheet"; got != want {
+		t.Errorf("window children = %s, want %s", got, want)
+	}
+}
+
+func TestReplaySetValue(t *testing.T) {
+	checkNoLeaks(t)
+	replay := loadFixture(t, "slack_channel.json")
+
+	focused := replay.Focused()
+	defer focused.Release()
+	if err := focused.SetValue("rewritten"); err != nil {
+		t.Fatalf("SetValue on the composer: %v", err)
+	}
+	if got := focused.Value(); got != "rewritten" {
+		t.Errorf("value after SetValue = %q", got)
+	}
+	if got := replay.Snapshot().Window.FindFocused().Value; got != "rewritten" {
+		t.Errorf("snapshot value after SetValue = %q", got)
+	}
+
+	window := replay.Window()
+	defer window.Release()
+	if err := window.SetValue("x"); err == nil {
+		t.Error("SetValue on a window succeeded")
+	}
+}
+
+func TestReplayCaretBounds(t *testing.T) {
+	checkNoLeaks(t)
+
+	replay := loadFixture(t, "slack_thread.json")
+	focused := replay.Focused()
+	defer focused.Release()
+	got, ok := focused.CaretBounds()
+	if want := (Rect{X: 1060, Y: 832, Width: 38, Height: 18}); !ok || got != want {
+		t.Errorf("CaretBounds = %v, %v; want %v", got, ok, want)
+	}
+	if _, ok := focused.BoundsForRange(Range{Location: 0, Length: 1}); ok {
+		t.Error("bounds for an unrecorded range")
+	}
+
+	// Messages doesn't report bounds for ranges.
+	replay = loadFixture(t, "messages_chat.json")
+	composer := replay.Focused()
+	defer composer.Release()
+	if _, ok := composer.SelectedTextRange(); !ok {
+		t.Error("no selected range")
+	}
+	if _, ok := composer.CaretBounds(); ok {
+		t.Error("CaretBounds reported without recorded bounds")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, tt := range fixtures {
+		t.Run(tt.file, func(t *testing.T) {
+			snap := loadFixture(t, tt.file).Snapshot()
+
+			var first bytes.Buffer
+			if err := snap.WriteJSON(&first); err != nil {
+				t.Fatalf("WriteJSON: %v", err)
+			}
+			again, err := ReadSnapshot(bytes.NewReader(first.Bytes()))
+			if err != nil {
+				t.Fatalf("ReadSnapshot: %v", err)
+			}
+			var second bytes.Buffer
+			if err := again.WriteJSON(&second); err != nil {
+				t.Fatalf("WriteJSON: %v", err)
+			}
+			if first.String() != second.String() {
+				t.Error("snapshot changed across a round trip")
+			}
+			if focused := again.Window.FindFocused(); focused == nil || focused.Parent() == nil {
+				t.Error("ReadSnapshot didn't link parents")
+			}
+		})
+	}
+}
+
+// Capturing a replayed window must reproduce the recording, so snapshots
+// taken by ax-dump and trees walked live look the same to per-app logic.
+func TestCaptureReproducesSnapshot(t *testing.T) {
+	for _, tt := range fixtures {
+		t.Run(tt.file, func(t *testing.T) {
+			checkNoLeaks(t)
+			replay := loadFixture(t, tt.file)
+
+			window := replay.Window()
+			defer window.Release()
+			focused := replay.Focused()
+			defer focused.Release()
+
+			captured := Capture(window, focused, DefaultCaptureOptions())
+			want, _ := json.Marshal(replay.Snapshot().Window)
+			got, _ := json.Marshal(captured)
+			if string(got) != string(want) {
+				t.Errorf("captured tree differs from the recording\n got: %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCaptureBudget(t *testing.T) {
+	checkNoLeaks(t)
+	replay := loadFixture(t, "slack_channel.json")
+	window := replay.Window()
+	defer window.Release()
+
+	captured := Capture(window, nil, CaptureOptions{MaxDepth: 40, MaxNodes: 5})
+	count := 0
+	captured.Walk(func(*Node) bool { count++; return true })
+	if count != 5 {
+		t.Errorf("captured %d nodes, want 5", count)
+	}
+
+	shallow := Capture(window, nil, CaptureOptions{MaxDepth: 1, MaxNodes: 3000})
+	for _, child := range shallow.Children {
+		if len(child.Children) != 0 {
+			t.Fatalf("depth 1 capture has grandchildren under %s", child.Role)
+		}
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"future version", `{"version": 2, "window": {"role": "AXWindow"}}`},
+		{"no window", `{"version": 1}`},
+		{"malformed", `{"version": 1, "window": [`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadSnapshot(strings.NewReader(tt.json)); err == nil {
+				t.Error("ReadSnapshot succeeded")
+			}
+		})
+	}
+}
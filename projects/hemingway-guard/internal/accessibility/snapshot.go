@@ -0,0 +1,168 @@
+package accessibility
+
+import "strings"
+
+// Node is a plain-Go copy of one element in an AX tree. Snapshots let
+// per-app logic run without live accessibility access.
+type Node struct {
+	Role        string `json:"role"`
+	Subrole     string `json:"subrole,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Description string `json:"description,omitempty"`
+	Identifier  string `json:"identifier,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	Editable    bool   `json:"editable,omitempty"`
+	Focused     bool   `json:"focused,omitempty"`
+
+	Frame         *Rect  `json:"frame,omitempty"`
+	SelectedRange *Range `json:"selected_range,omitempty"`
+	CaretBounds   *Rect  `json:"caret_bounds,omitempty"`
+
+	Children []*Node `json:"children,omitempty"`
+
+	parent *Node
+}
+
+// CaptureOptions bounds how much of a live tree Capture walks.
+// Chat apps can expose thousands of elements per window.
+type CaptureOptions struct {
+	MaxDepth int
+	MaxNodes int
+}
+
+// DefaultCaptureOptions returns limits suitable for a single chat window.
+func DefaultCaptureOptions() CaptureOptions {
+	return CaptureOptions{MaxDepth: 40, MaxNodes: 3000}
+}
+
+// Capture copies the subtree rooted at root into Nodes. The node matching
+// focused, if any, is marked Focused.
+func Capture(root, focused *Element, opts CaptureOptions) *Node {
+	budget := opts.MaxNodes
+	node := capture(root, focused, opts.MaxDepth, &budget)
+	if node != nil {
+		node.link(nil)
+	}
+	return node
+}
+
+func capture(e, focused *Element, depth int, budget *int) *Node {
+	if *budget <= 0 {
+		return nil
+	}
+	*budget--
+
+	role, subrole := e.Role(), e.Subrole()
+	node := &Node{
+		Role:        role,
+		Subrole:     subrole,
+		Title:       e.Title(),
+		Description: e.Description(),
+		Identifier:  e.Identifier(),
+		Placeholder: e.Placeholder(),
+		Editable:    e.IsEditable(),
+		Focused:     focused != nil && e.Equal(focused),
+	}
+	if !isSecure(role, subrole) {
+		node.Value = e.stringAttribute("AXValue")
+	}
+	if frame, ok := e.Frame(); ok {
+		node.Frame = &frame
+	}
+	if selected, ok := e.SelectedTextRange(); ok {
+		node.SelectedRange = &selected
+		if bounds, ok := e.BoundsForRange(selected); ok {
+			node.CaretBounds = &bounds
+		}
+	}
+
+	if depth <= 0 {
+		return node
+	}
+
+	for _, child := range e.Children() {
+		if n := capture(child, focused, depth-1, budget); n != nil {
+			node.Children = append(node.Children, n)
+		}
+		child.Release()
+	}
+	return node
+}
+
+// link sets parent pointers throughout the subtree.
+func (n *Node) link(parent *Node) {
+	n.parent = parent
+	for _, child := range n.Children {
+		child.link(n)
+	}
+}
+
+// Parent returns the node's parent, or nil for the root.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Walk visits the subtree in document order. Returning false from fn skips
+// the node's children.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// FindFocused returns the focused node in the subtree, or nil.
+func (n *Node) FindFocused() *Node {
+	var found *Node
+	n.Walk(func(node *Node) bool {
+		if found != nil {
+			return false
+		}
+		if node.Focused {
+			found = node
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// HasAncestor reports whether any ancestor of n satisfies fn.
+func (n *Node) HasAncestor(fn func(*Node) bool) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if fn(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the visible text of the node itself: its value for text
+// roles, otherwise its title or description.
+func (n *Node) Text() string {
+	switch {
+	case n.Value != "" && (n.Role == "AXStaticText" || n.Role == "AXTextArea" || n.Role == "AXTextField"):
+		return strings.TrimSpace(n.Value)
+	case n.Title != "":
+		return strings.TrimSpace(n.Title)
+	default:
+		return strings.TrimSpace(n.Description)
+	}
+}
+
+// JoinedText concatenates the text of every static text leaf in the subtree.
+func (n *Node) JoinedText() string {
+	var parts []string
+	n.Walk(func(node *Node) bool {
+		if node.Role == "AXStaticText" {
+			if text := node.Text(); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return true
+	})
+	return strings.Join(parts, " ")
+}
@@ -4,11 +4,18 @@ package analyzer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
+	"github.com/lancekrogers/hemingway-guard/internal/textutil"
 )
 
-// Analysis represents the result of Hemingway analysis on a message.
+// Analysis represents the result of Hemingway analysis on a message. It is
+// sent to the popover as-is, so its JSON shape is part of the IPC protocol
+// (internal/ipc/schema/protocol.schema.json).
 type Analysis struct {
 	Approved        bool     `json:"approved"`
 	WordCount       int      `json:"word_count"`
@@ -16,26 +23,73 @@ type Analysis struct {
 	GradeLevel      float64  `json:"grade_level"`
 	Issues          []string `json:"issues"`
 	Suggestion      string   `json:"suggestion"`
+
+	// Blocked means a finding, such as a pasted credential, stops the
+	// message from being sent without an explicit override.
+	Blocked bool `json:"blocked,omitempty"`
+
+	// Findings from local rules, with their locations. Issues repeats
+	// their messages.
+	Findings []Finding `json:"findings,omitempty"`
 }
 
 // AppContext provides context about where the message is being sent.
 type AppContext struct {
-	AppName   string // e.g., "Slack", "Discord", "iMessage"
+	AppName     string // e.g., "Slack", "Discord", "iMessage"
 	ChannelType string // e.g., "DM", "channel", "group"
+
+	// Surrounding conversation, when the accessibility layer can read it
+	ConversationTitle string   // e.g., "#launch-planning" or a contact name
+	RecentMessages    []string // last few visible messages, oldest first
+	InThread          bool     // replying inside a thread rather than the main channel
+}
+
+// Provider sends a prompt to a language model and returns its reply.
+type Provider interface {
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, prompt string) (string, error)
+
+// Complete calls f.
+func (f ProviderFunc) Complete(ctx context.Context, prompt string) (string, error) {
+	return f(ctx, prompt)
 }
 
 // Analyzer performs Hemingway-style text analysis.
 type Analyzer struct {
-	// Client will be claude-code-go client when integrated
-	// For now, we use a placeholder interface
+	// provider will be a claude-code-go client when integrated. Without
+	// one, only the local rules run.
+	provider Provider
+
+	rules []Rule
 }
 
-// NewAnalyzer creates a new Hemingway analyzer.
+// NewAnalyzer creates a new Hemingway analyzer with the default rules.
 func NewAnalyzer() *Analyzer {
-	return &Analyzer{}
+	return &Analyzer{rules: DefaultRules()}
+}
+
+// SetRules replaces the local rules.
+func (a *Analyzer) SetRules(rules []Rule) {
+	a.rules = rules
 }
 
-// Analyze performs Hemingway analysis on the given text.
+// SetProvider sets the language model that reviews messages. Secrets and
+// personal data are redacted from everything sent to it.
+func (a *Analyzer) SetProvider(p Provider) {
+	a.provider = p
+}
+
+// Rules returns the local rules, in the order they run.
+func (a *Analyzer) Rules() []Rule {
+	return append([]Rule(nil), a.rules...)
+}
+
+// Analyze performs Hemingway analysis on the given text. If the provider
+// fails, Analyze returns the error along with the local rules' analysis,
+// so a caller can still hold a message they block.
 func (a *Analyzer) Analyze(ctx context.Context, text string, appCtx AppContext) (*Analysis, error) {
 	if strings.TrimSpace(text) == "" {
 		return &Analysis{
@@ -45,12 +99,108 @@ func (a *Analyzer) Analyze(ctx context.Context, text string, appCtx AppContext)
 		}, nil
 	}
 
-	// TODO: Integrate with claude-code-go
-	// prompt := buildPrompt(text, appCtx)
-	_ = buildPrompt // silence unused warning until LLM integration
+	start := time.Now()
+	provider := "model"
+	var analysis *Analysis
+	var err error
+	if a.provider == nil {
+		provider = "rules"
+		analysis, err = a.mockAnalysis(text, appCtx)
+	} else {
+		analysis, err = a.providerAnalysis(ctx, text, appCtx)
+	}
+	observeAnalysis(provider, time.Since(start), err)
+	return analysis, err
+}
+
+// observeAnalysis records an analysis in the daemon's metrics.
+func observeAnalysis(provider string, took time.Duration, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result = "timeout"
+	case err != nil:
+		result = "error"
+	}
+	metrics.Analyses.With(provider, result).Inc()
+	metrics.AnalysisSeconds.Observe(took.Seconds())
+}
+
+// providerAnalysis asks the provider to review a redacted copy of the
+// message and its context, then restores the redacted values in its
+// suggestion. Local rules run on the original text, whether or not the
+// provider answers.
+func (a *Analyzer) providerAnalysis(ctx context.Context, text string, appCtx AppContext) (*Analysis, error) {
+	redactor := NewRedactor()
+	prompt := buildPrompt(redactText(redactor, text), redactContext(redactor, appCtx))
+	if redactor.Count() > 0 {
+		prompt += redactionNote
+	}
+
+	reply, err := a.provider.Complete(ctx, prompt)
+	if err != nil {
+		local, _ := a.mockAnalysis(text, appCtx)
+		return local, fmt.Errorf("provider failed: %w", err)
+	}
+	analysis, err := ParseAnalysis(reply)
+	if err != nil {
+		local, _ := a.mockAnalysis(text, appCtx)
+		return local, err
+	}
+	analysis.Suggestion = redactor.Restore(analysis.Suggestion)
+	for i, issue := range analysis.Issues {
+		analysis.Issues[i] = redactor.Restore(issue)
+	}
+	if analysis.Issues == nil {
+		analysis.Issues = []string{}
+	}
+
+	// Blocking and findings are decided locally, never by the model
+	analysis.Blocked = false
+	analysis.Findings = nil
+	analysis.addFindings(RunRules(a.rules, text, appCtx))
+	return analysis, nil
+}
 
-	// For now, return a mock analysis
-	return a.mockAnalysis(text)
+// addFindings records local rule findings: their messages become issues,
+// errors reject the message and blocking findings block it.
+func (a *Analysis) addFindings(findings []Finding) {
+	for _, f := range findings {
+		a.Issues = append(a.Issues, f.Message)
+		if f.Severity >= SeverityError {
+			a.Approved = false
+		}
+		if f.Severity >= SeverityBlock {
+			a.Blocked = true
+		}
+		a.Findings = append(a.Findings, f)
+	}
+}
+
+// redactionNote tells the model to leave placeholders alone, so they can
+// be restored in its suggestion.
+const redactionNote = `
+- Values like [EMAIL_1], [SECRET_1] or [CODE_1] were redacted; keep them exactly as written in the suggestion`
+
+// redactText prepares text for the prompt. Chat markup is held back first,
+// so code and links come back exactly as written; normalizing before
+// redaction means a zero-width character can't hide a secret.
+func redactText(redactor *Redactor, text string) string {
+	return redactor.Redact(textutil.Normalize(redactor.Protect(text)))
+}
+
+// redactContext returns appCtx with the conversation redacted, since it is
+// sent along with the message.
+func redactContext(redactor *Redactor, appCtx AppContext) AppContext {
+	appCtx.ConversationTitle = redactText(redactor, appCtx.ConversationTitle)
+	if len(appCtx.RecentMessages) > 0 {
+		recent := make([]string, len(appCtx.RecentMessages))
+		for i, msg := range appCtx.RecentMessages {
+			recent[i] = redactText(redactor, msg)
+		}
+		appCtx.RecentMessages = recent
+	}
+	return appCtx
 }
 
 func buildPrompt(text string, appCtx AppContext) string {
@@ -62,7 +212,7 @@ func buildPrompt(text string, appCtx AppContext) string {
 	return fmt.Sprintf(`Analyze this message for %s:
 
 "%s"
-
+%s
 Apply the Hemingway method: check for conciseness, clarity, and readability.
 
 Return JSON only:
@@ -80,34 +230,42 @@ Guidelines:
 - Flag overly long messages (>100 words for DMs, >200 for channels)
 - Flag passive voice, jargon, or unclear phrasing
 - Flag messages that could be misinterpreted
-- Suggest a more concise version if there are issues`, contextDesc, text)
+- Suggest a more concise version if there are issues
+- Judge tone and relevance against the conversation, if provided`, contextDesc, text, conversationPrompt(appCtx))
 }
 
-// mockAnalysis provides a simple local analysis without LLM.
-// This will be replaced with claude-code-go integration.
-func (a *Analyzer) mockAnalysis(text string) (*Analysis, error) {
-	words := strings.Fields(text)
-	wordCount := len(words)
-
-	// Simple heuristics for mock analysis
-	issues := []string{}
-	approved := true
-
-	// Check length
-	if wordCount > 100 {
-		issues = append(issues, "message is quite long")
-		approved = false
+// conversationPrompt describes the surrounding conversation, or returns an
+// empty string when nothing is known about it.
+func conversationPrompt(appCtx AppContext) string {
+	if appCtx.ConversationTitle == "" && len(appCtx.RecentMessages) == 0 && !appCtx.InThread {
+		return ""
 	}
 
-	// Check for passive voice indicators (very basic)
-	passiveIndicators := []string{"was", "were", "been", "being", "is being", "are being"}
-	lowerText := strings.ToLower(text)
-	for _, indicator := range passiveIndicators {
-		if strings.Contains(lowerText, " "+indicator+" ") {
-			issues = append(issues, "possible passive voice detected")
-			break
+	var b strings.Builder
+	b.WriteString("\nConversation context:\n")
+	if appCtx.ConversationTitle != "" {
+		fmt.Fprintf(&b, "- Conversation: %s\n", appCtx.ConversationTitle)
+	}
+	if appCtx.InThread {
+		b.WriteString("- The user is replying in a thread\n")
+	}
+	if len(appCtx.RecentMessages) > 0 {
+		b.WriteString("- Recent messages above the reply, oldest first:\n")
+		for _, msg := range appCtx.RecentMessages {
+			fmt.Fprintf(&b, "  > %s\n", msg)
 		}
 	}
+	return b.String()
+}
+
+// suggestionWords is how much of a long message the local suggestion keeps.
+const suggestionWords = 50
+
+// mockAnalysis provides a simple local analysis without LLM, using the
+// analyzer's rules. It is used until a provider is set.
+func (a *Analyzer) mockAnalysis(text string, appCtx AppContext) (*Analysis, error) {
+	prose := proseText(text, appCtx)
+	wordCount := textutil.CountWords(prose)
 
 	// Estimate reading time (average 200 wpm)
 	readTime := (wordCount * 60) / 200
@@ -121,20 +279,24 @@ func (a *Analyzer) mockAnalysis(text string) (*Analysis, error) {
 		gradeLevel = 12
 	}
 
-	suggestion := ""
-	if !approved && wordCount > 100 {
-		// Truncate as a simple "suggestion"
-		suggestion = strings.Join(words[:50], " ") + "..."
-	}
-
-	return &Analysis{
-		Approved:        approved,
+	analysis := &Analysis{
+		Approved:        true,
 		WordCount:       wordCount,
 		ReadTimeSeconds: readTime,
 		GradeLevel:      gradeLevel,
-		Issues:          issues,
-		Suggestion:      suggestion,
-	}, nil
+		Issues:          []string{},
+	}
+	analysis.addFindings(RunRules(a.rules, text, appCtx))
+
+	for _, f := range analysis.Findings {
+		if f.Rule == "chat/length" && !analysis.Approved {
+			// Truncate as a simple "suggestion", keeping line breaks,
+			// code and links as written
+			analysis.Suggestion = textutil.TruncateChatWords(text, suggestionWords)
+			break
+		}
+	}
+	return analysis, nil
 }
 
 // ParseAnalysis parses JSON response from the LLM into an Analysis struct.
@@ -0,0 +1,62 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	frontMatter = regexp.MustCompile(`\A(?:---\r?\n(?s:.*?)\r?\n---|\+\+\+\r?\n(?s:.*?)\r?\n\+\+\+)(?:\r?\n|\z)`)
+	fence       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	inlineCode  = regexp.MustCompile("`[^`\n]+`")
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// MaskMarkdown blanks out the parts of a Markdown document that aren't
+// prose: front matter, fenced code blocks, inline code and HTML comments.
+// Masked bytes become spaces and line endings are kept, whether LF or CRLF,
+// so finding offsets still point into the original document.
+func MaskMarkdown(text string) string {
+	b := []byte(text)
+
+	mask := func(start, end int) {
+		for i := start; i < end; i++ {
+			if b[i] != '\n' && b[i] != '\r' {
+				b[i] = ' '
+			}
+		}
+	}
+
+	if loc := frontMatter.FindStringIndex(text); loc != nil {
+		mask(loc[0], loc[1])
+	}
+
+	// Fenced code blocks run to a closing fence of the same kind, or to
+	// the end of the document
+	offset := 0
+	var open string
+	openAt := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if m := fence.FindStringSubmatch(line); m != nil {
+			switch {
+			case open == "":
+				open, openAt = m[1], offset
+			case m[1][0] == open[0] && len(m[1]) >= len(open) &&
+				strings.TrimSpace(line[len(m[0]):]) == "":
+				mask(openAt, offset+len(line))
+				open = ""
+			}
+		}
+		offset += len(line)
+	}
+	if open != "" {
+		mask(openAt, len(text))
+	}
+
+	for _, re := range []*regexp.Regexp{htmlComment, inlineCode} {
+		for _, loc := range re.FindAllIndex(b, -1) {
+			mask(loc[0], loc[1])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"prose", "Plain prose.\n", "Plain prose.\n"},
+		{"front matter", "---\ntitle: x\n---\nBody.", "   \n        \n   \nBody."},
+		{"toml front matter", "+++\na = 1\n+++\nBody.", "   \n     \n   \nBody."},
+		{"fence", "Before.\n```go\nx := 1\n```\nAfter.", "Before.\n     \n      \n   \nAfter."},
+		{"tilde fence", "~~~\ncode\n~~~\nAfter.", "   \n    \n   \nAfter."},
+		{"closing fence must match", "```\n~~~\ncode\n```\nAfter.", "   \n   \n    \n   \nAfter."},
+		{"unclosed fence", "Before.\n```\ncode", "Before.\n   \n    "},
+		{"inline code", "Run `make test` now.", "Run             now."},
+		{"html comment", "A<!-- note\nhere -->B", "A         \n        B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskMarkdown(tt.in); got != tt.want {
+				t.Errorf("MaskMarkdown(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// CRLF documents keep their line endings, so every offset is unchanged.
+func TestMaskMarkdownCRLF(t *testing.T) {
+	lf := "---\ntitle: x\n---\nIntro was written.\n```\ncode was here\n```\nSee `x` here.\n"
+	crlf := strings.ReplaceAll(lf, "\n", "\r\n")
+
+	got := MaskMarkdown(crlf)
+	if want := strings.ReplaceAll(MaskMarkdown(lf), "\n", "\r\n"); got != want {
+		t.Errorf("MaskMarkdown(CRLF)\n got %q\nwant %q", got, want)
+	}
+	if len(got) != len(crlf) {
+		t.Fatalf("masked length %d, want %d", len(got), len(crlf))
+	}
+
+	// A finding in the masked text points at the same word in the original
+	start := strings.Index(got, "was written")
+	if start < 0 || crlf[start:start+len("was written")] != "was written" {
+		t.Errorf("offset %d doesn't point into the original", start)
+	}
+	if strings.Contains(got, "code was here") {
+		t.Error("fence in a CRLF document wasn't masked")
+	}
+}
@@ -0,0 +1,188 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lancekrogers/hemingway-guard/internal/textutil"
+)
+
+// Kinds of redacted values, used in placeholders such as "[EMAIL_1]".
+const (
+	RedactPrivateKey = "PRIVATE_KEY"
+	RedactSecret     = "SECRET"
+	RedactEmail      = "EMAIL"
+	RedactCard       = "CARD"
+	RedactPhone      = "PHONE"
+
+	// Chat markup held back so it survives a rewrite verbatim
+	RedactCode    = "CODE"
+	RedactLink    = "LINK"
+	RedactMention = "MENTION"
+	RedactEmoji   = "EMOJI"
+)
+
+// protectedKinds maps chat tokens to the placeholders Protect uses.
+var protectedKinds = map[textutil.TokenKind]string{
+	textutil.Code:      RedactCode,
+	textutil.URL:       RedactLink,
+	textutil.Mention:   RedactMention,
+	textutil.Shortcode: RedactEmoji,
+}
+
+// detector finds one kind of personal data. Valid, if set, filters
+// matches the pattern alone can't tell apart, such as card numbers that
+// fail the Luhn check.
+type detector struct {
+	kind    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// detectors run in order after credentials are redacted, each on the
+// output of the last.
+var detectors = []detector{
+	{kind: RedactEmail, pattern: regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)},
+	{kind: RedactCard, pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhnValid},
+	// International numbers with a "+", or North American ones with separators
+	{kind: RedactPhone, pattern: regexp.MustCompile(`\+\d{1,3}(?:[ .-]?\(?\d{1,4}\)?){2,5}\b|(?:\(\d{3}\) ?|\b\d{3}[ .-]?)\d{3}[ .-]\d{4}\b`), valid: plausiblePhone},
+	// Random-looking tokens inside links, which FindSecrets leaves alone
+	// but which may still be credentials, e.g. in a query string
+	{kind: RedactSecret, pattern: regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`), valid: highEntropy},
+}
+
+// Redactor replaces secrets and personal data with placeholders before
+// text leaves the machine, and puts the originals back in what comes
+// back. A value gets the same placeholder every time it's seen, so one
+// Redactor should cover all the text sent in a single request.
+type Redactor struct {
+	placeholders map[string]string // original -> placeholder
+	originals    map[string]string // placeholder -> original
+	counts       map[string]int    // by kind
+}
+
+// NewRedactor creates a redactor with no values seen yet.
+func NewRedactor() *Redactor {
+	return &Redactor{
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// Redact returns text with each sensitive value replaced by a placeholder
+// such as "[EMAIL_1]". Credentials are those FindSecrets reports.
+func (r *Redactor) Redact(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range FindSecrets(text) {
+		kind := RedactSecret
+		if m.Name == privateKeyName {
+			kind = RedactPrivateKey
+		}
+		b.WriteString(text[last:m.Span.Start])
+		b.WriteString(r.placeholder(kind, text[m.Span.Start:m.Span.End]))
+		last = m.Span.End
+	}
+	b.WriteString(text[last:])
+	text = b.String()
+
+	for _, d := range detectors {
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			return r.placeholder(d.kind, match)
+		})
+	}
+	return text
+}
+
+// Protect returns text with code, links, mentions and emoji shortcodes
+// replaced by placeholders such as "[CODE_1]", so Restore puts them back
+// exactly as written however the rest is rewritten. Call it before Redact:
+// secrets inside held-back markup are never sent at all.
+func (r *Redactor) Protect(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range textutil.TokenizeChat(text) {
+		kind, ok := protectedKinds[t.Kind]
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:t.Start])
+		b.WriteString(r.placeholder(kind, t.Text))
+		last = t.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (r *Redactor) placeholder(kind, original string) string {
+	if p, ok := r.placeholders[original]; ok {
+		return p
+	}
+	r.counts[kind]++
+	p := fmt.Sprintf("[%s_%d]", kind, r.counts[kind])
+	r.placeholders[original] = p
+	r.originals[p] = original
+	return p
+}
+
+// placeholderPattern matches placeholders this package produces.
+var placeholderPattern = regexp.MustCompile(`\[[A-Z_]+_\d+\]`)
+
+// Restore replaces the placeholders in text with their original values.
+// Unknown placeholders are left alone.
+func (r *Redactor) Restore(text string) string {
+	if len(r.originals) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+		if original, ok := r.originals[p]; ok {
+			return original
+		}
+		return p
+	})
+}
+
+// Count returns how many distinct values have been redacted.
+func (r *Redactor) Count() int {
+	return len(r.originals)
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum that
+// every payment card number carries.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// plausiblePhone rejects matches that are more likely dates, times or
+// plain numbers: a phone number has 10 to 15 digits and at least one
+// separator or a leading "+".
+func plausiblePhone(s string) bool {
+	digits := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	return digits >= 10 && digits <= 15 && strings.ContainsAny(s, "+() .-")
+}
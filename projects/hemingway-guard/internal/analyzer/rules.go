@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/lancekrogers/hemingway-guard/internal/textutil"
+)
+
+// Apps with rules of their own. Pass them as AppContext.AppName.
+const (
+	AppGit      = "git"      // commit messages
+	AppMarkdown = "markdown" // documents and PR descriptions
+)
+
+// Severity ranks findings. Any SeverityError finding rejects the message;
+// any SeverityBlock finding also blocks it, so it is only sent when the
+// user explicitly overrides.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityBlock
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the severity by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for _, sev := range []Severity{SeverityInfo, SeverityWarning, SeverityError, SeverityBlock} {
+		if string(text) == sev.String() {
+			*s = sev
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Span is a byte range in the analyzed text.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Finding is one problem reported by a rule.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Span     *Span    `json:"span,omitempty"` // nil when the whole message is at fault
+	// Replacement, if set, is text that fixes the finding when it replaces
+	// Span. Only findings with a Span carry one.
+	Replacement *string `json:"replacement,omitempty"`
+}
+
+// Rule is a local check run on every message it applies to.
+type Rule struct {
+	ID string
+	// Applies reports whether the rule runs for appCtx. Nil means always.
+	Applies func(appCtx AppContext) bool
+	// Check finds problems. In chat messages it sees code, links,
+	// mentions and emoji shortcodes blanked out, unless Verbatim is set.
+	Check    func(text string) []Finding
+	Verbatim bool
+}
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() []Rule {
+	return append([]Rule{
+		{ID: "security/secret", Check: checkSecrets, Verbatim: true},
+		{ID: "chat/length", Applies: isChat, Check: checkLength},
+		{ID: "prose/long-sentence", Applies: isDocument, Check: checkLongSentences},
+		{ID: "style/passive", Check: checkPassive},
+	}, gitRules()...)
+}
+
+// RunRules runs the rules that apply to appCtx, in order.
+func RunRules(rules []Rule, text string, appCtx AppContext) []Finding {
+	prose := proseText(text, appCtx)
+	var findings []Finding
+	for _, rule := range rules {
+		if rule.Applies != nil && !rule.Applies(appCtx) {
+			continue
+		}
+		input := prose
+		if rule.Verbatim {
+			input = text
+		}
+		for _, f := range rule.Check(input) {
+			if f.Rule == "" {
+				f.Rule = rule.ID
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// proseText returns what style rules should read: for chat messages, the
+// text with code, links, mentions and shortcodes masked. Offsets are kept.
+func proseText(text string, appCtx AppContext) string {
+	if isChat(appCtx) {
+		return textutil.MaskChat(text)
+	}
+	return text
+}
+
+func isChat(appCtx AppContext) bool {
+	return appCtx.AppName != AppGit && appCtx.AppName != AppMarkdown
+}
+
+func isDocument(appCtx AppContext) bool {
+	return appCtx.AppName == AppMarkdown
+}
+
+// chatWordLimit is where a chat message becomes too long to read at a glance.
+const chatWordLimit = 100
+
+func checkLength(text string) []Finding {
+	if textutil.CountWords(text) <= chatWordLimit {
+		return nil
+	}
+	return []Finding{{Severity: SeverityError, Message: "message is quite long"}}
+}
+
+// passiveIndicator matches auxiliaries that often start a passive
+// construction. It's a rough signal, so only the first is reported.
+var passiveIndicator = regexp.MustCompile(`(?i)\b(?:is being|are being|was|were|been|being)\b`)
+
+func checkPassive(text string) []Finding {
+	loc := passiveIndicator.FindStringIndex(text)
+	if loc == nil {
+		return nil
+	}
+	return []Finding{{
+		Severity: SeverityWarning,
+		Message:  "possible passive voice detected",
+		Span:     &Span{Start: loc[0], End: loc[1]},
+	}}
+}
+
+// sentenceWordLimit is the longest sentence a document should carry.
+const sentenceWordLimit = 35
+
+var sentenceEnd = regexp.MustCompile(`[.!?](?:\s|$)|\n\s*\n`)
+
+func checkLongSentences(text string) []Finding {
+	var findings []Finding
+	start := 0
+	check := func(end int) {
+		if textutil.CountWords(text[start:end]) > sentenceWordLimit {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Message:  "sentence is over 35 words; consider splitting it",
+				Span:     trimSpan(text, start, end),
+			})
+		}
+	}
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		check(loc[0] + 1)
+		start = loc[1]
+	}
+	if start < len(text) {
+		check(len(text))
+	}
+	return findings
+}
+
+// trimSpan shrinks [start, end) to exclude surrounding whitespace.
+func trimSpan(text string, start, end int) *Span {
+	for start < end && isSpace(text[start]) {
+		start++
+	}
+	for end > start && isSpace(text[end-1]) {
+		end--
+	}
+	return &Span{Start: start, End: end}
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
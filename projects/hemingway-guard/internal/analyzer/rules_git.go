@@ -0,0 +1,256 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Commit message limits, following the usual git conventions.
+const (
+	subjectSoftLimit = 50
+	subjectHardLimit = 72
+	bodyWrapLimit    = 72
+)
+
+func gitRules() []Rule {
+	isGit := func(appCtx AppContext) bool { return appCtx.AppName == AppGit }
+	return []Rule{
+		{ID: "git/subject-length", Applies: isGit, Check: checkSubjectLength},
+		{ID: "git/subject-period", Applies: isGit, Check: checkSubjectPeriod},
+		{ID: "git/imperative", Applies: isGit, Check: checkImperative},
+		{ID: "git/blank-line", Applies: isGit, Check: checkBlankLine},
+		{ID: "git/body-wrap", Applies: isGit, Check: checkBodyWrap},
+	}
+}
+
+// scissors is the line below which git drops everything in verbose
+// commits, after the comment character.
+const scissors = " ------------------------ >8 ------------------------"
+
+// autoCommentChars are the characters git picks from, in order, when
+// core.commentChar is "auto".
+const autoCommentChars = "#;@!$%^&|:"
+
+// commentPrefix resolves git's core.commentChar setting for msg. Empty means
+// the default "#". For "auto", git picked the first candidate that no line
+// of the message started with and used it for every comment, so that is
+// the first candidate that starts a line the way comments do: alone or
+// followed by a space.
+func commentPrefix(setting, msg string) string {
+	switch setting {
+	case "":
+		return "#"
+	case "auto":
+		for _, c := range autoCommentChars {
+			prefix := string(c)
+			for _, line := range strings.Split(msg, "\n") {
+				line = strings.TrimSuffix(line, "\r")
+				if line == prefix || strings.HasPrefix(line, prefix+" ") {
+					return prefix
+				}
+			}
+		}
+		return "#"
+	}
+	return setting
+}
+
+// CleanCommitMessage strips what git itself strips before committing:
+// comment lines, everything below the scissors line, and trailing blank
+// lines. commentChar is git's core.commentChar setting; empty means "#".
+func CleanCommitMessage(msg, commentChar string) string {
+	prefix := commentPrefix(commentChar, msg)
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == prefix+scissors {
+			break
+		}
+		if strings.HasPrefix(line, prefix) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// MaskCommitMessage blanks what CleanCommitMessage would strip, replacing
+// it with spaces so offsets into the result are offsets into msg. Use it
+// when findings must point into the text as the user sees it, as in an
+// editor. Line endings are kept as they are.
+func MaskCommitMessage(msg, commentChar string) string {
+	prefix := commentPrefix(commentChar, msg)
+	var b strings.Builder
+	b.Grow(len(msg))
+	cut := false
+	for _, line := range strings.SplitAfter(msg, "\n") {
+		content := strings.TrimRight(line, "\r\n")
+		cut = cut || content == prefix+scissors
+		if !cut && !strings.HasPrefix(content, prefix) {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", len(content)))
+		b.WriteString(line[len(content):])
+	}
+	return b.String()
+}
+
+// subjectLine returns the first line, without its line ending, and its end
+// offset.
+func subjectLine(text string) (string, int) {
+	end := strings.IndexByte(text, '\n')
+	if end < 0 {
+		end = len(text)
+	}
+	subject := strings.TrimSuffix(text[:end], "\r")
+	return subject, len(subject)
+}
+
+func checkSubjectLength(text string) []Finding {
+	subject, _ := subjectLine(text)
+	n := len([]rune(subject))
+	switch {
+	case n > subjectHardLimit:
+		return []Finding{{
+			Severity: SeverityError,
+			Message:  "subject is over 72 characters",
+			Span:     &Span{Start: runeOffset(subject, subjectHardLimit), End: len(subject)},
+		}}
+	case n > subjectSoftLimit:
+		return []Finding{{
+			Severity: SeverityInfo,
+			Message:  "subject is over 50 characters",
+			Span:     &Span{Start: runeOffset(subject, subjectSoftLimit), End: len(subject)},
+		}}
+	}
+	return nil
+}
+
+func checkSubjectPeriod(text string) []Finding {
+	subject, end := subjectLine(text)
+	if !strings.HasSuffix(subject, ".") || strings.HasSuffix(subject, "...") {
+		return nil
+	}
+	return []Finding{{
+		Severity:    SeverityWarning,
+		Message:     "subject ends with a period",
+		Span:        &Span{Start: end - 1, End: end},
+		Replacement: new(string),
+	}}
+}
+
+// subjectPrefix matches prefixes before the summary proper:
+// "[ticket-12] ", "fix(parser): ", "ui: ".
+var subjectPrefix = regexp.MustCompile(`^(?:\[[^\]]*\]\s*|[\w./-]+(?:\([^)]*\))?!?:\s+)*`)
+
+// imperativeVerbs are common commit verbs. Their other forms ("Added",
+// "Fixes", "Updating") are flagged.
+var imperativeVerbs = []string{
+	"add", "allow", "bump", "change", "clean", "create", "delete", "disable",
+	"document", "drop", "enable", "extract", "fix", "handle", "implement",
+	"improve", "introduce", "make", "merge", "move", "refactor", "remove",
+	"rename", "replace", "revert", "simplify", "split", "support", "test",
+	"update", "upgrade", "use",
+}
+
+var nonImperative = func() map[string]string {
+	forms := make(map[string]string)
+	for _, verb := range imperativeVerbs {
+		stem := strings.TrimSuffix(verb, "e")
+		for _, form := range []string{verb + "s", verb + "es", verb + "ed", stem + "ed", verb + "ing", stem + "ing"} {
+			if form != verb {
+				forms[form] = verb
+			}
+		}
+	}
+	for form, verb := range map[string]string{
+		"dropped": "drop", "dropping": "drop", "made": "make", "splitting": "split",
+	} {
+		forms[form] = verb
+	}
+	return forms
+}()
+
+func checkImperative(text string) []Finding {
+	subject, _ := subjectLine(text)
+	start := len(subjectPrefix.FindString(subject))
+	rest := subject[start:]
+	word := rest
+	if i := strings.IndexAny(rest, " :,"); i >= 0 {
+		word = rest[:i]
+	}
+
+	verb, ok := nonImperative[strings.ToLower(word)]
+	if !ok {
+		return nil
+	}
+	fix := verb
+	if word[0] >= 'A' && word[0] <= 'Z' {
+		fix = upperFirst(verb)
+	}
+	return []Finding{{
+		Severity:    SeverityWarning,
+		Message:     "use the imperative mood in the subject (\"" + upperFirst(verb) + "\", not \"" + word + "\")",
+		Span:        &Span{Start: start, End: start + len(word)},
+		Replacement: &fix,
+	}}
+}
+
+func checkBlankLine(text string) []Finding {
+	lines := strings.SplitN(text, "\n", 3)
+	if len(lines) < 2 || strings.TrimSpace(lines[1]) == "" {
+		return nil
+	}
+	start := len(lines[0]) + 1
+	return []Finding{{
+		Severity: SeverityError,
+		Message:  "separate the subject from the body with a blank line",
+		Span:     &Span{Start: start, End: start + len(strings.TrimSuffix(lines[1], "\r"))},
+	}}
+}
+
+// trailer matches "Signed-off-by: ..." style lines, which aren't wrapped.
+var trailer = regexp.MustCompile(`^[A-Za-z][\w-]*: `)
+
+func checkBodyWrap(text string) []Finding {
+	var findings []Finding
+	offset := 0
+	for i, line := range strings.Split(text, "\n") {
+		start := offset
+		offset += len(line) + 1
+		line = strings.TrimSuffix(line, "\r")
+		if i == 0 || len([]rune(line)) <= bodyWrapLimit {
+			continue
+		}
+		// Long URLs, indented code and trailers can't be wrapped
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") ||
+			strings.Contains(line, "://") || trailer.MatchString(line) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Message:  "wrap body lines at 72 characters",
+			Span:     &Span{Start: start + runeOffset(line, bodyWrapLimit), End: start + len(line)},
+		})
+	}
+	return findings
+}
+
+// runeOffset returns the byte offset of the n-th rune in s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,124 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func runGitRule(t *testing.T, id, text string) []Finding {
+	t.Helper()
+	for _, rule := range gitRules() {
+		if rule.ID == id {
+			return rule.Check(text)
+		}
+	}
+	t.Fatalf("no rule %s", id)
+	return nil
+}
+
+func TestGitRules(t *testing.T) {
+	long := strings.Repeat("x", 80)
+	tests := []struct {
+		rule     string
+		text     string
+		severity Severity
+		span     string // text the single finding covers; empty for none
+		fix      *string
+	}{
+		{"git/subject-length", "Add the thing", 0, "", nil},
+		{"git/subject-length", "Add " + strings.Repeat("a", 50), SeverityInfo, strings.Repeat("a", 4), nil},
+		{"git/subject-length", "Add " + strings.Repeat("a", 70), SeverityError, strings.Repeat("a", 2), nil},
+		{"git/subject-length", strings.Repeat("é", 50), 0, "", nil},
+		{"git/subject-period", "Add the thing.", SeverityWarning, ".", ptr("")},
+		{"git/subject-period", "Add the thing.\r\n\r\nBody.", SeverityWarning, ".", ptr("")},
+		{"git/subject-period", "Wait for it...", 0, "", nil},
+		{"git/imperative", "Added the thing", SeverityWarning, "Added", ptr("Add")},
+		{"git/imperative", "fix(parser): fixes the crash", SeverityWarning, "fixes", ptr("fix")},
+		{"git/imperative", "[user-12] Updating docs", SeverityWarning, "Updating", ptr("Update")},
+		{"git/imperative", "Fix the crash", 0, "", nil},
+		{"git/imperative", "Dropped support", SeverityWarning, "Dropped", ptr("Drop")},
+		{"git/blank-line", "Subject\nBody right away", SeverityError, "Body right away", nil},
+		{"git/blank-line", "Subject\r\nBody right away\r\n", SeverityError, "Body right away", nil},
+		{"git/blank-line", "Subject\n\nBody", 0, "", nil},
+		{"git/body-wrap", "Subject\n\n" + long, SeverityWarning, long[72:], nil},
+		{"git/body-wrap", "Subject\r\n\r\n" + strings.Repeat("y", 72) + "\r\n", 0, "", nil},
+		{"git/body-wrap", "Subject\n\n    " + long, 0, "", nil},
+		{"git/body-wrap", "Subject\n\nSee https://example.com/" + long, 0, "", nil},
+		{"git/body-wrap", "Subject\n\nCo-authored-by: " + long, 0, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.text[:min(len(tt.text), 20)], func(t *testing.T) {
+			findings := runGitRule(t, tt.rule, tt.text)
+			if tt.span == "" {
+				if len(findings) != 0 {
+					t.Fatalf("findings = %+v, want none", findings)
+				}
+				return
+			}
+			if len(findings) != 1 {
+				t.Fatalf("findings = %+v, want one", findings)
+			}
+			f := findings[0]
+			if f.Severity != tt.severity {
+				t.Errorf("severity = %v, want %v", f.Severity, tt.severity)
+			}
+			if got := tt.text[f.Span.Start:f.Span.End]; got != tt.span {
+				t.Errorf("span covers %q, want %q", got, tt.span)
+			}
+			if (f.Replacement == nil) != (tt.fix == nil) || (f.Replacement != nil && *f.Replacement != *tt.fix) {
+				t.Errorf("replacement = %v, want %v", f.Replacement, tt.fix)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestCleanCommitMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         string
+		commentChar string
+		want        string
+	}{
+		{"comments", "Fix it\n\nBody  \n# Please enter the commit message\n#\n", "", "Fix it\n\nBody"},
+		{"scissors", "Fix it\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n", "", "Fix it"},
+		{"CRLF", "Fix it\r\n\r\nBody\r\n# comment\r\n", "", "Fix it\n\nBody"},
+		{"comment char", "#123 Fix it\n\n; Please enter the commit message\n;\n", ";", "#123 Fix it"},
+		{"comment char scissors", "Fix it\n; ------------------------ >8 ------------------------\n# not a comment\n", ";", "Fix it"},
+		{"comment string", "Fix it\n// note\n", "//", "Fix it"},
+		{"auto", "#123 Fix it\n\n; Please enter the commit message\n;\n", "auto", "#123 Fix it"},
+		{"auto default", "Fix it\n# Please enter the commit message\n", "auto", "Fix it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanCommitMessage(tt.msg, tt.commentChar); got != tt.want {
+				t.Errorf("CleanCommitMessage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskCommitMessage(t *testing.T) {
+	msg := "Added it.\r\n\r\n; Please enter\r\n#123 stays\r\n; ------------------------ >8 ------------------------\r\ndiff\r\n"
+	got := MaskCommitMessage(msg, ";")
+	want := "Added it.\r\n\r\n              \r\n#123 stays\r\n" +
+		strings.Repeat(" ", 54) + "\r\n    \r\n"
+	if got != want {
+		t.Fatalf("MaskCommitMessage\n got %q\nwant %q", got, want)
+	}
+
+	// Findings on the masked text point into the original
+	a := NewAnalyzer()
+	for _, f := range RunRules(a.Rules(), got, AppContext{AppName: AppGit}) {
+		if f.Span == nil {
+			continue
+		}
+		if f.Rule == "git/subject-period" && msg[f.Span.Start:f.Span.End] != "." {
+			t.Errorf("period finding covers %q", msg[f.Span.Start:f.Span.End])
+		}
+		if f.Rule == "git/imperative" && msg[f.Span.Start:f.Span.End] != "Added" {
+			t.Errorf("imperative finding covers %q", msg[f.Span.Start:f.Span.End])
+		}
+	}
+}
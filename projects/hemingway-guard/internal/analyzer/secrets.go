@@ -0,0 +1,144 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// SecretPattern detects one kind of credential.
+type SecretPattern struct {
+	Name    string // what the credential is, e.g. "GitHub token"
+	Pattern *regexp.Regexp
+	// Valid, if set, rejects matches the pattern alone can't tell apart
+	// from harmless text.
+	Valid func(match string) bool
+	// InURLs reports whether to look inside URLs. Document and image IDs in
+	// links look random too, so the generic pattern skips them.
+	InURLs bool
+}
+
+const privateKeyName = "private key"
+
+// secretPatterns is the credential library, most specific first. A span
+// matched by one pattern isn't matched again by a later one.
+var secretPatterns = []SecretPattern{
+	{Name: privateKeyName, Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)-----END [A-Z ]*PRIVATE KEY-----`), InURLs: true},
+	{Name: "AWS access key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), InURLs: true},
+	{Name: "GitHub token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_\w{22,})`), InURLs: true},
+	{Name: "GitLab token", Pattern: regexp.MustCompile(`\bglpat-[\w-]{20,}`), InURLs: true},
+	{Name: "Slack token", Pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`), InURLs: true},
+	{Name: "Slack webhook", Pattern: regexp.MustCompile(`https://hooks\.slack\.com/services/[A-Za-z0-9/]{20,}`), InURLs: true},
+	{Name: "Stripe key", Pattern: regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}`), InURLs: true},
+	{Name: "API key", Pattern: regexp.MustCompile(`\bsk-(?:ant-|proj-)?[\w-]{20,}`), InURLs: true}, // Anthropic, OpenAI
+	{Name: "Google API key", Pattern: regexp.MustCompile(`\bAIza[\w-]{35}`), InURLs: true},
+	{Name: "JSON web token", Pattern: regexp.MustCompile(`\beyJ[\w-]+\.eyJ[\w-]+\.[\w-]*`), InURLs: true},
+	{Name: "high-entropy string", Pattern: regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`), Valid: highEntropy},
+}
+
+// SecretPatterns returns the credential library, most specific first.
+func SecretPatterns() []SecretPattern {
+	return append([]SecretPattern(nil), secretPatterns...)
+}
+
+// SecretMatch is a credential found in text.
+type SecretMatch struct {
+	Name string // the SecretPattern that matched
+	Span Span
+}
+
+// urlPattern finds links, whose paths the generic pattern skips.
+var urlPattern = regexp.MustCompile(`\bhttps?://\S+`)
+
+// FindSecrets returns the credentials in text, in order of appearance.
+func FindSecrets(text string) []SecretMatch {
+	urls := urlPattern.FindAllStringIndex(text, -1)
+	var matches []SecretMatch
+	overlaps := func(start, end int) bool {
+		for _, m := range matches {
+			if start < m.Span.End && m.Span.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+	inURL := func(start, end int) bool {
+		for _, u := range urls {
+			if start >= u[0] && end <= u[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, p := range secretPatterns {
+		for _, loc := range p.Pattern.FindAllStringIndex(text, -1) {
+			match := text[loc[0]:loc[1]]
+			if p.Valid != nil && !p.Valid(match) {
+				continue
+			}
+			if !p.InURLs && inURL(loc[0], loc[1]) {
+				continue
+			}
+			if overlaps(loc[0], loc[1]) {
+				continue
+			}
+			matches = append(matches, SecretMatch{Name: p.Name, Span: Span{Start: loc[0], End: loc[1]}})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Span.Start < matches[j].Span.Start })
+	return matches
+}
+
+// checkSecrets blocks messages carrying credentials: pasting one into a
+// chat leaks it to everyone in the channel, and to the app's servers.
+func checkSecrets(text string) []Finding {
+	var findings []Finding
+	for _, m := range FindSecrets(text) {
+		span := m.Span
+		findings = append(findings, Finding{
+			Severity: SeverityBlock,
+			Message:  fmt.Sprintf("possible %s; sending it needs an override", m.Name),
+			Span:     &span,
+		})
+	}
+	return findings
+}
+
+// hexish matches commit hashes, checksums and UUIDs, which are long and
+// random but not secret.
+var hexish = regexp.MustCompile(`^[0-9a-fA-F-]+$`)
+
+// highEntropy reports whether s looks randomly generated: mixed letters
+// and digits, and over 4 bits of entropy per character. Words, paths and
+// repeated characters fall well below that; hex strings are excluded
+// outright.
+func highEntropy(s string) bool {
+	if hexish.MatchString(s) {
+		return false
+	}
+
+	var letter, digit bool
+	freq := make(map[rune]int)
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letter = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+		freq[c]++
+	}
+	if !digit || !letter {
+		return false
+	}
+
+	entropy := 0.0
+	n := float64(len(s))
+	for _, count := range freq {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy > 4.0
+}
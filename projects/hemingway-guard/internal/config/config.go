@@ -0,0 +1,360 @@
+// Package config loads user settings shared by the menubar app and the
+// command line tools.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/history"
+	"github.com/lancekrogers/hemingway-guard/internal/httpapi"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+	"github.com/lancekrogers/hemingway-guard/pkg/apps"
+)
+
+// Config is the contents of config.json. Missing fields keep their
+// defaults.
+type Config struct {
+	Rules   RuleConfig    `json:"rules"`
+	API     APIConfig     `json:"api"`
+	Log     LogConfig     `json:"log"`
+	History HistoryConfig `json:"history"`
+	Metrics MetricsConfig `json:"metrics"`
+	// Schedule limits checking to these windows. Empty checks at all
+	// times.
+	Schedule []ScheduleRule `json:"schedule,omitempty"`
+	// Enforcement picks what happens to messages that aren't approved.
+	Enforcement EnforcementConfig `json:"enforcement"`
+}
+
+// RuleConfig adjusts the analyzer's built-in rules.
+type RuleConfig struct {
+	// Disabled lists rule IDs to skip, e.g. "style/passive".
+	Disabled []string `json:"disabled,omitempty"`
+	// Severity overrides the severity of a rule's findings by ID.
+	Severity map[string]analyzer.Severity `json:"severity,omitempty"`
+}
+
+// APIConfig enables the loopback HTTP API alongside the menubar app.
+type APIConfig struct {
+	// Addr is the loopback address to listen on. Empty leaves the API off.
+	Addr string `json:"addr,omitempty"`
+	// Token is the bearer token clients must send.
+	// $HEMINGWAY_GUARD_API_TOKEN overrides it.
+	Token string `json:"token,omitempty"`
+}
+
+// LogConfig controls the daemon's log file.
+type LogConfig struct {
+	// Level is debug, info (the default), warn or error.
+	Level string `json:"level,omitempty"`
+	// Privacy is how message text is logged: hash (the default) logs a
+	// keyed hash and length, omit only the length.
+	Privacy string `json:"privacy,omitempty"`
+	// MaxSizeMB and MaxBackups bound the rotating log file.
+	MaxSizeMB  int `json:"max_size_mb,omitempty"`
+	MaxBackups int `json:"max_backups,omitempty"`
+}
+
+// HistoryConfig controls the record of analyzed messages.
+type HistoryConfig struct {
+	// Disabled turns the history off.
+	Disabled bool `json:"disabled,omitempty"`
+	// StoreContent keeps message text in the history. Off by default.
+	StoreContent bool `json:"store_content,omitempty"`
+	// RetentionDays and MaxRecords bound what is kept. Zero keeps the
+	// defaults of 90 days and 100,000 records.
+	RetentionDays int `json:"retention_days,omitempty"`
+	MaxRecords    int `json:"max_records,omitempty"`
+}
+
+// MetricsConfig controls the daemon's loopback metrics endpoint.
+type MetricsConfig struct {
+	// Addr is the loopback address to listen on, 127.0.0.1:7458 by default.
+	Addr string `json:"addr,omitempty"`
+	// Disabled turns the endpoint off; status then only shows the state.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// ScheduleRule is a window in which messages are checked, e.g. work
+// hours on weekdays.
+type ScheduleRule struct {
+	// Apps are app names or bundle IDs; empty means every app.
+	Apps []string `json:"apps,omitempty"`
+	// Days are "mon" to "sun", "weekdays" or "weekends"; empty is every
+	// day.
+	Days []string `json:"days,omitempty"`
+	// From and To are "HH:MM"; leaving both out means all day.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// EnforcementConfig sets the enforcement mode: observe, advise, gate (the
+// default) or block.
+type EnforcementConfig struct {
+	// Mode applies to every app not listed in Apps.
+	Mode string `json:"mode,omitempty"`
+	// Apps maps app names or bundle IDs to their own mode.
+	Apps map[string]string `json:"apps,omitempty"`
+}
+
+// MetricsAddr returns where the daemon serves metrics, or "" if it
+// doesn't.
+func (c *Config) MetricsAddr() string {
+	switch {
+	case c.Metrics.Disabled:
+		return ""
+	case c.Metrics.Addr != "":
+		return c.Metrics.Addr
+	}
+	return httpapi.DefaultMetricsAddr
+}
+
+// APIToken returns the HTTP API token, preferring the environment.
+func (c *Config) APIToken() string {
+	if token := os.Getenv("HEMINGWAY_GUARD_API_TOKEN"); token != "" {
+		return token
+	}
+	return c.API.Token
+}
+
+// Default returns the configuration used when no file exists.
+func Default() *Config {
+	return &Config{}
+}
+
+// Path returns where the config file lives: $HEMINGWAY_GUARD_CONFIG if
+// set, otherwise hemingway-guard/config.json in the user config directory.
+func Path() (string, error) {
+	if path := os.Getenv("HEMINGWAY_GUARD_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hemingway-guard", "config.json"), nil
+}
+
+// Load reads a config file. A missing file yields the defaults.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadDefault reads the config file at Path.
+func LoadDefault() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// validate rejects rule IDs that don't exist, which are most likely typos.
+func (c *Config) validate() error {
+	known := make(map[string]bool)
+	for _, rule := range analyzer.DefaultRules() {
+		known[rule.ID] = true
+	}
+	for _, id := range c.Rules.Disabled {
+		if !known[id] {
+			return fmt.Errorf("unknown rule %q in rules.disabled", id)
+		}
+	}
+	for id := range c.Rules.Severity {
+		if !known[id] {
+			return fmt.Errorf("unknown rule %q in rules.severity", id)
+		}
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("log.level: %w", err)
+	}
+	if _, err := logging.ParsePrivacy(c.Log.Privacy); err != nil {
+		return fmt.Errorf("log.privacy: %w", err)
+	}
+	if c.History.RetentionDays < 0 || c.History.MaxRecords < 0 {
+		return errors.New("history.retention_days and history.max_records can't be negative")
+	}
+	if _, err := c.ScheduleRules(); err != nil {
+		return err
+	}
+	if _, _, err := c.EnforcementModes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ScheduleRules converts the schedule for the policy, resolving app names
+// to bundle IDs.
+func (c *Config) ScheduleRules() ([]policy.Rule, error) {
+	var rules []policy.Rule
+	for i, sr := range c.Schedule {
+		var r policy.Rule
+		for _, app := range sr.Apps {
+			target := findApp(app)
+			if target == nil {
+				return nil, fmt.Errorf("schedule[%d]: unknown app %q", i, app)
+			}
+			r.Apps = append(r.Apps, target.BundleID)
+		}
+		days, err := policy.ParseDays(sr.Days)
+		if err != nil {
+			return nil, fmt.Errorf("schedule[%d]: %w", i, err)
+		}
+		r.Days = days
+		if (sr.From == "") != (sr.To == "") {
+			return nil, fmt.Errorf("schedule[%d]: set both from and to, or neither", i)
+		}
+		if sr.From != "" {
+			if r.From, err = policy.ParseTimeOfDay(sr.From); err != nil {
+				return nil, fmt.Errorf("schedule[%d].from: %w", i, err)
+			}
+			if r.To, err = policy.ParseTimeOfDay(sr.To); err != nil {
+				return nil, fmt.Errorf("schedule[%d].to: %w", i, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// EnforcementModes returns the enforcement mode for every app and the
+// modes of apps that differ, by bundle ID.
+func (c *Config) EnforcementModes() (policy.Enforcement, map[string]policy.Enforcement, error) {
+	mode, err := policy.ParseEnforcement(c.Enforcement.Mode)
+	if err != nil {
+		return "", nil, fmt.Errorf("enforcement.mode: %w", err)
+	}
+	apps := make(map[string]policy.Enforcement, len(c.Enforcement.Apps))
+	for app, name := range c.Enforcement.Apps {
+		target := findApp(app)
+		if target == nil {
+			return "", nil, fmt.Errorf("enforcement.apps: unknown app %q", app)
+		}
+		m, err := policy.ParseEnforcement(name)
+		if err != nil {
+			return "", nil, fmt.Errorf("enforcement.apps[%q]: %w", app, err)
+		}
+		apps[target.BundleID] = m
+	}
+	return mode, apps, nil
+}
+
+// findApp looks up a monitored app by name or bundle ID.
+func findApp(nameOrID string) *apps.TargetApp {
+	for _, target := range apps.DefaultTargets() {
+		if strings.EqualFold(nameOrID, target.Name) || nameOrID == target.BundleID {
+			return &target
+		}
+	}
+	return nil
+}
+
+// ApplyRules returns rules with the configured rules removed and
+// severities overridden.
+func (c *Config) ApplyRules(rules []analyzer.Rule) []analyzer.Rule {
+	disabled := make(map[string]bool)
+	for _, id := range c.Rules.Disabled {
+		disabled[id] = true
+	}
+
+	var out []analyzer.Rule
+	for _, rule := range rules {
+		if disabled[rule.ID] {
+			continue
+		}
+		if severity, ok := c.Rules.Severity[rule.ID]; ok {
+			check := rule.Check
+			rule.Check = func(text string) []analyzer.Finding {
+				findings := check(text)
+				for i := range findings {
+					findings[i].Severity = severity
+				}
+				return findings
+			}
+		}
+		out = append(out, rule)
+	}
+	return out
+}
+
+// NewAnalyzer returns an analyzer using the configured rules.
+func (c *Config) NewAnalyzer() *analyzer.Analyzer {
+	a := analyzer.NewAnalyzer()
+	a.SetRules(c.ApplyRules(analyzer.DefaultRules()))
+	return a
+}
+
+// LogOptions returns the logging options for the daemon's log file.
+func (c *Config) LogOptions() (logging.Options, error) {
+	level, err := logging.ParseLevel(c.Log.Level)
+	if err != nil {
+		return logging.Options{}, err
+	}
+	privacy, err := logging.ParsePrivacy(c.Log.Privacy)
+	if err != nil {
+		return logging.Options{}, err
+	}
+	file, err := logging.DefaultFile()
+	if err != nil {
+		return logging.Options{}, err
+	}
+	return logging.Options{
+		Level:      level,
+		Privacy:    privacy,
+		File:       file,
+		MaxSize:    int64(c.Log.MaxSizeMB) << 20,
+		MaxBackups: c.Log.MaxBackups,
+		Stderr:     true,
+	}, nil
+}
+
+// OpenHistory opens the history store with the configured retention,
+// pruning what it no longer keeps.
+func (c *Config) OpenHistory() (*history.Store, error) {
+	path, err := history.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	retention := history.DefaultRetention
+	if c.History.RetentionDays > 0 {
+		retention.MaxAge = time.Duration(c.History.RetentionDays) * 24 * time.Hour
+	}
+	if c.History.MaxRecords > 0 {
+		retention.MaxRecords = c.History.MaxRecords
+	}
+	store.SetRetention(retention)
+	store.SetKeepContent(c.History.StoreContent)
+	if _, err := store.Prune(time.Now()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("pruning history: %w", err)
+	}
+	return store, nil
+}
@@ -0,0 +1,15 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dataDir is ~/Library/Application Support/hemingway-guard.
+func dataDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "Application Support", "hemingway-guard"), nil
+}
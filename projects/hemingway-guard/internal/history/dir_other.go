@@ -0,0 +1,21 @@
+//go:build !darwin
+
+package history
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dataDir is $XDG_DATA_HOME/hemingway-guard, defaulting to
+// ~/.local/share/hemingway-guard.
+func dataDir() (string, error) {
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return filepath.Join(data, "hemingway-guard"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "hemingway-guard"), nil
+}
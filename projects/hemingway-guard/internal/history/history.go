@@ -0,0 +1,105 @@
+// Package history keeps a local record of the messages HemingwayGuard
+// analyzed and what the user did with each.
+//
+// Records are appended to a JSON Lines file readable only by the user. By
+// default they hold metrics, rule hits and the outcome, not the message:
+// text is only stored when the store is told to keep it.
+package history
+
+import (
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+// Verdict is what the analyzer decided.
+type Verdict string
+
+const (
+	VerdictApproved Verdict = "approved"
+	VerdictRejected Verdict = "rejected"
+	VerdictBlocked  Verdict = "blocked"
+	// VerdictError means the analysis failed and the message was let
+	// through unchecked.
+	VerdictError Verdict = "error"
+)
+
+// Outcomes that don't come from the popover. Popover choices are recorded
+// by their protocol name, e.g. "send_anyway" or "use_suggestion".
+const (
+	// ActionSent means the message was approved and sent unchanged.
+	ActionSent = "sent"
+	// ActionAllowed means the message had issues but was sent because
+	// nobody could review it, or enforcement only observes.
+	ActionAllowed = "allowed"
+	// ActionAdvised means the message had issues that were shown to the
+	// user as it was sent.
+	ActionAdvised = "advised"
+	// ActionHeld means the message wasn't sent: enforcement requires a
+	// change nobody could review, or the review failed.
+	ActionHeld = "held"
+	// ActionFailed means the user's choice couldn't be applied, so the
+	// message was held. The choice is kept in Record.Attempted.
+	ActionFailed = "failed"
+)
+
+// Record describes one intercepted send.
+type Record struct {
+	Time    time.Time `json:"time"`
+	App     string    `json:"app,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+
+	Words           int     `json:"words"`
+	ReadTimeSeconds int     `json:"read_time_seconds"`
+	GradeLevel      float64 `json:"grade_level"`
+	// Rules lists the IDs of the rules that reported findings, once each.
+	Rules []string `json:"rules,omitempty"`
+
+	Verdict Verdict `json:"verdict"`
+	Action  string  `json:"action"`
+	// Attempted is the popover choice that couldn't be applied when Action
+	// is ActionFailed.
+	Attempted string `json:"attempted,omitempty"`
+	// Latency is how long the analysis took.
+	Latency time.Duration `json:"latency_ns"`
+
+	// Text is the message as typed. It is empty unless the store keeps
+	// content.
+	Text string `json:"text,omitempty"`
+}
+
+// NewRecord describes an analysis of text sent in appCtx. Set Action once
+// the outcome is known. A nil analysis records a failed one.
+func NewRecord(analysis *analyzer.Analysis, text string, appCtx analyzer.AppContext, latency time.Duration) Record {
+	rec := Record{
+		Time:    time.Now(),
+		App:     appCtx.AppName,
+		Channel: appCtx.ChannelType,
+		Verdict: VerdictError,
+		Latency: latency,
+		Text:    text,
+	}
+	if analysis == nil {
+		return rec
+	}
+
+	rec.Words = analysis.WordCount
+	rec.ReadTimeSeconds = analysis.ReadTimeSeconds
+	rec.GradeLevel = analysis.GradeLevel
+	seen := make(map[string]bool)
+	for _, f := range analysis.Findings {
+		if !seen[f.Rule] {
+			seen[f.Rule] = true
+			rec.Rules = append(rec.Rules, f.Rule)
+		}
+	}
+	switch {
+	case analysis.Blocked:
+		rec.Verdict = VerdictBlocked
+	case analysis.Approved:
+		rec.Verdict = VerdictApproved
+	default:
+		rec.Verdict = VerdictRejected
+	}
+	return rec
+}
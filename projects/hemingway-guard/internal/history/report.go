@@ -0,0 +1,183 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+)
+
+// Week summarizes one week of history, Monday to Sunday.
+type Week struct {
+	Start    time.Time  `json:"start"`
+	Messages int        `json:"messages"`
+	Apps     []AppStats `json:"apps"`      // busiest first
+	TopRules []RuleHits `json:"top_rules"` // most triggered first
+
+	// Reviewed counts messages the user answered in the popover.
+	Reviewed int `json:"reviewed"`
+	// SuggestionsTaken counts reviews answered with "use suggestion".
+	SuggestionsTaken int `json:"suggestions_taken"`
+	// Overrides counts reviews where the user chose to send the message as
+	// written despite its findings: "send anyway", or an override of a block.
+	Overrides int `json:"overrides"`
+	// Failed counts reviews whose choice couldn't be applied. They still
+	// count towards the choice made.
+	Failed int `json:"failed"`
+
+	SuggestionRate float64 `json:"suggestion_rate"` // of reviews, 0 to 1
+	OverrideRate   float64 `json:"override_rate"`   // of reviews, 0 to 1
+	AvgGrade       float64 `json:"avg_grade"`
+	AvgWords       float64 `json:"avg_words"`
+}
+
+// AppStats summarizes one app's messages in a week.
+type AppStats struct {
+	App      string  `json:"app"`
+	Messages int     `json:"messages"`
+	AvgGrade float64 `json:"avg_grade"`
+	AvgWords float64 `json:"avg_words"`
+}
+
+// RuleHits counts the messages a rule reported findings on.
+type RuleHits struct {
+	Rule     string `json:"rule"`
+	Messages int    `json:"messages"`
+}
+
+// topRules is how many rules a Week lists.
+const topRules = 5
+
+// reviewActions are the popover's answers.
+var reviewActions = map[string]bool{
+	string(ipc.ActionSendAnyway):    true,
+	string(ipc.ActionUseSuggestion): true,
+	string(ipc.ActionEdit):          true,
+	string(ipc.ActionCancel):        true,
+	string(ipc.ActionOverride):      true,
+}
+
+// Weekly summarizes records into the given number of weeks ending with the
+// one containing now, oldest first. Weeks start on Monday in now's time
+// zone; weeks without records are included so trends line up.
+func Weekly(records []Record, weeks int, now time.Time) []Week {
+	if weeks <= 0 {
+		return nil
+	}
+	first := WeekStart(now).AddDate(0, 0, -7*(weeks-1))
+	byWeek := make([][]Record, weeks)
+	for _, rec := range records {
+		t := rec.Time.In(now.Location())
+		if t.Before(first) {
+			continue
+		}
+		i := int(WeekStart(t).Sub(first).Hours()+12) / (7 * 24) // +12h absorbs DST shifts
+		if i < weeks {
+			byWeek[i] = append(byWeek[i], rec)
+		}
+	}
+
+	summaries := make([]Week, weeks)
+	for i := range summaries {
+		summaries[i] = summarize(first.AddDate(0, 0, 7*i), byWeek[i])
+	}
+	return summaries
+}
+
+// WeekStart returns midnight on the Monday of t's week, in t's time zone.
+func WeekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7 // days since Monday
+	y, m, d := t.AddDate(0, 0, -days).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// totals counts messages and accumulates averages over the analyzed ones;
+// failed analyses have no metrics.
+type totals struct {
+	messages int
+	analyzed int
+	grade    float64
+	words    int
+}
+
+func (t *totals) add(rec Record) {
+	t.messages++
+	if rec.Verdict == VerdictError {
+		return
+	}
+	t.analyzed++
+	t.grade += rec.GradeLevel
+	t.words += rec.Words
+}
+
+func (t totals) averages() (grade, words float64) {
+	if t.analyzed == 0 {
+		return 0, 0
+	}
+	return t.grade / float64(t.analyzed), float64(t.words) / float64(t.analyzed)
+}
+
+func summarize(start time.Time, records []Record) Week {
+	w := Week{Start: start, Messages: len(records), Apps: []AppStats{}, TopRules: []RuleHits{}}
+
+	var all totals
+	apps := make(map[string]*totals)
+	rules := make(map[string]int)
+	for _, rec := range records {
+		all.add(rec)
+		if apps[rec.App] == nil {
+			apps[rec.App] = &totals{}
+		}
+		apps[rec.App].add(rec)
+		for _, rule := range rec.Rules {
+			rules[rule]++
+		}
+
+		action := rec.Action
+		if action == ActionFailed {
+			w.Failed++
+			action = rec.Attempted
+		}
+		if reviewActions[action] {
+			w.Reviewed++
+		}
+		switch ipc.Action(action) {
+		case ipc.ActionUseSuggestion:
+			w.SuggestionsTaken++
+		case ipc.ActionSendAnyway, ipc.ActionOverride:
+			w.Overrides++
+		}
+	}
+
+	w.AvgGrade, w.AvgWords = all.averages()
+	if w.Reviewed > 0 {
+		w.SuggestionRate = float64(w.SuggestionsTaken) / float64(w.Reviewed)
+		w.OverrideRate = float64(w.Overrides) / float64(w.Reviewed)
+	}
+
+	for app, t := range apps {
+		stats := AppStats{App: app, Messages: t.messages}
+		stats.AvgGrade, stats.AvgWords = t.averages()
+		w.Apps = append(w.Apps, stats)
+	}
+	sort.Slice(w.Apps, func(i, j int) bool {
+		if w.Apps[i].Messages != w.Apps[j].Messages {
+			return w.Apps[i].Messages > w.Apps[j].Messages
+		}
+		return w.Apps[i].App < w.Apps[j].App
+	})
+
+	for rule, n := range rules {
+		w.TopRules = append(w.TopRules, RuleHits{Rule: rule, Messages: n})
+	}
+	sort.Slice(w.TopRules, func(i, j int) bool {
+		if w.TopRules[i].Messages != w.TopRules[j].Messages {
+			return w.TopRules[i].Messages > w.TopRules[j].Messages
+		}
+		return w.TopRules[i].Rule < w.TopRules[j].Rule
+	})
+	if len(w.TopRules) > topRules {
+		w.TopRules = w.TopRules[:topRules]
+	}
+	return w
+}
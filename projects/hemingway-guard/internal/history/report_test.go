@@ -0,0 +1,224 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata" // America/New_York on machines without a zoneinfo database
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestWeekStart(t *testing.T) {
+	ny := newYork(t)
+	tests := []struct {
+		t    time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 3, 2, 0, 0, 0, 0, ny), time.Date(2026, 3, 2, 0, 0, 0, 0, ny)},
+		{time.Date(2026, 3, 4, 15, 30, 0, 0, ny), time.Date(2026, 3, 2, 0, 0, 0, 0, ny)},
+		// Sunday belongs to the week before
+		{time.Date(2026, 3, 8, 23, 59, 0, 0, ny), time.Date(2026, 3, 2, 0, 0, 0, 0, ny)},
+		{time.Date(2026, 3, 9, 0, 0, 0, 0, ny), time.Date(2026, 3, 9, 0, 0, 0, 0, ny)},
+		// Across a year
+		{time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2026, 12, 28, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := WeekStart(tt.t); !got.Equal(tt.want) || got.Location() != tt.want.Location() {
+			t.Errorf("WeekStart(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+// Records land in the week they were sent in now's time zone, including
+// the 167- and 169-hour weeks when clocks change.
+func TestWeeklyBuckets(t *testing.T) {
+	ny := newYork(t)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, ny)
+	}
+	tests := []struct {
+		name    string
+		now     time.Time
+		weeks   int
+		records []time.Time
+		want    []int // messages per week
+	}{
+		{
+			name:  "clocks go forward",
+			now:   at(time.March, 18, 12, 0),
+			weeks: 3,
+			records: []time.Time{
+				at(time.March, 1, 23, 59), // before the first week
+				at(time.March, 2, 0, 0),
+				at(time.March, 8, 1, 59), // just before the change
+				at(time.March, 8, 3, 0),  // just after it
+				at(time.March, 8, 23, 59),
+				at(time.March, 9, 0, 0),
+				// 23:30 on Sunday in New York, Monday in UTC
+				time.Date(2026, 3, 9, 3, 30, 0, 0, time.UTC),
+				at(time.March, 18, 9, 0),
+				at(time.March, 23, 9, 0), // after this week
+			},
+			want: []int{5, 1, 1},
+		},
+		{
+			name:  "clocks go back",
+			now:   at(time.November, 4, 12, 0),
+			weeks: 2,
+			records: []time.Time{
+				at(time.October, 26, 0, 0),
+				at(time.November, 1, 1, 30),
+				at(time.November, 1, 1, 30).Add(time.Hour), // 1:30 again
+				at(time.November, 1, 23, 59),
+				at(time.November, 2, 0, 0),
+			},
+			want: []int{4, 1},
+		},
+		{
+			name:    "empty weeks",
+			now:     at(time.March, 18, 12, 0),
+			weeks:   4,
+			records: []time.Time{at(time.March, 3, 12, 0)},
+			want:    []int{0, 1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make([]Record, len(tt.records))
+			for i, sent := range tt.records {
+				records[i] = Record{Time: sent, Verdict: VerdictApproved, Action: ActionSent}
+			}
+			weeks := Weekly(records, tt.weeks, tt.now)
+			if len(weeks) != tt.weeks {
+				t.Fatalf("%d weeks, want %d", len(weeks), tt.weeks)
+			}
+			var got []int
+			for i, w := range weeks {
+				got = append(got, w.Messages)
+				want := WeekStart(tt.now).AddDate(0, 0, 7*(i-tt.weeks+1))
+				if !w.Start.Equal(want) {
+					t.Errorf("week %d starts %v, want %v", i, w.Start, want)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages per week = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if weeks := Weekly(nil, 0, epoch); weeks != nil {
+		t.Errorf("Weekly for 0 weeks = %v", weeks)
+	}
+}
+
+func TestWeeklyRates(t *testing.T) {
+	review := func(action string) Record {
+		return Record{Time: epoch, Verdict: VerdictRejected, Action: action}
+	}
+	failed := func(attempted string) Record {
+		return Record{Time: epoch, Verdict: VerdictRejected, Action: ActionFailed, Attempted: attempted}
+	}
+	tests := []struct {
+		name                            string
+		records                         []Record
+		reviewed, suggestions, override int
+		failed                          int
+		suggestionRate, overrideRate    float64
+	}{
+		{name: "no reviews", records: []Record{
+			{Time: epoch, Verdict: VerdictApproved, Action: ActionSent},
+			{Time: epoch, Verdict: VerdictRejected, Action: ActionAllowed},
+			{Time: epoch, Verdict: VerdictRejected, Action: ActionAdvised},
+			{Time: epoch, Verdict: VerdictBlocked, Action: ActionHeld},
+		}},
+		{
+			name:     "every choice",
+			records:  []Record{review("send_anyway"), review("use_suggestion"), review("edit"), review("cancel"), review("override")},
+			reviewed: 5, suggestions: 1, override: 2,
+			suggestionRate: 0.2, overrideRate: 0.4,
+		},
+		{
+			name:     "failed choices",
+			records:  []Record{review("send_anyway"), failed("send_anyway"), failed("use_suggestion"), failed("")},
+			reviewed: 3, suggestions: 1, override: 2, failed: 3,
+			suggestionRate: 1.0 / 3, overrideRate: 2.0 / 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Weekly(tt.records, 1, epoch)[0]
+			if w.Reviewed != tt.reviewed || w.SuggestionsTaken != tt.suggestions || w.Overrides != tt.override || w.Failed != tt.failed {
+				t.Errorf("reviewed %d, suggestions %d, overrides %d, failed %d; want %d, %d, %d, %d",
+					w.Reviewed, w.SuggestionsTaken, w.Overrides, w.Failed, tt.reviewed, tt.suggestions, tt.override, tt.failed)
+			}
+			if w.SuggestionRate != tt.suggestionRate || w.OverrideRate != tt.overrideRate {
+				t.Errorf("rates %v, %v; want %v, %v", w.SuggestionRate, w.OverrideRate, tt.suggestionRate, tt.overrideRate)
+			}
+		})
+	}
+}
+
+func TestWeeklyApps(t *testing.T) {
+	records := []Record{
+		{Time: epoch, App: "Slack", Verdict: VerdictApproved, Words: 10, GradeLevel: 4},
+		{Time: epoch, App: "Slack", Verdict: VerdictRejected, Words: 30, GradeLevel: 8},
+		// A failed analysis counts as a message but not in the averages
+		{Time: epoch, App: "Slack", Verdict: VerdictError},
+		{Time: epoch, App: "Discord", Verdict: VerdictApproved, Words: 5, GradeLevel: 2},
+		{Time: epoch, App: "", Verdict: VerdictApproved, Words: 5, GradeLevel: 2},
+	}
+	w := Weekly(records, 1, epoch)[0]
+	want := []AppStats{
+		{App: "Slack", Messages: 3, AvgGrade: 6, AvgWords: 20},
+		{App: "", Messages: 1, AvgGrade: 2, AvgWords: 5},
+		{App: "Discord", Messages: 1, AvgGrade: 2, AvgWords: 5},
+	}
+	if !reflect.DeepEqual(w.Apps, want) {
+		t.Errorf("apps = %+v, want %+v", w.Apps, want)
+	}
+	if w.Messages != 5 || w.AvgGrade != 4 || w.AvgWords != 12.5 {
+		t.Errorf("week: %d messages, grade %v, words %v; want 5, 4, 12.5", w.Messages, w.AvgGrade, w.AvgWords)
+	}
+
+	empty := Weekly(nil, 1, epoch)[0]
+	if empty.Apps == nil || empty.TopRules == nil || empty.AvgGrade != 0 {
+		t.Errorf("empty week = %+v, want empty lists and no averages", empty)
+	}
+}
+
+// Top rules are the most triggered, ties broken by ID, at most topRules.
+func TestWeeklyTopRules(t *testing.T) {
+	hits := map[string]int{
+		"chat/length":     4,
+		"style/passive":   2,
+		"style/adverbs":   2,
+		"git/imperative":  3,
+		"security/secret": 1,
+		"style/hedging":   1,
+		"chat/wall":       1,
+	}
+	var records []Record
+	for rule, n := range hits {
+		for range n {
+			records = append(records, Record{Time: epoch, Rules: []string{rule}})
+		}
+	}
+	want := []RuleHits{
+		{"chat/length", 4},
+		{"git/imperative", 3},
+		{"style/adverbs", 2},
+		{"style/passive", 2},
+		{"chat/wall", 1},
+	}
+	if got := Weekly(records, 1, epoch)[0].TopRules; !reflect.DeepEqual(got, want) {
+		t.Errorf("top rules = %v, want %v", got, want)
+	}
+}
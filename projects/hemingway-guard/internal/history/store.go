@@ -0,0 +1,300 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned after the store is closed.
+var ErrClosed = errors.New("history store closed")
+
+// ErrReadOnly is returned by changes to a store opened with OpenReadOnly.
+var ErrReadOnly = errors.New("history store is read-only")
+
+// maxRecordBytes bounds one line of the file; longer lines are skipped.
+const maxRecordBytes = 1 << 20
+
+// Retention bounds how much history is kept. Zero fields are unlimited.
+type Retention struct {
+	MaxAge     time.Duration
+	MaxRecords int
+}
+
+// DefaultRetention keeps 90 days, up to 100,000 records.
+var DefaultRetention = Retention{MaxAge: 90 * 24 * time.Hour, MaxRecords: 100_000}
+
+// Store is an append-only history file. It is safe for concurrent use.
+type Store struct {
+	path        string
+	retention   Retention
+	keepContent bool
+	readOnly    bool
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens the history file at path, creating it and its directory
+// readable only by the user.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := endLine(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Store{path: path, retention: DefaultRetention, f: f}, nil
+}
+
+// OpenReadOnly opens an existing history file for queries only, leaving
+// it untouched for the app appending to it.
+func OpenReadOnly(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, retention: DefaultRetention, readOnly: true, f: f}, nil
+}
+
+// endLine finishes a line cut short by a crash, so the next record
+// doesn't run into it.
+func endLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = f.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// SetRetention sets what Prune keeps.
+func (s *Store) SetRetention(r Retention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = r
+}
+
+// SetKeepContent sets whether records keep the message text. Off by
+// default: Append clears Text.
+func (s *Store) SetKeepContent(keep bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keepContent = keep
+}
+
+// Path returns the history file's path.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append adds a record.
+func (s *Store) Append(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if !s.keepContent {
+		rec.Text = ""
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	// One write per record, so a crash leaves at most one partial line
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+// Query selects records. Zero fields match everything.
+type Query struct {
+	Since, Until time.Time // Since is inclusive, Until exclusive
+	App          string
+	Verdict      Verdict
+	Action       string
+	Rule         string // records where this rule reported findings
+	// Limit keeps only the most recent matches.
+	Limit int
+}
+
+func (q Query) matches(rec Record) bool {
+	switch {
+	case !q.Since.IsZero() && rec.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !rec.Time.Before(q.Until):
+		return false
+	case q.App != "" && rec.App != q.App:
+		return false
+	case q.Verdict != "" && rec.Verdict != q.Verdict:
+		return false
+	case q.Action != "" && rec.Action != q.Action:
+		return false
+	case q.Rule != "" && !slices.Contains(rec.Rules, q.Rule):
+		return false
+	}
+	return true
+}
+
+// Query returns the records matching q, oldest first.
+func (s *Store) Query(q Query) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil, ErrClosed
+	}
+
+	var records []Record
+	err := s.scan(func(rec Record, _ []byte) {
+		if !q.matches(rec) {
+			return
+		}
+		records = append(records, rec)
+		if q.Limit > 0 && len(records) > 2*q.Limit {
+			records = append(records[:0], records[len(records)-q.Limit:]...)
+		}
+	})
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[len(records)-q.Limit:]
+	}
+	return records, err
+}
+
+// scan calls fn for each record in the file and its line, skipping lines
+// that don't parse, such as one cut short by a crash.
+func (s *Store) scan(fn func(rec Record, line []byte)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && len(line) <= maxRecordBytes {
+			var rec Record
+			if json.Unmarshal(line, &rec) == nil {
+				fn(rec, bytes.TrimSuffix(line, []byte("\n")))
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Prune drops records the retention policy no longer keeps and returns
+// how many it dropped. Unless the store keeps content, it also clears the
+// text of records written while it did. The file is rewritten and swapped
+// in atomically.
+func (s *Store) Prune(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return 0, ErrClosed
+	}
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	var kept [][]byte
+	total, stripped := 0, false
+	err := s.scan(func(rec Record, line []byte) {
+		total++
+		if s.retention.MaxAge > 0 && now.Sub(rec.Time) > s.retention.MaxAge {
+			return
+		}
+		if rec.Text != "" && !s.keepContent {
+			rec.Text = ""
+			line, _ = json.Marshal(rec) // it was just unmarshaled, so it marshals
+			stripped = true
+		}
+		kept = append(kept, line)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if n := s.retention.MaxRecords; n > 0 && len(kept) > n {
+		kept = kept[len(kept)-n:]
+	}
+	if len(kept) == total && !stripped {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".history-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, line := range kept {
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, err
+	}
+
+	// Appends must go to the new file
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("reopening %s: %w", s.path, err)
+	}
+	s.f.Close()
+	s.f = f
+	return total - len(kept), nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// DefaultPath returns history.jsonl in the user's data directory.
+func DefaultPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
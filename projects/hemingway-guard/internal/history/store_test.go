@@ -0,0 +1,293 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+func openStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "data", "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func appendAll(t *testing.T, s *Store, records ...Record) {
+	t.Helper()
+	for _, rec := range records {
+		if err := s.Append(rec); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+}
+
+// sample returns records a day apart, in order.
+func sample() []Record {
+	return []Record{
+		{Time: epoch, App: "Slack", Verdict: VerdictApproved, Action: ActionSent, Words: 4},
+		{Time: epoch.Add(24 * time.Hour), App: "Slack", Verdict: VerdictRejected, Action: "send_anyway", Rules: []string{"chat/length", "style/passive"}},
+		{Time: epoch.Add(48 * time.Hour), App: "Discord", Verdict: VerdictBlocked, Action: ActionHeld, Rules: []string{"security/secret"}},
+		{Time: epoch.Add(72 * time.Hour), App: "Slack", Verdict: VerdictRejected, Action: "use_suggestion", Rules: []string{"chat/length"}},
+	}
+}
+
+func times(records []Record) []int {
+	out := []int{}
+	for _, rec := range records {
+		out = append(out, int(rec.Time.Sub(epoch)/(24*time.Hour)))
+	}
+	return out
+}
+
+func TestOpenCreatesPrivateFile(t *testing.T) {
+	s := openStore(t)
+	info, err := os.Stat(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("file mode = %o, want 600", mode)
+	}
+	dir, err := os.Stat(filepath.Dir(s.Path()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := dir.Mode().Perm(); mode != 0o700 {
+		t.Errorf("directory mode = %o, want 700", mode)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := openStore(t)
+	appendAll(t, s, sample()...)
+
+	tests := []struct {
+		name string
+		q    Query
+		want []int // days after epoch
+	}{
+		{"everything", Query{}, []int{0, 1, 2, 3}},
+		{"since is inclusive", Query{Since: epoch.Add(24 * time.Hour)}, []int{1, 2, 3}},
+		{"until is exclusive", Query{Until: epoch.Add(48 * time.Hour)}, []int{0, 1}},
+		{"app", Query{App: "Slack"}, []int{0, 1, 3}},
+		{"verdict", Query{Verdict: VerdictRejected}, []int{1, 3}},
+		{"action", Query{Action: ActionHeld}, []int{2}},
+		{"rule", Query{Rule: "chat/length"}, []int{1, 3}},
+		{"combined", Query{App: "Slack", Rule: "style/passive"}, []int{1}},
+		{"limit keeps the latest", Query{Limit: 2}, []int{2, 3}},
+		{"limit with a filter", Query{App: "Slack", Limit: 2}, []int{1, 3}},
+		{"no match", Query{App: "Messages"}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Query(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(times(got), tt.want) {
+				t.Errorf("Query = days %v, want %v", times(got), tt.want)
+			}
+		})
+	}
+
+	got, _ := s.Query(Query{Verdict: VerdictRejected, Limit: 1})
+	if want := sample()[3]; len(got) != 1 || !reflect.DeepEqual(got[0].Rules, want.Rules) || got[0].Action != want.Action {
+		t.Errorf("Query = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryLimitOverManyRecords(t *testing.T) {
+	s := openStore(t)
+	for i := range 50 {
+		appendAll(t, s, Record{Time: epoch.Add(time.Duration(i) * 24 * time.Hour), App: "Slack"})
+	}
+	got, err := s.Query(Query{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{47, 48, 49}; !reflect.DeepEqual(times(got), want) {
+		t.Errorf("Query = days %v, want %v", times(got), want)
+	}
+}
+
+func TestAppendContent(t *testing.T) {
+	s := openStore(t)
+	appendAll(t, s, Record{Time: epoch, Text: "not kept"})
+	s.SetKeepContent(true)
+	appendAll(t, s, Record{Time: epoch, Text: "kept"})
+
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Text != "" || got[1].Text != "kept" {
+		t.Errorf("records = %+v, want the text only in the second", got)
+	}
+	raw, _ := os.ReadFile(s.Path())
+	if strings.Contains(string(raw), "not kept") {
+		t.Errorf("file carries text it shouldn't:\n%s", raw)
+	}
+}
+
+func TestOpenRecoversPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	partial := `{"time":"2026-03-02T09:00:00Z","verdict":"approved"}` + "\n" + `{"time":"2026-03-03T09:00:00Z","verd`
+	if err := os.WriteFile(path, []byte(partial), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	appendAll(t, s, Record{Time: epoch.Add(48 * time.Hour), Verdict: VerdictRejected})
+
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 2}; !reflect.DeepEqual(times(got), want) {
+		t.Errorf("Query = days %v, want %v, skipping the partial line", times(got), want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := epoch.Add(72 * time.Hour)
+	tests := []struct {
+		name      string
+		retention Retention
+		dropped   int
+		want      []int
+	}{
+		{"by age", Retention{MaxAge: 36 * time.Hour}, 2, []int{2, 3}},
+		{"by count", Retention{MaxRecords: 3}, 1, []int{1, 2, 3}},
+		{"both", Retention{MaxAge: 60 * time.Hour, MaxRecords: 1}, 3, []int{3}},
+		{"nothing to drop", Retention{MaxAge: 10 * 24 * time.Hour}, 0, []int{0, 1, 2, 3}},
+		{"unlimited", Retention{}, 0, []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openStore(t)
+			appendAll(t, s, sample()...)
+			s.SetRetention(tt.retention)
+
+			dropped, err := s.Prune(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("Prune dropped %d, want %d", dropped, tt.dropped)
+			}
+			got, _ := s.Query(Query{})
+			if !reflect.DeepEqual(times(got), tt.want) {
+				t.Errorf("after Prune: days %v, want %v", times(got), tt.want)
+			}
+
+			// Appends land in the rewritten file
+			appendAll(t, s, Record{Time: now, App: "Messages"})
+			if got, _ := s.Query(Query{App: "Messages"}); len(got) != 1 {
+				t.Errorf("append after Prune: %d records, want 1", len(got))
+			}
+		})
+	}
+}
+
+func TestPruneStripsContent(t *testing.T) {
+	s := openStore(t)
+	s.SetKeepContent(true)
+	appendAll(t, s,
+		Record{Time: epoch, App: "Slack", Text: "the launch slipped"},
+		Record{Time: epoch.Add(time.Hour), App: "Slack", Rules: []string{"chat/length"}, Text: "call me at noon"},
+	)
+
+	// Content kept: Prune leaves the text alone
+	if _, err := s.Prune(epoch); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Query(Query{}); got[0].Text == "" {
+		t.Fatal("Prune cleared text while the store keeps it")
+	}
+
+	s.SetKeepContent(false)
+	dropped, err := s.Prune(epoch.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 0 {
+		t.Errorf("Prune dropped %d, want 0", dropped)
+	}
+	raw, _ := os.ReadFile(s.Path())
+	if strings.Contains(string(raw), "launch") || strings.Contains(string(raw), "noon") {
+		t.Errorf("file still carries text:\n%s", raw)
+	}
+	got, _ := s.Query(Query{})
+	if len(got) != 2 || !reflect.DeepEqual(got[1].Rules, []string{"chat/length"}) {
+		t.Errorf("records = %+v, want both kept with their rules", got)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing.jsonl")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenReadOnly of a missing file = %v, want ErrNotExist", err)
+	}
+
+	w := openStore(t)
+	appendAll(t, w, sample()[:2]...)
+	before, _ := os.ReadFile(w.Path())
+
+	r, err := OpenReadOnly(w.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Append(Record{Time: epoch}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Append = %v, want ErrReadOnly", err)
+	}
+	r.SetRetention(Retention{MaxRecords: 1})
+	if _, err := r.Prune(epoch); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Prune = %v, want ErrReadOnly", err)
+	}
+	if after, _ := os.ReadFile(w.Path()); string(after) != string(before) {
+		t.Errorf("read-only store changed the file:\n%s", after)
+	}
+
+	// Records the writer appends meanwhile show up in the next query
+	appendAll(t, w, sample()[2:]...)
+	got, err := r.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(times(got), want) {
+		t.Errorf("Query = days %v, want %v", times(got), want)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	s := openStore(t)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(Record{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Append = %v, want ErrClosed", err)
+	}
+	if _, err := s.Query(Query{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Query = %v, want ErrClosed", err)
+	}
+	if _, err := s.Prune(epoch); !errors.Is(err, ErrClosed) {
+		t.Errorf("Prune = %v, want ErrClosed", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
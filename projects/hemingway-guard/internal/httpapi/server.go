@@ -0,0 +1,317 @@
+// Package httpapi serves the analyzer over HTTP on the loopback interface,
+// for local tools such as browser extensions and launcher scripts.
+//
+// Every endpoint except the health check requires the bearer token set with
+// SetToken. Requests and responses are JSON; POST /v1/analyze returns an
+// analyzer.Analysis exactly as the popover receives it, with a warning
+// added when the provider failed and only the local rules ran.
+//
+// A server made with NewMetricsServer serves only the health check and
+// GET /metrics, without a token: metrics are counts, never message text.
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
+)
+
+// ErrNoToken is returned by Listen when an API server has no token. The API is never
+// served without one: any local process, including web pages, can reach a
+// loopback port.
+var ErrNoToken = errors.New("http api: a token is required")
+
+// ErrNotLoopback is returned by Listen for addresses other processes on the
+// network could reach.
+var ErrNotLoopback = errors.New("http api: address must be on the loopback interface")
+
+// logger logs API failures. Request bodies are never logged.
+var logger = logging.Component("httpapi")
+
+// DefaultAddr is where the API listens unless configured otherwise.
+const DefaultAddr = "127.0.0.1:7457"
+
+// DefaultMetricsAddr is where the daemon serves metrics unless configured
+// otherwise.
+const DefaultMetricsAddr = "127.0.0.1:7458"
+
+// maxRequestBytes bounds a request body. Messages are short; this leaves
+// room for long documents without letting a client exhaust memory.
+const maxRequestBytes = 256 << 10
+
+// Server serves the HTTP API.
+type Server struct {
+	addr       string
+	analyzer   atomic.Pointer[analyzer.Analyzer]
+	token      string
+	resolveApp func(string) string
+	stats      stats
+	metrics    *metrics.Registry
+
+	mu       sync.Mutex
+	listener net.Listener
+	srv      *http.Server
+}
+
+// NewServer creates a server that will listen on addr and analyze with a.
+func NewServer(addr string, a *analyzer.Analyzer) *Server {
+	s := &Server{
+		addr:       addr,
+		resolveApp: func(app string) string { return app },
+	}
+	s.analyzer.Store(a)
+	s.stats.started = time.Now()
+	return s
+}
+
+// NewMetricsServer creates a server that will listen on addr and serve
+// reg's metrics. It needs no token.
+func NewMetricsServer(addr string, reg *metrics.Registry) *Server {
+	s := NewServer(addr, nil)
+	s.metrics = reg
+	return s
+}
+
+// SetAnalyzer replaces the analyzer, e.g. after the config is reloaded.
+// Requests in flight finish with the old one.
+func (s *Server) SetAnalyzer(a *analyzer.Analyzer) {
+	s.analyzer.Store(a)
+}
+
+// SetToken sets the bearer token clients must present.
+func (s *Server) SetToken(token string) {
+	s.token = token
+}
+
+// SetAppResolver sets how a request's app is turned into the name rules
+// expect, e.g. a bundle ID into "Slack". By default it is used as given.
+func (s *Server) SetAppResolver(resolve func(app string) string) {
+	s.resolveApp = resolve
+}
+
+// Addr returns the address the server listens on, once listening.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Listen binds the address. It returns ErrNoToken for an API server without
+// a token and ErrNotLoopback for non-loopback addresses. Call Serve to
+// handle requests.
+func (s *Server) Listen() error {
+	if s.analyzer.Load() != nil && s.token == "" {
+		return ErrNoToken
+	}
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return fmt.Errorf("http api: invalid address %q: %w", s.addr, err)
+	}
+	if !isLoopback(host) {
+		return fmt.Errorf("%w: %s", ErrNotLoopback, s.addr)
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	s.mu.Lock()
+	s.listener = ln
+	s.srv = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		MaxHeaderBytes:    16 << 10,
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Serve handles requests until ctx is done or Close is called, then
+// returns http.ErrServerClosed.
+func (s *Server) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	ln, srv := s.listener, s.srv
+	s.mu.Unlock()
+	if ln == nil {
+		return errors.New("http api not listening")
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+	return srv.Serve(ln)
+}
+
+// Close stops the server, waiting briefly for requests in flight.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// Handler returns the API's routes. Listen and Serve use it; it is exported
+// for embedding the API in another server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	if s.analyzer.Load() != nil {
+		mux.Handle("POST /v1/analyze", s.authorize(http.HandlerFunc(s.handleAnalyze)))
+		mux.Handle("GET /v1/rules", s.authorize(http.HandlerFunc(s.handleRules)))
+		mux.Handle("GET /v1/stats", s.authorize(http.HandlerFunc(s.handleStats)))
+	}
+	if s.metrics != nil {
+		mux.Handle("GET /metrics", s.metrics.Handler())
+	}
+	return checkHost(mux)
+}
+
+// checkHost rejects requests addressed to other host names, so a web page
+// can't reach the API by rebinding its own domain to 127.0.0.1.
+func checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !isLoopback(host) {
+			writeError(w, http.StatusForbidden, "host not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize requires "Authorization: Bearer <token>".
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hemingway-guard"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// analyzeRequest is the body of POST /v1/analyze.
+type analyzeRequest struct {
+	Text    string `json:"text"`
+	App     string `json:"app"`
+	Channel string `json:"channel"`
+
+	ConversationTitle string   `json:"conversation_title"`
+	RecentMessages    []string `json:"recent_messages"`
+	InThread          bool     `json:"in_thread"`
+}
+
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	var req analyzeRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxRequestBytes))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	appCtx := analyzer.AppContext{
+		AppName:           s.resolveApp(req.App),
+		ChannelType:       req.Channel,
+		ConversationTitle: req.ConversationTitle,
+		RecentMessages:    req.RecentMessages,
+		InThread:          req.InThread,
+	}
+	analysis, err := s.analyzer.Load().Analyze(r.Context(), req.Text, appCtx)
+	if analysis == nil {
+		logger.Error("analysis failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "analysis failed")
+		return
+	}
+	resp := analyzeResponse{Analysis: analysis}
+	if err != nil {
+		// The local rules still ran; a credential they found stays blocked
+		logger.Warn("analysis failed, returning local rules only", logging.Err(err))
+		resp.Warning = "analysis failed; only local rules ran"
+	}
+	s.stats.record(analysis)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// analyzeResponse is the body of a POST /v1/analyze response.
+type analyzeResponse struct {
+	*analyzer.Analysis
+	Warning string `json:"warning,omitempty"`
+}
+
+// ruleInfo describes a rule in GET /v1/rules.
+type ruleInfo struct {
+	ID string `json:"id"`
+}
+
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	rules := []ruleInfo{}
+	for _, rule := range s.analyzer.Load().Rules() {
+		rules = append(rules, ruleInfo{ID: rule.ID})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules})
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.stats.snapshot())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("failed to write response", logging.Err(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// isLoopback reports whether host names or is a loopback address.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,60 @@
+package httpapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+// stats counts the analyses this server has returned.
+type stats struct {
+	started time.Time
+
+	mu       sync.Mutex
+	analyzed int
+	approved int
+	findings map[string]int
+}
+
+// statsSnapshot is the body of GET /v1/stats.
+type statsSnapshot struct {
+	Started       time.Time      `json:"started"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Analyzed      int            `json:"analyzed"`
+	Approved      int            `json:"approved"`
+	Rejected      int            `json:"rejected"`
+	Findings      map[string]int `json:"findings"` // by rule ID
+}
+
+func (s *stats) record(a *analyzer.Analysis) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.analyzed++
+	if a.Approved {
+		s.approved++
+	}
+	if s.findings == nil {
+		s.findings = make(map[string]int)
+	}
+	for _, f := range a.Findings {
+		s.findings[f.Rule]++
+	}
+}
+
+func (s *stats) snapshot() statsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	findings := make(map[string]int, len(s.findings))
+	for rule, n := range s.findings {
+		findings[rule] = n
+	}
+	return statsSnapshot{
+		Started:       s.started,
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+		Analyzed:      s.analyzed,
+		Approved:      s.approved,
+		Rejected:      s.analyzed - s.approved,
+		Findings:      findings,
+	}
+}
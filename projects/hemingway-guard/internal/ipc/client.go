@@ -0,0 +1,123 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// Review is a review received by a Client.
+type Review struct {
+	RequestID string
+	ReviewRequest
+}
+
+// Client is the popover side of the socket. The Swift helper implements the
+// same protocol; this client lets Go code drive the daemon without the UI.
+type Client struct {
+	c *conn
+}
+
+// Dial connects to the daemon's socket and exchanges hellos. token is the
+// daemon's shared secret, or empty if it doesn't use one.
+func Dial(path, token string) (*Client, error) {
+	nc, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	cl := &Client{c: newConn(nc)}
+	if err := cl.handshake(token); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return cl, nil
+}
+
+func (cl *Client) handshake(token string) error {
+	hello := message{Type: TypeHello, Hello: &Hello{ProtocolVersion: ProtocolVersion, Role: RolePopover, Token: token}}
+	if err := cl.c.send(hello); err != nil {
+		return err
+	}
+
+	msg, err := cl.c.receive()
+	if err != nil {
+		return err
+	}
+	switch {
+	case msg.Type == TypeError:
+		return fmt.Errorf("daemon rejected connection: %s", msg.Error)
+	case msg.Type != TypeHello || msg.Hello == nil:
+		return fmt.Errorf("expected hello, got %q", msg.Type)
+	}
+	if err := compatible(msg.ProtocolVersion); err != nil {
+		return err
+	}
+	cl.c.peerMinor = minorVersion(msg.ProtocolVersion)
+	return nil
+}
+
+// Next waits for the next review. Dismissals for reviews the daemon gave
+// up on are reported through dismissed, which may be nil. Notices are
+// logged.
+func (cl *Client) Next(dismissed func(requestID string)) (Review, error) {
+	for {
+		msg, err := cl.c.receive()
+		if err != nil {
+			return Review{}, err
+		}
+
+		switch msg.Type {
+		case TypeReview:
+			if msg.ReviewRequest == nil {
+				return Review{}, fmt.Errorf("review %s has no payload", msg.RequestID)
+			}
+			return Review{RequestID: msg.RequestID, ReviewRequest: *msg.ReviewRequest}, nil
+		case TypeDismiss:
+			if dismissed != nil {
+				dismissed(msg.RequestID)
+			}
+		case TypeNotice:
+			logger.Info("notice from daemon", "text", logging.Content(msg.Text))
+		case TypeError:
+			return Review{}, fmt.Errorf("daemon error: %s", msg.Error)
+		}
+	}
+}
+
+// Respond answers a review. Overrides need a daemon speaking 1.5 or later.
+func (cl *Client) Respond(requestID string, resp ActionResponse) error {
+	if resp.Action == ActionOverride && cl.c.peerMinor < minorOverride {
+		return fmt.Errorf("%w: %s", ErrUnsupported, resp.Action)
+	}
+	return cl.c.send(message{Type: TypeAction, RequestID: requestID, ActionResponse: &resp})
+}
+
+// Serve answers every review with handler until ctx is done or the
+// connection closes.
+func (cl *Client) Serve(ctx context.Context, handler func(Review) ActionResponse) error {
+	go func() {
+		<-ctx.Done()
+		cl.Close()
+	}()
+
+	for {
+		review, err := cl.Next(nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := cl.Respond(review.RequestID, handler(review)); err != nil {
+			return err
+		}
+	}
+}
+
+// Close disconnects from the daemon.
+func (cl *Client) Close() error {
+	return cl.c.nc.Close()
+}
@@ -0,0 +1,266 @@
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// ErrNotRunning indicates nothing is listening on the control socket.
+var ErrNotRunning = errors.New("hemingway-guard is not running")
+
+// controlSocketName is the control socket's file name inside SocketDir.
+const controlSocketName = "control.sock"
+
+// controlTimeout bounds one control request, from connect to reply.
+const controlTimeout = 5 * time.Second
+
+// DefaultControlSocketPath is where the CLI reaches the running daemon.
+func DefaultControlSocketPath() string {
+	return filepath.Join(SocketDir(), controlSocketName)
+}
+
+// ControlCommand is a request to the running daemon.
+type ControlCommand string
+
+const (
+	ControlStatus     ControlCommand = "status"
+	ControlPause      ControlCommand = "pause"
+	ControlResume     ControlCommand = "resume"
+	ControlReload     ControlCommand = "reload"
+	ControlEnableApp  ControlCommand = "enable_app"
+	ControlDisableApp ControlCommand = "disable_app"
+)
+
+// ControlRequest is one line sent to the control socket. The connection
+// carries a single request and its response.
+type ControlRequest struct {
+	Command ControlCommand `json:"command"`
+	// Until is when a pause ends; without it the pause lasts until
+	// resumed.
+	Until *time.Time `json:"until,omitempty"`
+	// BundleID names the app for enable_app and disable_app.
+	BundleID string `json:"bundle_id,omitempty"`
+}
+
+// ControlResponse answers a ControlRequest with the daemon's state after
+// carrying it out, or an error.
+type ControlResponse struct {
+	Status *Status `json:"status,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Status is the running daemon's state.
+type Status struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// Mode is "active", "off", "snoozed" or "outside_schedule".
+	Mode string `json:"mode"`
+	// Enabled is the menu bar toggle.
+	Enabled bool `json:"enabled"`
+	// PausedUntil is when a timed pause ends; a pause without one lasts
+	// until resumed.
+	Paused      bool       `json:"paused"`
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	// Active is whether messages are being checked at all.
+	Active bool `json:"active"`
+	// NextChange is when the mode may change by itself, because a pause
+	// ends or a schedule window opens or closes.
+	NextChange       *time.Time  `json:"next_change,omitempty"`
+	Apps             []AppStatus `json:"apps"`
+	PopoverConnected bool        `json:"popover_connected"`
+}
+
+// AppStatus is whether messages in one app are checked.
+type AppStatus struct {
+	Name     string `json:"name"`
+	BundleID string `json:"bundle_id"`
+	// Enabled is false for apps turned off by hand.
+	Enabled bool `json:"enabled"`
+	// Checked is whether messages in the app are checked right now, after
+	// pauses and the schedule.
+	Checked bool `json:"checked"`
+	// Enforcement is what happens to messages that aren't approved:
+	// "observe", "advise", "gate" or "block".
+	Enforcement string `json:"enforcement"`
+}
+
+// Controller carries out control requests in the daemon.
+type Controller interface {
+	Status() Status
+	// Pause stops checking messages until the given time, or until Resume
+	// if it is zero.
+	Pause(until time.Time)
+	Resume()
+	// Reload re-reads the config file.
+	Reload() error
+	SetAppEnabled(bundleID string, enabled bool) error
+}
+
+// ControlServer accepts control requests on a Unix socket. Like the
+// popover socket, only processes running as the same user may connect.
+type ControlServer struct {
+	path string
+	ctrl Controller
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+}
+
+// NewControlServer creates a server that will listen on path and pass
+// requests to ctrl.
+func NewControlServer(path string, ctrl Controller) *ControlServer {
+	return &ControlServer{path: path, ctrl: ctrl}
+}
+
+// Path returns the socket path.
+func (s *ControlServer) Path() string {
+	return s.path
+}
+
+// Listen creates the socket. Call Serve to accept connections.
+func (s *ControlServer) Listen() error {
+	ln, err := listenUnix(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+	return nil
+}
+
+// Serve accepts control connections until ctx is done or Close is called.
+func (s *ControlServer) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln == nil {
+		return errors.New("control server not listening")
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.handle(nc)
+	}
+}
+
+// Close stops accepting connections and removes the socket.
+func (s *ControlServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	os.Remove(s.path)
+	return err
+}
+
+func (s *ControlServer) handle(nc net.Conn) {
+	defer nc.Close()
+	if err := checkPeer(nc); err != nil {
+		logger.Warn("rejected control connection", logging.Err(err))
+		return
+	}
+	nc.SetDeadline(time.Now().Add(controlTimeout))
+
+	var req ControlRequest
+	if err := json.NewDecoder(io.LimitReader(nc, maxLineBytes)).Decode(&req); err != nil {
+		json.NewEncoder(nc).Encode(ControlResponse{Error: "malformed request"})
+		return
+	}
+
+	resp := ControlResponse{}
+	if err := s.apply(req); err != nil {
+		resp.Error = err.Error()
+	} else {
+		status := s.ctrl.Status()
+		resp.Status = &status
+	}
+	logger.Info("control request", "command", req.Command, "bundle_id", req.BundleID, "ok", resp.Error == "")
+	json.NewEncoder(nc).Encode(resp)
+}
+
+func (s *ControlServer) apply(req ControlRequest) error {
+	switch req.Command {
+	case ControlStatus:
+		return nil
+	case ControlPause:
+		var until time.Time
+		if req.Until != nil {
+			until = *req.Until
+			if !until.After(time.Now()) {
+				return errors.New("pause must end in the future")
+			}
+		}
+		s.ctrl.Pause(until)
+		return nil
+	case ControlResume:
+		s.ctrl.Resume()
+		return nil
+	case ControlReload:
+		return s.ctrl.Reload()
+	case ControlEnableApp, ControlDisableApp:
+		return s.ctrl.SetAppEnabled(req.BundleID, req.Command == ControlEnableApp)
+	}
+	return fmt.Errorf("unknown command %q", req.Command)
+}
+
+// SendControl sends req to the daemon listening on path and returns its
+// state afterwards. It returns ErrNotRunning if nothing is listening.
+func SendControl(path string, req ControlRequest) (*Status, error) {
+	nc, err := net.DialTimeout("unix", path, time.Second)
+	if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+		return nil, ErrNotRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(controlTimeout))
+
+	if err := json.NewEncoder(nc).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp ControlResponse
+	if err := json.NewDecoder(io.LimitReader(nc, maxLineBytes)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("reading reply: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.Status == nil {
+		return nil, errors.New("reply has no status")
+	}
+	return resp.Status, nil
+}
@@ -0,0 +1,215 @@
+//go:build linux || darwin
+
+package ipc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeController records what the control server asks of it.
+type fakeController struct {
+	mu        sync.Mutex
+	paused    bool
+	until     time.Time
+	reloads   int
+	reloadErr error
+	disabled  map[string]bool
+}
+
+func (c *fakeController) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := Status{Mode: "active", Enabled: true, Active: !c.paused, Paused: c.paused, Apps: []AppStatus{}}
+	if c.paused {
+		st.Mode = "snoozed"
+	}
+	if !c.until.IsZero() {
+		until := c.until
+		st.PausedUntil = &until
+	}
+	for _, id := range []string{"com.example.chat", "com.example.mail"} {
+		st.Apps = append(st.Apps, AppStatus{BundleID: id, Enabled: !c.disabled[id]})
+	}
+	return st
+}
+
+func (c *fakeController) Pause(until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused, c.until = true, until
+}
+
+func (c *fakeController) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused, c.until = false, time.Time{}
+}
+
+func (c *fakeController) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloads++
+	return c.reloadErr
+}
+
+func (c *fakeController) SetAppEnabled(bundleID string, enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !strings.HasPrefix(bundleID, "com.example.") {
+		return errors.New("not a monitored app")
+	}
+	c.disabled[bundleID] = !enabled
+	return nil
+}
+
+// state returns what the controller was last asked to do.
+func (c *fakeController) state() (paused bool, until time.Time, reloads int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused, c.until, c.reloads
+}
+
+func (c *fakeController) failReload(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloadErr = err
+}
+
+// startControl serves ctrl on a socket in a fresh directory until the test
+// ends, and returns the socket's path.
+func startControl(t *testing.T, ctrl Controller) string {
+	t.Helper()
+	s := NewControlServer(filepath.Join(t.TempDir(), "hg", controlSocketName), ctrl)
+	if err := s.Listen(); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background()) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+	return s.Path()
+}
+
+func TestControl(t *testing.T) {
+	ctrl := &fakeController{disabled: make(map[string]bool)}
+	path := startControl(t, ctrl)
+	later := time.Now().Add(30 * time.Minute).Round(time.Second)
+	earlier := time.Now().Add(-time.Minute)
+
+	// The steps share the controller, in order
+	steps := []struct {
+		name    string
+		req     ControlRequest
+		wantErr string
+		check   func(*Status) bool
+	}{
+		{"status", ControlRequest{Command: ControlStatus},
+			"", func(st *Status) bool { return st.Active && len(st.Apps) == 2 }},
+		{"pause until resumed", ControlRequest{Command: ControlPause},
+			"", func(st *Status) bool { return st.Paused && st.PausedUntil == nil }},
+		{"pause for a while", ControlRequest{Command: ControlPause, Until: &later},
+			"", func(st *Status) bool { return st.Paused && st.PausedUntil != nil && st.PausedUntil.Equal(later) }},
+		{"pause ending in the past", ControlRequest{Command: ControlPause, Until: &earlier},
+			"pause must end in the future", nil},
+		{"resume", ControlRequest{Command: ControlResume},
+			"", func(st *Status) bool { return !st.Paused && st.Active && st.PausedUntil == nil }},
+		{"reload", ControlRequest{Command: ControlReload},
+			"", func(*Status) bool { _, _, reloads := ctrl.state(); return reloads == 1 }},
+		{"disable app", ControlRequest{Command: ControlDisableApp, BundleID: "com.example.chat"},
+			"", func(st *Status) bool { return !st.Apps[0].Enabled && st.Apps[1].Enabled }},
+		{"enable app", ControlRequest{Command: ControlEnableApp, BundleID: "com.example.chat"},
+			"", func(st *Status) bool { return st.Apps[0].Enabled }},
+		{"unmonitored app", ControlRequest{Command: ControlDisableApp, BundleID: "com.other.app"},
+			"not a monitored app", nil},
+		{"unknown command", ControlRequest{Command: "explode"},
+			`unknown command "explode"`, nil},
+	}
+	for _, step := range steps {
+		st, err := SendControl(path, step.req)
+		switch {
+		case step.wantErr != "":
+			if err == nil || err.Error() != step.wantErr || st != nil {
+				t.Errorf("%s: %+v, %v; want error %q", step.name, st, err, step.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", step.name, err)
+		case !step.check(st):
+			t.Errorf("%s: status %+v", step.name, st)
+		}
+	}
+
+	// A failed pause changes nothing
+	if paused, until, _ := ctrl.state(); paused || !until.IsZero() {
+		t.Errorf("controller paused %v until %v after resuming", paused, until)
+	}
+
+	ctrl.failReload(errors.New("config: bad JSON"))
+	if _, err := SendControl(path, ControlRequest{Command: ControlReload}); err == nil || err.Error() != "config: bad JSON" {
+		t.Errorf("failed reload = %v, want the controller's error", err)
+	}
+}
+
+func TestControlMalformedRequest(t *testing.T) {
+	path := startControl(t, &fakeController{disabled: make(map[string]bool)})
+	nc, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	if _, err := nc.Write([]byte("pause please\n")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	n, _ := nc.Read(buf)
+	if got := strings.TrimSpace(string(buf[:n])); got != `{"error":"malformed request"}` {
+		t.Errorf("reply = %s", got)
+	}
+}
+
+func TestSendControlNotRunning(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := SendControl(filepath.Join(dir, "missing.sock"), ControlRequest{Command: ControlStatus}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("no socket: %v, want ErrNotRunning", err)
+	}
+
+	// A socket left behind by a daemon that died
+	stale := filepath.Join(dir, "stale.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: stale, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := SendControl(stale, ControlRequest{Command: ControlStatus}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("stale socket: %v, want ErrNotRunning", err)
+	}
+}
+
+func TestControlServeCancel(t *testing.T) {
+	s := NewControlServer(filepath.Join(t.TempDir(), "hg", controlSocketName), &fakeController{})
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+	cancel()
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, want ErrServerClosed", err)
+	}
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Errorf("socket still there after Serve returned: %v", err)
+	}
+}
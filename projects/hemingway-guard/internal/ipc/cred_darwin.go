@@ -0,0 +1,45 @@
+package ipc
+
+/*
+#include <sys/types.h>
+#include <unistd.h>
+*/
+import "C"
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// peerUID returns the uid of the process on the other end (getpeereid).
+func peerUID(nc net.Conn) (int, error) {
+	raw, err := rawConn(nc)
+	if err != nil {
+		return -1, err
+	}
+
+	var uid C.uid_t
+	var gid C.gid_t
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		if rc, errno := C.getpeereid(C.int(fd), &uid, &gid); rc != 0 {
+			credErr = errno
+		}
+	})
+	if err != nil {
+		return -1, err
+	}
+	if credErr != nil {
+		return -1, credErr
+	}
+	return int(uid), nil
+}
+
+func fileOwner(fi os.FileInfo) (int, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, false
+	}
+	return int(st.Uid), true
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package ipc
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// peerUID fails closed where peer credentials aren't implemented.
+func peerUID(net.Conn) (int, error) {
+	return -1, errors.New("peer credentials not supported on this platform")
+}
+
+func fileOwner(os.FileInfo) (int, bool) {
+	return -1, false
+}
@@ -0,0 +1,191 @@
+// Package ipc connects the daemon to the approval popover over a Unix socket.
+//
+// Messages are newline-delimited JSON objects defined by
+// schema/protocol.schema.json. Each side opens with a "hello" carrying its
+// protocol version. The daemon then sends a "review" for each held message;
+// the popover answers with an "action" carrying the same request ID. If the
+// user doesn't answer in time, the daemon sends "dismiss" so the popover can
+// close. The daemon may also send a "notice" for the popover to show.
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc/schema"
+)
+
+// ProtocolVersion is the "major.minor" version this build speaks. Peers must
+// share the major version; see schema/README.md for what each bump allows.
+const ProtocolVersion = "1.6"
+
+// Minor versions that added what the daemon sends. Peers whose hello shows
+// an older version don't get them; see schema/README.md.
+const (
+	minorNotice      = 2 // notice messages
+	minorFindings    = 3 // analysis findings
+	minorReplacement = 4 // finding replacements
+	minorOverride    = 5 // the override action, block severity and blocked flag
+	minorActions     = 6 // review actions
+)
+
+// ErrUnsupported indicates the peer's protocol version predates a message.
+var ErrUnsupported = errors.New("not supported by the peer's protocol version")
+
+func init() {
+	if v := schema.Version(); v != ProtocolVersion {
+		panic(fmt.Sprintf("ipc: ProtocolVersion %s does not match schema version %s", ProtocolVersion, v))
+	}
+}
+
+// Message types.
+const (
+	TypeHello   = "hello"
+	TypeReview  = "review"
+	TypeAction  = "action"
+	TypeDismiss = "dismiss"
+	TypeNotice  = "notice"
+	TypeError   = "error"
+)
+
+// Roles announced in hello messages.
+const (
+	RoleDaemon  = "daemon"
+	RolePopover = "popover"
+)
+
+// Action is the user's choice in the popover.
+type Action string
+
+// Actions offered by the popover.
+const (
+	ActionSendAnyway    Action = "send_anyway"
+	ActionUseSuggestion Action = "use_suggestion"
+	ActionEdit          Action = "edit"
+	ActionCancel        Action = "cancel"
+	// ActionOverride sends a blocked message. Send anyway doesn't.
+	ActionOverride Action = "override"
+)
+
+// Anchor is where to show the popover, in AppKit screen coordinates.
+type Anchor struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ReviewRequest asks the popover to show an analysis.
+type ReviewRequest struct {
+	Analysis     *analyzer.Analysis `json:"analysis"`
+	OriginalText string             `json:"original_text"`
+	Anchor       *Anchor            `json:"anchor,omitempty"`
+	// Actions are the choices the popover may offer. Empty offers every
+	// choice that fits the analysis.
+	Actions []Action `json:"actions,omitempty"`
+}
+
+// Offers reports whether the review lets the user choose action. Cancel is
+// always offered. Without a list, everything is except sending a blocked
+// message any way but an override.
+func (r ReviewRequest) Offers(action Action) bool {
+	switch {
+	case action == ActionCancel:
+		return true
+	case len(r.Actions) > 0:
+		return slices.Contains(r.Actions, action)
+	}
+	return action != ActionSendAnyway || r.Analysis == nil || !r.Analysis.Blocked
+}
+
+// forPeer returns the review as a peer speaking minor version minor can
+// read it. Fields it doesn't know are left out, and block findings become
+// errors. Offers still answers for the full review, so the daemon refuses
+// choices it didn't mean to offer whatever an older popover shows.
+func (r ReviewRequest) forPeer(minor int) ReviewRequest {
+	if minor < minorActions {
+		r.Actions = nil
+	}
+	if r.Analysis == nil || minor >= minorOverride {
+		return r
+	}
+
+	a := *r.Analysis
+	a.Blocked = false
+	a.Findings = nil
+	if minor >= minorFindings {
+		for _, f := range r.Analysis.Findings {
+			f.Severity = min(f.Severity, analyzer.SeverityError)
+			if minor < minorReplacement {
+				f.Replacement = nil
+			}
+			a.Findings = append(a.Findings, f)
+		}
+	}
+	r.Analysis = &a
+	return r
+}
+
+// ActionResponse is the popover's answer to a review.
+type ActionResponse struct {
+	Action     Action `json:"action"`
+	EditedText string `json:"edited_text,omitempty"`
+}
+
+// Hello opens a connection in each direction. The popover includes the
+// shared secret when the daemon was configured with one.
+type Hello struct {
+	ProtocolVersion string `json:"protocol_version"`
+	Role            string `json:"role,omitempty"`
+	Token           string `json:"token,omitempty"`
+}
+
+// message is one line on the socket. Which payload is set depends on Type.
+type message struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Text      string `json:"text,omitempty"` // notice
+
+	*Hello
+	*ReviewRequest
+	*ActionResponse
+}
+
+// compatible reports whether a peer's version shares our major version.
+func compatible(peerVersion string) error {
+	peerMajor, err := majorVersion(peerVersion)
+	if err != nil {
+		return err
+	}
+	ourMajor, _ := majorVersion(ProtocolVersion)
+	if peerMajor != ourMajor {
+		return fmt.Errorf("protocol version %s is incompatible with %s", peerVersion, ProtocolVersion)
+	}
+	return nil
+}
+
+func majorVersion(v string) (int, error) {
+	major, _, ok := strings.Cut(v, ".")
+	if !ok {
+		return 0, fmt.Errorf("malformed protocol version %q", v)
+	}
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("malformed protocol version %q", v)
+	}
+	return n, nil
+}
+
+// minorVersion returns the minor part of a version that passed compatible,
+// or 0 if it doesn't parse.
+func minorVersion(v string) int {
+	_, minor, _ := strings.Cut(v, ".")
+	n, err := strconv.Atoi(minor)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,258 @@
+// Package schema holds the popover protocol definition shared by the Go
+// daemon and the Swift popover, and validates messages against it.
+//
+// protocol.schema.json is the source of truth. The validator understands the
+// subset of JSON Schema the file uses: type, const, enum, required,
+// properties, items, minimum, minLength, pattern, oneOf and local $refs.
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//go:embed protocol.schema.json
+var schemaJSON []byte
+
+// ErrUnknownType indicates a message type this version doesn't define.
+// Receivers should ignore such messages rather than fail.
+var ErrUnknownType = errors.New("unknown message type")
+
+type node struct {
+	Ref        string           `json:"$ref"`
+	Type       any              `json:"type"`
+	Const      any              `json:"const"`
+	Enum       []any            `json:"enum"`
+	Required   []string         `json:"required"`
+	Properties map[string]*node `json:"properties"`
+	Items      *node            `json:"items"`
+	Minimum    *float64         `json:"minimum"`
+	MinLength  *int             `json:"minLength"`
+	Pattern    string           `json:"pattern"`
+	OneOf      []*node          `json:"oneOf"`
+
+	Version string           `json:"x-protocol-version"`
+	Defs    map[string]*node `json:"$defs"`
+}
+
+var root = mustParse(schemaJSON)
+
+// patterns caches compiled "pattern" regexps.
+var patterns sync.Map
+
+func mustParse(data []byte) *node {
+	var n node
+	if err := json.Unmarshal(data, &n); err != nil {
+		panic(fmt.Sprintf("schema: invalid protocol.schema.json: %v", err))
+	}
+	return &n
+}
+
+// Raw returns the schema document.
+func Raw() []byte {
+	return schemaJSON
+}
+
+// Version returns the protocol version declared by the schema.
+func Version() string {
+	return root.Version
+}
+
+// Validate checks one message against the schema definition for its "type"
+// and returns that type.
+func Validate(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return "", errors.New("message must be a JSON object")
+	}
+	msgType, _ := obj["type"].(string)
+	if msgType == "" {
+		return "", errors.New("message has no type")
+	}
+
+	def, ok := root.Defs[msgType]
+	if !ok {
+		return msgType, fmt.Errorf("%w %q", ErrUnknownType, msgType)
+	}
+	if err := validate(def, value, msgType); err != nil {
+		return msgType, err
+	}
+	return msgType, nil
+}
+
+func resolve(n *node) (*node, error) {
+	for n.Ref != "" {
+		name, ok := strings.CutPrefix(n.Ref, "#/$defs/")
+		if !ok {
+			return nil, fmt.Errorf("unsupported $ref %q", n.Ref)
+		}
+		def, ok := root.Defs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown $ref %q", n.Ref)
+		}
+		n = def
+	}
+	return n, nil
+}
+
+func validate(n *node, value any, path string) error {
+	n, err := resolve(n)
+	if err != nil {
+		return err
+	}
+
+	if len(n.OneOf) > 0 {
+		matches := 0
+		for _, option := range n.OneOf {
+			if validate(option, value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of the allowed shapes, want exactly 1", path, matches)
+		}
+	}
+
+	if n.Type != nil && !hasType(n.Type, value) {
+		return fmt.Errorf("%s: want %v, got %s", path, n.Type, typeName(value))
+	}
+	if n.Const != nil && !equal(n.Const, value) {
+		return fmt.Errorf("%s: want %v", path, n.Const)
+	}
+	if len(n.Enum) > 0 {
+		found := false
+		for _, allowed := range n.Enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, n.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range n.Required {
+			if _, ok := v[key]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, key)
+			}
+		}
+		// Unknown fields are allowed so newer peers can add optional fields
+		for key, prop := range n.Properties {
+			if child, ok := v[key]; ok {
+				if err := validate(prop, child, path+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if n.Items != nil {
+			for i, item := range v {
+				if err := validate(n.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case json.Number:
+		if n.Minimum != nil {
+			if f, err := v.Float64(); err == nil && f < *n.Minimum {
+				return fmt.Errorf("%s: %v is below the minimum %v", path, v, *n.Minimum)
+			}
+		}
+	case string:
+		if n.MinLength != nil && len([]rune(v)) < *n.MinLength {
+			return fmt.Errorf("%s: shorter than %d characters", path, *n.MinLength)
+		}
+		if n.Pattern != "" {
+			re, err := compilePattern(n.Pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %w", path, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: %q does not match %s", path, v, n.Pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func hasType(want any, value any) bool {
+	switch t := want.(type) {
+	case string:
+		return isType(t, value)
+	case []any:
+		for _, option := range t {
+			if name, ok := option.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isType(name string, value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case []any:
+		return name == "array"
+	case map[string]any:
+		return name == "object"
+	case json.Number:
+		if name == "number" {
+			return true
+		}
+		_, err := v.Int64()
+		return name == "integer" && err == nil
+	}
+	return false
+}
+
+func typeName(value any) string {
+	for _, name := range []string{"null", "boolean", "string", "array", "object", "integer", "number"} {
+		if isType(name, value) {
+			return name
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func equal(schemaValue, value any) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		want, isNum := schemaValue.(float64)
+		return err == nil && isNum && f == want
+	}
+	return schemaValue == value
+}
@@ -0,0 +1,398 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/ipc/schema"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
+)
+
+// ErrNoClient indicates no popover is connected to review a message.
+var ErrNoClient = errors.New("no popover connected")
+
+// ErrTimeout indicates the user didn't answer a review in time.
+var ErrTimeout = errors.New("review timed out")
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("ipc server closed")
+
+// logger logs connections and protocol errors. Message text is never logged.
+var logger = logging.Component("ipc")
+
+// maxLineBytes bounds a single message; reviews carry the whole message text.
+const maxLineBytes = 1 << 20
+
+// handshakeTimeout bounds how long a new connection may take to say hello.
+const handshakeTimeout = 5 * time.Second
+
+// Server accepts popover connections and routes reviews to them. Only
+// processes running as the same user may connect.
+type Server struct {
+	path    string
+	timeout time.Duration
+	token   string
+
+	mu       sync.Mutex
+	listener net.Listener
+	clients  []*conn
+	pending  map[string]chan ActionResponse
+	closed   bool
+
+	nextID atomic.Uint64
+}
+
+// NewServer creates a server that will listen on the given socket path.
+func NewServer(path string) *Server {
+	return &Server{
+		path:    path,
+		timeout: 2 * time.Minute,
+		pending: make(map[string]chan ActionResponse),
+	}
+}
+
+// SetTimeout sets how long RequestAction waits for the user.
+func (s *Server) SetTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeout = d
+}
+
+// SetToken requires popovers to present token in their hello. An empty
+// token disables the check.
+func (s *Server) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
+
+// Path returns the socket path.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Listen creates the socket in a private directory. It returns
+// ErrSocketInUse if another daemon is already listening. Call Serve to
+// accept connections.
+func (s *Server) Listen() error {
+	ln, err := listenUnix(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+	return nil
+}
+
+// Serve accepts popover connections until ctx is done or Close is called.
+func (s *Server) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln == nil {
+		return errors.New("ipc server not listening")
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		go s.handle(newConn(nc))
+	}
+}
+
+// HasClient reports whether a popover is connected.
+func (s *Server) HasClient() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients) > 0
+}
+
+// RequestAction shows a review in the popover and waits for the user's
+// answer, the server timeout, or ctx to end. On timeout or cancellation the
+// popover is told to dismiss the review.
+func (s *Server) RequestAction(ctx context.Context, req ReviewRequest) (ActionResponse, error) {
+	id := strconv.FormatUint(s.nextID.Add(1), 10)
+	reply := make(chan ActionResponse, 1)
+
+	s.mu.Lock()
+	timeout := s.timeout
+	var c *conn
+	if n := len(s.clients); n > 0 {
+		c = s.clients[n-1] // most recently connected popover
+	}
+	if c != nil {
+		s.pending[id] = reply
+	}
+	s.mu.Unlock()
+
+	if c == nil {
+		return ActionResponse{}, ErrNoClient
+	}
+	defer s.forget(id)
+
+	// The schema requires an array; Swift can't decode null
+	if req.Analysis != nil && req.Analysis.Issues == nil {
+		analysis := *req.Analysis
+		analysis.Issues = []string{}
+		req.Analysis = &analysis
+	}
+
+	sent := req.forPeer(c.peerMinor)
+	if err := c.send(message{Type: TypeReview, RequestID: id, ReviewRequest: &sent}); err != nil {
+		metrics.IPCErrors.With("send").Inc()
+		return ActionResponse{}, fmt.Errorf("failed to send review: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-reply:
+		return resp, nil
+	case <-timer.C:
+		c.send(message{Type: TypeDismiss, RequestID: id})
+		metrics.ReviewTimeouts.Inc()
+		return ActionResponse{}, ErrTimeout
+	case <-ctx.Done():
+		c.send(message{Type: TypeDismiss, RequestID: id})
+		return ActionResponse{}, ctx.Err()
+	}
+}
+
+// Notify shows a notice in the most recently connected popover. Popovers
+// older than protocol 1.2 can't show notices; Notify returns ErrUnsupported.
+func (s *Server) Notify(text string) error {
+	s.mu.Lock()
+	var c *conn
+	if n := len(s.clients); n > 0 {
+		c = s.clients[n-1]
+	}
+	s.mu.Unlock()
+
+	if c == nil {
+		return ErrNoClient
+	}
+	if c.peerMinor < minorNotice {
+		return ErrUnsupported
+	}
+	return c.send(message{Type: TypeNotice, Text: text})
+}
+
+// Close stops accepting connections and disconnects all popovers.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	ln := s.listener
+	clients := s.clients
+	s.clients = nil
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.close()
+	}
+
+	var err error
+	if ln != nil {
+		err = ln.Close()
+		os.Remove(s.path)
+	}
+	return err
+}
+
+// handle runs the handshake, then routes the popover's answers until it
+// disconnects.
+func (s *Server) handle(c *conn) {
+	defer c.close()
+
+	// Say nothing to other users' processes, not even an error
+	if err := checkPeer(c.nc); err != nil {
+		logger.Warn("rejected connection", logging.Err(err))
+		metrics.IPCErrors.With("rejected").Inc()
+		return
+	}
+
+	if err := s.handshake(c); err != nil {
+		logger.Warn("rejected connection", logging.Err(err))
+		metrics.IPCErrors.With("rejected").Inc()
+		c.send(message{Type: TypeError, Error: err.Error()})
+		return
+	}
+
+	s.addClient(c)
+	defer s.removeClient(c)
+	s.readLoop(c)
+}
+
+func (s *Server) handshake(c *conn) error {
+	c.nc.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer c.nc.SetReadDeadline(time.Time{})
+
+	msg, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if msg.Type != TypeHello || msg.Hello == nil {
+		return fmt.Errorf("expected hello, got %q", msg.Type)
+	}
+	if err := compatible(msg.ProtocolVersion); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+	if token != "" && subtle.ConstantTimeCompare([]byte(msg.Token), []byte(token)) != 1 {
+		return fmt.Errorf("%w: bad token", ErrPeerRejected)
+	}
+	c.peerMinor = minorVersion(msg.ProtocolVersion)
+
+	return c.send(message{Type: TypeHello, Hello: &Hello{ProtocolVersion: ProtocolVersion, Role: RoleDaemon}})
+}
+
+func (s *Server) readLoop(c *conn) {
+	for {
+		msg, err := c.receive()
+		if err != nil {
+			return
+		}
+
+		if msg.Type != TypeAction || msg.ActionResponse == nil {
+			logger.Warn("ignoring message", "type", msg.Type)
+			metrics.IPCErrors.With("bad_message").Inc()
+			continue
+		}
+
+		s.mu.Lock()
+		reply, ok := s.pending[msg.RequestID]
+		delete(s.pending, msg.RequestID)
+		s.mu.Unlock()
+
+		if !ok {
+			logger.Warn("action for unknown or expired request", "request_id", msg.RequestID)
+			metrics.IPCErrors.With("bad_message").Inc()
+			continue
+		}
+		reply <- *msg.ActionResponse
+	}
+}
+
+func (s *Server) addClient(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = append(s.clients, c)
+	metrics.PopoverClients.Set(float64(len(s.clients)))
+	logger.Info("popover connected", "clients", len(s.clients))
+}
+
+func (s *Server) removeClient(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.clients {
+		if existing == c {
+			s.clients = append(s.clients[:i], s.clients[i+1:]...)
+			metrics.PopoverClients.Set(float64(len(s.clients)))
+			logger.Info("popover disconnected", "clients", len(s.clients))
+			return
+		}
+	}
+}
+
+func (s *Server) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+}
+
+// conn is one side of a socket speaking newline-delimited JSON.
+type conn struct {
+	nc      net.Conn
+	scanner *bufio.Scanner
+	// peerMinor is the minor protocol version from the peer's hello. It is
+	// set during the handshake, before the conn is shared.
+	peerMinor int
+
+	writeMu sync.Mutex
+}
+
+func newConn(nc net.Conn) *conn {
+	scanner := bufio.NewScanner(nc)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	return &conn{nc: nc, scanner: scanner}
+}
+
+func (c *conn) send(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := schema.Validate(data); err != nil {
+		return fmt.Errorf("refusing to send invalid %s message: %w", msg.Type, err)
+	}
+	data = append(data, '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.nc.Write(data)
+	return err
+}
+
+func (c *conn) receive() (message, error) {
+	for c.scanner.Scan() {
+		line := c.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if msgType, err := schema.Validate(line); err != nil {
+			if errors.Is(err, schema.ErrUnknownType) {
+				// Sent by a newer peer; safe to skip
+				logger.Info("ignoring unknown message type", "type", msgType)
+				continue
+			}
+			return message{}, fmt.Errorf("invalid message: %w", err)
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return message{}, fmt.Errorf("invalid message: %w", err)
+		}
+		return msg, nil
+	}
+	if err := c.scanner.Err(); err != nil {
+		return message{}, err
+	}
+	return message{}, errors.New("connection closed")
+}
+
+func (c *conn) close() {
+	c.nc.Close()
+}
@@ -0,0 +1,139 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrSocketInUse indicates another daemon is already listening on the socket.
+var ErrSocketInUse = errors.New("socket is in use by another process")
+
+// ErrPeerRejected indicates a connection from another user or without the
+// shared secret.
+var ErrPeerRejected = errors.New("peer rejected")
+
+// socketName is the socket's file name inside SocketDir.
+const socketName = "popover.sock"
+
+// SocketDir returns the per-user directory holding the socket. It prefers
+// $XDG_RUNTIME_DIR and otherwise uses a uid-suffixed directory in the
+// temp dir, which on macOS is already private to the user.
+func SocketDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "hemingway-guard")
+	}
+	return filepath.Join(os.TempDir(), "hemingway-guard-"+strconv.Itoa(os.Getuid()))
+}
+
+// DefaultSocketPath is where the popover helper expects the daemon.
+func DefaultSocketPath() string {
+	return filepath.Join(SocketDir(), socketName)
+}
+
+// prepareSocketDir creates dir with mode 0700, or checks that an existing
+// one is a real directory owned by us and tightens its permissions.
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	}
+	if uid, ok := fileOwner(fi); ok && uid != os.Getuid() {
+		return fmt.Errorf("socket directory %s is owned by uid %d", dir, uid)
+	}
+	if fi.Mode().Perm() != 0o700 {
+		if err := os.Chmod(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to restrict socket directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// listenUnix creates a socket at path that only the user can connect to.
+func listenUnix(path string) (net.Listener, error) {
+	if err := prepareSocketDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	// A socket file left by a crashed run blocks the bind
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict socket: %w", err)
+	}
+	return ln, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a crashed daemon.
+// A socket something still answers on is left alone, and so is anything
+// that isn't a socket.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	nc, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		nc.Close()
+		return fmt.Errorf("%w: %s", ErrSocketInUse, path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) && !errors.Is(err, syscall.ENOENT) {
+		return fmt.Errorf("failed to probe old socket: %w", err)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
+
+// checkPeer rejects connections from processes owned by other users.
+func checkPeer(nc net.Conn) error {
+	return checkPeerUID(nc, os.Getuid())
+}
+
+// checkPeerUID rejects connections from processes not owned by want.
+func checkPeerUID(nc net.Conn, want int) error {
+	uid, err := peerUID(nc)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPeerRejected, err)
+	}
+	if uid != want {
+		return fmt.Errorf("%w: uid %d", ErrPeerRejected, uid)
+	}
+	return nil
+}
+
+// rawConn returns the file descriptor access for a Unix socket connection.
+func rawConn(nc net.Conn) (syscall.RawConn, error) {
+	uc, ok := nc.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("not a unix socket: %T", nc)
+	}
+	return uc.SyscallConn()
+}
@@ -0,0 +1,199 @@
+//go:build linux || darwin
+
+package ipc
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenRestrictsPermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hg")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, socketName)
+
+	ln, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	defer ln.Close()
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o700 {
+		t.Errorf("socket directory mode = %o, want 700", perm)
+	}
+	fi, err = os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		t.Errorf("%s is not a socket", path)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+}
+
+func TestListenCreatesDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	ln, err := listenUnix(filepath.Join(dir, socketName))
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	defer ln.Close()
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o700 {
+		t.Errorf("new socket directory mode = %o, want 700", perm)
+	}
+}
+
+func TestListenRejectsUnsafeDirectory(t *testing.T) {
+	base := t.TempDir()
+	real := filepath.Join(base, "real")
+	if err := os.Mkdir(real, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(filepath.Join(link, socketName)); err == nil {
+		t.Error("listened in a symlinked directory")
+	}
+
+	if os.Getuid() != 0 {
+		return // only root can give the directory away
+	}
+	other := filepath.Join(base, "other")
+	if err := os.Mkdir(other, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(other, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(filepath.Join(other, socketName)); err == nil {
+		t.Error("listened in a directory owned by another user")
+	}
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hg", socketName)
+	ln, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	// Leave the file behind, as a crashed daemon would
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatalf("stale socket missing: %v", err)
+	}
+
+	ln, err = listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix over a stale socket: %v", err)
+	}
+	ln.Close()
+}
+
+func TestListenKeepsLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hg", socketName)
+	ln, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	defer ln.Close()
+
+	if _, err := listenUnix(path); !errors.Is(err, ErrSocketInUse) {
+		t.Fatalf("second listenUnix = %v, want ErrSocketInUse", err)
+	}
+	if nc, err := net.Dial("unix", path); err != nil {
+		t.Errorf("first listener lost its socket: %v", err)
+	} else {
+		nc.Close()
+	}
+}
+
+func TestListenKeepsOtherFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hg")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, socketName)
+	if err := os.WriteFile(path, []byte("not a socket"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := listenUnix(path); err == nil {
+		t.Fatal("listened over a regular file")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "not a socket" {
+		t.Errorf("regular file changed: %q, %v", data, err)
+	}
+}
+
+// acceptOne returns both ends of a connection to a fresh socket.
+func acceptOne(t *testing.T) (server, client net.Conn) {
+	t.Helper()
+	ln, err := listenUnix(filepath.Join(t.TempDir(), "hg", socketName))
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	defer ln.Close()
+
+	client, err = net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server, err = ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+func TestCheckPeer(t *testing.T) {
+	server, client := acceptOne(t)
+
+	uid, err := peerUID(server)
+	if err != nil {
+		t.Fatalf("peerUID: %v", err)
+	}
+	if uid != os.Getuid() {
+		t.Errorf("peerUID = %d, want %d", uid, os.Getuid())
+	}
+	if err := checkPeer(server); err != nil {
+		t.Errorf("checkPeer rejected our own process: %v", err)
+	}
+	if err := checkPeerUID(server, os.Getuid()+1); !errors.Is(err, ErrPeerRejected) {
+		t.Errorf("checkPeerUID for another user = %v, want ErrPeerRejected", err)
+	}
+	if err := checkPeer(client); err != nil {
+		t.Errorf("checkPeer from the client side: %v", err)
+	}
+}
+
+func TestCheckPeerNeedsUnixSocket(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	if err := checkPeer(a); !errors.Is(err, ErrPeerRejected) {
+		t.Errorf("checkPeer on a pipe = %v, want ErrPeerRejected", err)
+	}
+}
@@ -1,4 +1,3 @@
-// Package keyboard provides CGEventTap wrappers for keystroke interception.
 package keyboard
 
 /*
@@ -40,6 +39,19 @@ static inline CFMachPortRef createEventTap() {
     return tap;
 }
 
+// Report why macOS disabled the tap: 1 for a slow callback, 2 for user
+// input, 0 if the event isn't a tap-disabled notice
+static inline int tapDisabledReason(CGEventType type) {
+    switch (type) {
+    case kCGEventTapDisabledByTimeout:
+        return 1;
+    case kCGEventTapDisabledByUserInput:
+        return 2;
+    default:
+        return 0;
+    }
+}
+
 // Get the key code from a keyboard event
 static inline int64_t getKeyCode(CGEventRef event) {
     return CGEventGetIntegerValueField(event, kCGKeyboardEventKeycode);
@@ -97,41 +109,27 @@ import "C"
 
 import (
 	"sync"
-)
 
-const (
-	// KeyCodeReturn is the key code for the Return key
-	KeyCodeReturn = 36
-	// KeyCodeEnter is the key code for the numpad Enter key
-	KeyCodeEnter = 76
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
 )
 
-// EventCallback is called when a keyboard event is intercepted.
-// Return true to allow the event, false to swallow it.
-type EventCallback func(keyCode int, modifiers Modifiers) bool
-
-// Modifiers represents keyboard modifier keys.
-type Modifiers struct {
-	Shift   bool
-	Command bool
-	Control bool
-	Option  bool
-}
-
+// activeTap is the started tap, re-enabled when macOS switches it off.
 var (
-	eventCallbackMu sync.RWMutex
-	eventCallback   EventCallback
+	activeTapMu sync.Mutex
+	activeTap   *EventTap
 )
 
-// SetEventCallback sets the callback function for keyboard events.
-func SetEventCallback(cb EventCallback) {
-	eventCallbackMu.Lock()
-	defer eventCallbackMu.Unlock()
-	eventCallback = cb
-}
-
 //export goEventCallback
 func goEventCallback(proxy C.CGEventTapProxy, eventType C.CGEventType, event C.CGEventRef) C.CGEventRef {
+	switch C.tapDisabledReason(eventType) {
+	case 1:
+		reenableTap("timeout")
+		return event
+	case 2:
+		reenableTap("user_input")
+		return event
+	}
+
 	keyCode := int(C.getKeyCode(event))
 
 	// Only process Enter/Return keys
@@ -189,6 +187,10 @@ func (t *EventTap) Start() {
 	C.addToRunLoop(t.tap)
 	C.enableEventTap(t.tap)
 	t.enabled = true
+
+	activeTapMu.Lock()
+	activeTap = t
+	activeTapMu.Unlock()
 }
 
 // Stop disables the event tap.
@@ -202,6 +204,32 @@ func (t *EventTap) Stop() {
 
 	C.disableEventTap(t.tap)
 	t.enabled = false
+
+	activeTapMu.Lock()
+	if activeTap == t {
+		activeTap = nil
+	}
+	activeTapMu.Unlock()
+}
+
+// reenableTap switches the started tap back on. macOS disables a tap whose
+// callback is slow, and without this every Enter would go through unchecked.
+func reenableTap(reason string) {
+	activeTapMu.Lock()
+	t := activeTap
+	activeTapMu.Unlock()
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.enabled {
+		return
+	}
+	C.enableEventTap(t.tap)
+	metrics.TapReenables.With(reason).Inc()
+	logger.Warn("event tap disabled by macOS, re-enabled", "reason", reason)
 }
 
 // IsEnabled returns whether the event tap is currently enabled.
@@ -0,0 +1,38 @@
+//go:build !darwin
+
+package keyboard
+
+import (
+	"errors"
+)
+
+// ErrUnsupported indicates keystroke interception isn't available on this
+// platform.
+var ErrUnsupported = errors.New("keyboard interception requires macOS")
+
+// EventTap is unavailable off macOS; NewEventTap always fails.
+type EventTap struct{}
+
+// NewEventTap returns ErrUnsupported.
+func NewEventTap() (*EventTap, error) {
+	return nil, ErrUnsupported
+}
+
+// Start does nothing.
+func (t *EventTap) Start() {}
+
+// Stop does nothing.
+func (t *EventTap) Stop() {}
+
+// IsEnabled always returns false.
+func (t *EventTap) IsEnabled() bool {
+	return false
+}
+
+// PostEnterKey does nothing.
+func PostEnterKey() {}
+
+// PasteText returns ErrUnsupported.
+func PasteText(text string) error {
+	return ErrUnsupported
+}
@@ -3,13 +3,18 @@ package keyboard
 import (
 	"context"
 	"errors"
-	"log"
 	"sync"
+
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/metrics"
 )
 
 // ErrInputMonitoringNotEnabled indicates Input Monitoring permissions are not granted.
 var ErrInputMonitoringNotEnabled = errors.New("input monitoring permissions not enabled")
 
+// logger logs keyboard events. Keystrokes themselves are never logged.
+var logger = logging.Component("keyboard")
+
 // InterceptHandler is called when Enter is pressed in a monitored context.
 // Return true to allow the keystroke, false to block it.
 type InterceptHandler func(ctx context.Context) bool
@@ -20,6 +25,7 @@ type Interceptor struct {
 	eventTap   *EventTap
 	handler    InterceptHandler
 	monitoring bool
+	releasing  int // synthetic Enters to let through untouched
 	ctx        context.Context
 	cancel     context.CancelFunc
 }
@@ -56,7 +62,7 @@ func (i *Interceptor) Start(ctx context.Context) error {
 	SetEventCallback(i.handleKeyEvent)
 	tap.Start()
 
-	log.Println("Keyboard interceptor started")
+	logger.Info("interceptor started")
 	return nil
 }
 
@@ -71,17 +77,24 @@ func (i *Interceptor) handleKeyEvent(keyCode int, modifiers Modifiers) bool {
 		return true
 	}
 
-	i.mu.RLock()
+	i.mu.Lock()
+	if i.releasing > 0 {
+		// This is the Enter we posted in ReleaseEnter
+		i.releasing--
+		i.mu.Unlock()
+		return true
+	}
 	monitoring := i.monitoring
 	handler := i.handler
 	ctx := i.ctx
-	i.mu.RUnlock()
+	i.mu.Unlock()
 
 	if !monitoring {
 		return true // Not monitoring, allow the keystroke
 	}
 
-	log.Println("Intercepted Enter key in monitored context")
+	logger.Debug("intercepted Enter in monitored field")
+	metrics.Intercepts.Inc()
 
 	if handler != nil {
 		// Handler decides whether to allow the keystroke
@@ -97,7 +110,7 @@ func (i *Interceptor) SetMonitoring(monitoring bool) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 	i.monitoring = monitoring
-	log.Printf("Monitoring: %v", monitoring)
+	logger.Debug("monitoring changed", "monitoring", monitoring)
 }
 
 // IsMonitoring returns whether the interceptor is actively monitoring.
@@ -107,9 +120,13 @@ func (i *Interceptor) IsMonitoring() bool {
 	return i.monitoring
 }
 
-// ReleaseEnter posts an Enter key event to send the message.
+// ReleaseEnter posts an Enter key event to send the message. The posted
+// event bypasses the handler.
 func (i *Interceptor) ReleaseEnter() {
-	log.Println("Releasing Enter key")
+	logger.Debug("releasing Enter")
+	i.mu.Lock()
+	i.releasing++
+	i.mu.Unlock()
 	PostEnterKey()
 }
 
@@ -128,5 +145,5 @@ func (i *Interceptor) Stop() {
 	}
 
 	SetEventCallback(nil)
-	log.Println("Keyboard interceptor stopped")
+	logger.Info("interceptor stopped")
 }
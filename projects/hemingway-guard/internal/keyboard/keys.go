@@ -0,0 +1,37 @@
+// Package keyboard provides CGEventTap wrappers for keystroke interception.
+package keyboard
+
+import (
+	"sync"
+)
+
+const (
+	// KeyCodeReturn is the key code for the Return key
+	KeyCodeReturn = 36
+	// KeyCodeEnter is the key code for the numpad Enter key
+	KeyCodeEnter = 76
+)
+
+// EventCallback is called when a keyboard event is intercepted.
+// Return true to allow the event, false to swallow it.
+type EventCallback func(keyCode int, modifiers Modifiers) bool
+
+// Modifiers represents keyboard modifier keys.
+type Modifiers struct {
+	Shift   bool
+	Command bool
+	Control bool
+	Option  bool
+}
+
+var (
+	eventCallbackMu sync.RWMutex
+	eventCallback   EventCallback
+)
+
+// SetEventCallback sets the callback function for keyboard events.
+func SetEventCallback(cb EventCallback) {
+	eventCallbackMu.Lock()
+	defer eventCallbackMu.Unlock()
+	eventCallback = cb
+}
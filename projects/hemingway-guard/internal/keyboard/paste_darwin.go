@@ -0,0 +1,87 @@
+package keyboard
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework ApplicationServices -framework AppKit
+
+#include <stdlib.h>
+#include <string.h>
+#include <ApplicationServices/ApplicationServices.h>
+#import <AppKit/AppKit.h>
+
+// Copy the clipboard's string contents (caller frees), or NULL if none
+static char *copyClipboardString() {
+    @autoreleasepool {
+        NSString *s = [[NSPasteboard generalPasteboard] stringForType:NSPasteboardTypeString];
+        if (s == nil) {
+            return NULL;
+        }
+        return strdup([s UTF8String]);
+    }
+}
+
+// Replace the clipboard's contents with a string
+static void setClipboardString(const char *s) {
+    @autoreleasepool {
+        NSPasteboard *pb = [NSPasteboard generalPasteboard];
+        [pb clearContents];
+        [pb setString:[NSString stringWithUTF8String:s] forType:NSPasteboardTypeString];
+    }
+}
+
+// Post a key press with Command held
+static void postCommandKey(int64_t keyCode) {
+    CGEventRef down = CGEventCreateKeyboardEvent(NULL, (CGKeyCode)keyCode, true);
+    CGEventRef up = CGEventCreateKeyboardEvent(NULL, (CGKeyCode)keyCode, false);
+    CGEventSetFlags(down, kCGEventFlagMaskCommand);
+    CGEventSetFlags(up, kCGEventFlagMaskCommand);
+    CGEventPost(kCGHIDEventTap, down);
+    CGEventPost(kCGHIDEventTap, up);
+    CFRelease(down);
+    CFRelease(up);
+}
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+const (
+	keyCodeA = 0
+	keyCodeV = 9
+)
+
+// clipboardRestoreDelay gives the target app time to read the pasted text
+// before the user's clipboard is put back.
+const clipboardRestoreDelay = 500 * time.Millisecond
+
+// PasteText replaces the focused field's contents by selecting all and
+// pasting text from the clipboard. It's the fallback for apps, mostly
+// Electron ones, that ignore AXValue writes. The user's clipboard is
+// restored shortly afterwards.
+func PasteText(text string) error {
+	var previous *string
+	if cs := C.copyClipboardString(); cs != nil {
+		s := C.GoString(cs)
+		C.free(unsafe.Pointer(cs))
+		previous = &s
+	}
+
+	ctext := C.CString(text)
+	C.setClipboardString(ctext)
+	C.free(unsafe.Pointer(ctext))
+
+	C.postCommandKey(C.int64_t(keyCodeA))
+	C.postCommandKey(C.int64_t(keyCodeV))
+
+	if previous != nil {
+		time.AfterFunc(clipboardRestoreDelay, func() {
+			cs := C.CString(*previous)
+			C.setClipboardString(cs)
+			C.free(unsafe.Pointer(cs))
+		})
+	}
+	return nil
+}
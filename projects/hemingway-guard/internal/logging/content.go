@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/textutil"
+)
+
+// Privacy decides how Content is logged outside a content window.
+type Privacy int
+
+const (
+	// PrivacyHash logs a keyed hash and the length. The key is random per
+	// process, so hashes match up within one run but can't be checked
+	// against guessed messages.
+	PrivacyHash Privacy = iota
+	// PrivacyOmit logs only the length.
+	PrivacyOmit
+)
+
+// ParsePrivacy parses "hash" or "omit". Empty means hash.
+func ParsePrivacy(s string) (Privacy, error) {
+	switch s {
+	case "", "hash":
+		return PrivacyHash, nil
+	case "omit":
+		return PrivacyOmit, nil
+	}
+	return 0, fmt.Errorf("unknown privacy mode %q", s)
+}
+
+// MaxContentWindow caps how long content logging can stay on.
+const MaxContentWindow = time.Hour
+
+// maxContentBytes bounds logged content even inside a content window.
+const maxContentBytes = 200
+
+var (
+	mu           sync.Mutex
+	privacy      Privacy
+	contentUntil time.Time
+	hashKey      = newHashKey()
+)
+
+func newHashKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("logging: no randomness for hash key: %v", err))
+	}
+	return key
+}
+
+// SetPrivacy sets how Content is logged outside a content window.
+func SetPrivacy(p Privacy) {
+	mu.Lock()
+	defer mu.Unlock()
+	privacy = p
+}
+
+// EnableContent logs Content verbatim for d, capped at MaxContentWindow,
+// and returns when the window ends. A zero or negative d ends the window.
+func EnableContent(d time.Duration) time.Time {
+	d = min(d, MaxContentWindow)
+	mu.Lock()
+	defer mu.Unlock()
+	contentUntil = time.Now().Add(d)
+	return contentUntil
+}
+
+// ContentEnabled reports whether a content window is open.
+func ContentEnabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return time.Now().Before(contentUntil)
+}
+
+// Content is message text. It implements slog.LogValuer so the policy is
+// applied when the record is logged, not when the call is written.
+type Content string
+
+// LogValue returns the text inside a content window, and otherwise a hash
+// and length or only the length.
+func (c Content) LogValue() slog.Value {
+	mu.Lock()
+	p, open := privacy, time.Now().Before(contentUntil)
+	mu.Unlock()
+
+	switch {
+	case open:
+		return slog.StringValue(textutil.TruncateBytes(string(c), maxContentBytes))
+	case p == PrivacyOmit:
+		return slog.GroupValue(slog.Int("len", len(c)))
+	}
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte(c))
+	return slog.GroupValue(
+		slog.String("hash", hex.EncodeToString(mac.Sum(nil))[:12]),
+		slog.Int("len", len(c)),
+	)
+}
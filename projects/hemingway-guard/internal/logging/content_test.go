@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetContent restores the default privacy when the test ends.
+func resetContent(t *testing.T) {
+	t.Cleanup(func() {
+		SetPrivacy(PrivacyHash)
+		EnableContent(0)
+	})
+}
+
+// logged returns what a text handler writes for msg logged as Content.
+func logged(msg string) string {
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("analyzed", "text", Content(msg))
+	return buf.String()
+}
+
+func TestParsePrivacy(t *testing.T) {
+	for s, want := range map[string]Privacy{"": PrivacyHash, "hash": PrivacyHash, "omit": PrivacyOmit} {
+		if got, err := ParsePrivacy(s); err != nil || got != want {
+			t.Errorf("ParsePrivacy(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParsePrivacy("plain"); err == nil {
+		t.Error("ParsePrivacy accepted an unknown mode")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	resetContent(t)
+	SetPrivacy(PrivacyHash)
+
+	out := logged("the launch slipped")
+	if strings.Contains(out, "launch") {
+		t.Errorf("hash mode logged the text: %s", out)
+	}
+	if !strings.Contains(out, "text.hash=") || !strings.Contains(out, "text.len=18") {
+		t.Errorf("hash mode output = %s, want a hash and length", out)
+	}
+
+	hash := func(s string) string {
+		return Content(s).LogValue().Group()[0].Value.String()
+	}
+	if a, b := hash("same"), hash("same"); a != b || len(a) != 12 {
+		t.Errorf("hashes of the same text: %q and %q, want equal 12-digit hashes", a, b)
+	}
+	if hash("one") == hash("two") {
+		t.Error("different text, same hash")
+	}
+}
+
+func TestContentOmit(t *testing.T) {
+	resetContent(t)
+	SetPrivacy(PrivacyOmit)
+
+	out := logged("the launch slipped")
+	if strings.Contains(out, "launch") || strings.Contains(out, "hash") {
+		t.Errorf("omit mode logged more than the length: %s", out)
+	}
+	if !strings.Contains(out, "text.len=18") {
+		t.Errorf("omit mode output = %s, want the length", out)
+	}
+}
+
+func TestContentWindow(t *testing.T) {
+	resetContent(t)
+	SetPrivacy(PrivacyOmit)
+
+	until := EnableContent(time.Minute)
+	if d := time.Until(until); d <= 0 || d > time.Minute {
+		t.Errorf("window ends in %v, want about a minute", d)
+	}
+	if !ContentEnabled() {
+		t.Fatal("content window not open")
+	}
+	if out := logged("the launch slipped"); !strings.Contains(out, `text="the launch slipped"`) {
+		t.Errorf("inside the window: %s, want the text", out)
+	}
+
+	// Long text is cut short even inside the window
+	long := strings.Repeat("é", maxContentBytes)
+	got, cut := strings.CutSuffix(Content(long).LogValue().String(), "...")
+	if !cut || len(got) > maxContentBytes || !strings.HasPrefix(long, got) {
+		t.Errorf("logged %q, want at most %d bytes of whole characters and a marker", got, maxContentBytes)
+	}
+
+	EnableContent(0)
+	if ContentEnabled() {
+		t.Error("window still open after EnableContent(0)")
+	}
+	if out := logged("the launch slipped"); strings.Contains(out, "launch") {
+		t.Errorf("after the window: %s", out)
+	}
+}
+
+func TestContentWindowCap(t *testing.T) {
+	resetContent(t)
+	until := EnableContent(24 * time.Hour)
+	if d := time.Until(until); d > MaxContentWindow {
+		t.Errorf("window ends in %v, want at most %v", d, MaxContentWindow)
+	}
+}
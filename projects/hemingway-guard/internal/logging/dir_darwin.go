@@ -0,0 +1,15 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// logDir is ~/Library/Logs/hemingway-guard, where Console.app looks.
+func logDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "Logs", "hemingway-guard"), nil
+}
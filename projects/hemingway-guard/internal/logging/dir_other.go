@@ -0,0 +1,21 @@
+//go:build !darwin
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// logDir is $XDG_STATE_HOME/hemingway-guard, defaulting to
+// ~/.local/state/hemingway-guard.
+func logDir() (string, error) {
+	if state := os.Getenv("XDG_STATE_HOME"); state != "" {
+		return filepath.Join(state, "hemingway-guard"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "hemingway-guard"), nil
+}
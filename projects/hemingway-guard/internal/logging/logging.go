@@ -0,0 +1,138 @@
+// Package logging sets up structured logging for the daemon and commands.
+//
+// Logs never contain message text by default: wrap it in Content, which
+// logs as a keyed hash and length (or only the length) unless content
+// logging has been enabled for a bounded window with EnableContent.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Options configure Setup.
+type Options struct {
+	Level   slog.Level
+	Privacy Privacy
+
+	// File, if set, also receives every record, rotated once it grows
+	// past MaxSize bytes, keeping MaxBackups old files.
+	File       string
+	MaxSize    int64
+	MaxBackups int
+
+	// Stderr also writes records to standard error.
+	Stderr bool
+}
+
+// DefaultMaxSize and DefaultMaxBackups bound the log file to about 25 MB.
+const (
+	DefaultMaxSize    = 5 << 20
+	DefaultMaxBackups = 4
+)
+
+// Setup installs the default slog logger. Close the returned closer to
+// flush and close the log file.
+func Setup(opts Options) (io.Closer, error) {
+	SetPrivacy(opts.Privacy)
+
+	var writers []io.Writer
+	var closer io.Closer = nopCloser{}
+	if opts.Stderr {
+		writers = append(writers, os.Stderr)
+	}
+	if opts.File != "" {
+		f, err := OpenRotating(opts.File, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, f)
+		closer = f
+	}
+
+	handler := slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: opts.Level})
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error". Empty means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// DefaultFile returns the daemon's log file in the user's log directory.
+func DefaultFile() (string, error) {
+	dir, err := logDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hemingway-guard.log"), nil
+}
+
+// Component returns a logger that tags records with a component, e.g.
+// "ipc". It follows the default logger, so packages can create theirs
+// before Setup runs.
+func Component(name string) *slog.Logger {
+	return slog.New(componentHandler{name: name})
+}
+
+// componentHandler adds a component attribute and forwards records to
+// whatever the default handler is when they are logged.
+type componentHandler struct {
+	name   string
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (h componentHandler) handler() slog.Handler {
+	handler := slog.Default().Handler().WithAttrs([]slog.Attr{slog.String("component", h.name)})
+	if len(h.attrs) > 0 {
+		handler = handler.WithAttrs(h.attrs)
+	}
+	for _, g := range h.groups {
+		handler = handler.WithGroup(g)
+	}
+	return handler
+}
+
+func (h componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.groups) > 0 {
+		// Attributes after a group belong inside it; resolve eagerly
+		return h.handler().WithAttrs(attrs)
+	}
+	h.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return h
+}
+
+func (h componentHandler) WithGroup(name string) slog.Handler {
+	h.groups = append(append([]string(nil), h.groups...), name)
+	return h
+}
+
+// Err returns an attribute for an error.
+func Err(err error) slog.Attr {
+	return slog.Any("err", err)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeLines(t *testing.T, r *RotatingFile, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if _, err := fmt.Fprintf(r, "line %03d\n", i); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "test.log")
+	// Each line is 9 bytes, so each file holds 3
+	r, err := OpenRotating(path, 30, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	writeLines(t, r, 0, 10)
+
+	want := map[string]string{
+		path:        "line 009\n",
+		path + ".1": "line 006\nline 007\nline 008\n",
+		path + ".2": "line 003\nline 004\nline 005\n",
+	}
+	for p, w := range want {
+		if got := readFile(t, p); got != w {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, w)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups than the limit: %v", err)
+	}
+
+	for _, p := range []string{path, path + ".1"} {
+		if info, _ := os.Stat(p); info.Mode().Perm() != 0o600 {
+			t.Errorf("%s mode = %o, want 600", filepath.Base(p), info.Mode().Perm())
+		}
+	}
+	if info, _ := os.Stat(filepath.Dir(path)); info.Mode().Perm() != 0o700 {
+		t.Errorf("directory mode = %o, want 700", info.Mode().Perm())
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("late\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	if err := os.WriteFile(path, []byte("line 000\nline 001\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRotating(path, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The existing 18 bytes count towards the limit
+	writeLines(t, r, 2, 4)
+	if got := readFile(t, path+".1"); got != "line 000\nline 001\nline 002\n" {
+		t.Errorf("backup = %q", got)
+	}
+	if got := readFile(t, path); got != "line 003\n" {
+		t.Errorf("current = %q", got)
+	}
+}
+
+// A rename that fails leaves the current file in use, and the next write
+// tries again.
+func TestRotatingFileRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	r, err := OpenRotating(path, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// A non-empty directory where the backup goes can't be replaced
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 0, 3)
+	n, err := r.Write([]byte("line 003\n"))
+	if err == nil || !strings.Contains(err.Error(), "rotating") {
+		t.Errorf("Write = %v, want the rotation error", err)
+	}
+	if n != 9 {
+		t.Errorf("Write wrote %d bytes, want all 9 despite the failed rotation", n)
+	}
+	if got := readFile(t, path); got != "line 000\nline 001\nline 002\nline 003\n" {
+		t.Errorf("current = %q, want every line", got)
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, 4, 5)
+	if got := readFile(t, path+".1"); !strings.HasSuffix(got, "line 003\n") {
+		t.Errorf("backup after retry = %q", got)
+	}
+	if got := readFile(t, path); got != "line 004\n" {
+		t.Errorf("current after retry = %q", got)
+	}
+}
+
+func TestOpenRotatingDefaults(t *testing.T) {
+	r, err := OpenRotating(filepath.Join(t.TempDir(), "test.log"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.maxSize != DefaultMaxSize || r.maxBackups != DefaultMaxBackups {
+		t.Errorf("limits = %d, %d; want the defaults", r.maxSize, r.maxBackups)
+	}
+}
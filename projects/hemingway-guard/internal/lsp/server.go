@@ -0,0 +1,390 @@
+// Package lsp serves the analyzer's findings to editors over the Language
+// Server Protocol.
+//
+// The server speaks JSON-RPC over a single stream, normally stdio. It
+// analyzes each document when it is opened or saved, runs only the local
+// rules on every change in between, publishes the findings as diagnostics,
+// and offers code actions that apply findings' replacements or the
+// analysis suggestion.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// ErrNoShutdown is returned by Serve when the client exits without asking
+// the server to shut down first. Per the spec, the process should then exit
+// with status 1.
+var ErrNoShutdown = errors.New("lsp: exit without shutdown")
+
+// logger logs to stderr; stdout carries the protocol.
+var logger = logging.Component("lsp")
+
+// diagnosticSource names the server in diagnostics.
+const diagnosticSource = "hemingway-guard"
+
+// Language IDs with rules of their own. Other documents are analyzed as
+// chat messages for the configured app.
+const (
+	languageMarkdown  = "markdown"
+	languageGitCommit = "git-commit"
+)
+
+// Server analyzes open documents for one client.
+type Server struct {
+	analyzer    *analyzer.Analyzer
+	rules       *analyzer.Analyzer // the same rules, without the provider
+	appCtx      analyzer.AppContext
+	commentChar string
+
+	out         io.Writer
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// document is an open text document and its latest analysis.
+type document struct {
+	uri        string
+	languageID string
+	version    int
+	text       string
+
+	analysis    *analyzer.Analysis
+	diagnostics []diagnostic // parallel to analysis.Findings
+}
+
+// NewServer creates a server that analyzes documents with a. Changes as
+// the user types run only a's local rules, so the provider isn't asked on
+// every keystroke.
+func NewServer(a *analyzer.Analyzer) *Server {
+	rules := analyzer.NewAnalyzer()
+	rules.SetRules(a.Rules())
+	return &Server{analyzer: a, rules: rules, docs: make(map[string]*document)}
+}
+
+// SetAppContext sets the context for documents that aren't Markdown or
+// commit messages. A client's initializationOptions override it.
+func (s *Server) SetAppContext(appCtx analyzer.AppContext) {
+	s.appCtx = appCtx
+}
+
+// SetCommentChar sets git's core.commentChar for commit messages. Empty
+// means "#".
+func (s *Server) SetCommentChar(c string) {
+	s.commentChar = c
+}
+
+// Serve reads requests from r and writes responses and diagnostics to w
+// until the client sends exit, r reaches EOF, or ctx is done.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = w
+	in := bufio.NewReader(r)
+	for ctx.Err() == nil {
+		msg, err := readMessage(in)
+		var rpcErr *responseError
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.As(err, &rpcErr):
+			if err := s.replyError(nil, rpcErr); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		if err := s.dispatch(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// dispatch handles one request or notification. It only returns errors
+// writing to the client; errors in handling are reported to the client.
+func (s *Server) dispatch(ctx context.Context, msg *message) error {
+	isRequest := msg.ID != nil
+
+	switch {
+	case !s.initialized && msg.Method != "initialize":
+		if isRequest {
+			return s.replyError(msg.ID, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"})
+		}
+		return nil
+	case s.shutdown && isRequest:
+		return s.replyError(msg.ID, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+	}
+
+	var result any
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result, err = s.initialize(msg.Params)
+	case "initialized":
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		err = s.didOpen(ctx, msg.Params)
+	case "textDocument/didChange":
+		err = s.didChange(ctx, msg.Params)
+	case "textDocument/didSave":
+		err = s.didSave(ctx, msg.Params)
+	case "textDocument/didClose":
+		err = s.didClose(msg.Params)
+	case "textDocument/codeAction":
+		result, err = s.codeAction(msg.Params)
+	default:
+		if isRequest {
+			return s.replyError(msg.ID, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+		}
+		return nil
+	}
+
+	if !isRequest {
+		if err != nil {
+			logger.Warn("notification failed", "method", msg.Method, logging.Err(err))
+		}
+		var writeErr *writeError
+		if errors.As(err, &writeErr) {
+			return writeErr.err
+		}
+		return nil
+	}
+	if err != nil {
+		rpcErr, ok := err.(*responseError)
+		if !ok {
+			rpcErr = &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.replyError(msg.ID, rpcErr)
+	}
+	return writeMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+// writeError marks failures to write to the client, which end the session.
+type writeError struct{ err error }
+
+func (e *writeError) Error() string { return e.err.Error() }
+
+func (s *Server) replyError(id *json.RawMessage, rpcErr *responseError) error {
+	return writeMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: id, Error: rpcErr})
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	if s.initialized {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "already initialized"}
+	}
+	var p initializeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if opts := p.InitializationOptions; opts != nil {
+		if opts.App != "" {
+			s.appCtx.AppName = opts.App
+		}
+		if opts.Channel != "" {
+			s.appCtx.ChannelType = opts.Channel
+		}
+	}
+	s.initialized = true
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:   textDocumentSyncOptions{OpenClose: true, Change: syncFull, Save: true},
+			CodeActionProvider: true,
+		},
+		ServerInfo: serverInfo{Name: diagnosticSource},
+	}, nil
+}
+
+func (s *Server) didOpen(ctx context.Context, params json.RawMessage) error {
+	var p didOpenParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	doc := &document{
+		uri:        p.TextDocument.URI,
+		languageID: p.TextDocument.LanguageID,
+		version:    p.TextDocument.Version,
+		text:       p.TextDocument.Text,
+	}
+	s.docs[doc.uri] = doc
+	return s.analyze(ctx, doc, s.analyzer)
+}
+
+func (s *Server) didChange(ctx context.Context, params json.RawMessage) error {
+	var p didChangeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return fmt.Errorf("document %s is not open", p.TextDocument.URI)
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil
+	}
+	// With full sync each change is the whole document; the last one wins
+	doc.text = p.ContentChanges[len(p.ContentChanges)-1].Text
+	doc.version = p.TextDocument.Version
+	return s.analyze(ctx, doc, s.rules)
+}
+
+func (s *Server) didSave(ctx context.Context, params json.RawMessage) error {
+	var p didSaveParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return fmt.Errorf("document %s is not open", p.TextDocument.URI)
+	}
+	return s.analyze(ctx, doc, s.analyzer)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p didCloseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	// Clear the document's diagnostics so they don't linger in the editor
+	return s.publish(&document{uri: p.TextDocument.URI})
+}
+
+// analyze re-runs a on doc and publishes its diagnostics. When the provider
+// fails, the local rules' findings are still published.
+func (s *Server) analyze(ctx context.Context, doc *document, a *analyzer.Analyzer) error {
+	text, appCtx := s.prepare(doc)
+	analysis, err := a.Analyze(ctx, text, appCtx)
+	if analysis == nil {
+		return err
+	}
+	if err != nil {
+		logger.Warn("analysis failed, publishing local findings", logging.Err(err))
+	}
+
+	doc.analysis = analysis
+	doc.diagnostics = make([]diagnostic, len(analysis.Findings))
+	for i, f := range analysis.Findings {
+		doc.diagnostics[i] = diagnostic{
+			Range:    findingRange(doc.text, f),
+			Severity: lspSeverity(f.Severity),
+			Code:     f.Rule,
+			Source:   diagnosticSource,
+			Message:  f.Message,
+		}
+	}
+	return s.publish(doc)
+}
+
+// prepare returns the text to analyze and its app context. Text that rules
+// should ignore is blanked rather than removed, so findings' offsets still
+// point into the document.
+func (s *Server) prepare(doc *document) (string, analyzer.AppContext) {
+	switch doc.languageID {
+	case languageMarkdown:
+		return analyzer.MaskMarkdown(doc.text), analyzer.AppContext{AppName: analyzer.AppMarkdown}
+	case languageGitCommit:
+		return analyzer.MaskCommitMessage(doc.text, s.commentChar), analyzer.AppContext{AppName: analyzer.AppGit}
+	}
+	return doc.text, s.appCtx
+}
+
+func (s *Server) publish(doc *document) error {
+	params := publishDiagnosticsParams{URI: doc.uri, Diagnostics: doc.diagnostics}
+	if params.Diagnostics == nil {
+		params.Diagnostics = []diagnostic{}
+	}
+	if doc.version != 0 {
+		params.Version = &doc.version
+	}
+	if err := writeMessage(s.out, &notification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params}); err != nil {
+		return &writeError{err}
+	}
+	return nil
+}
+
+// codeAction offers a quick fix for each finding with a replacement that
+// overlaps the requested range, then the analysis suggestion for the whole
+// document.
+func (s *Server) codeAction(params json.RawMessage) (any, error) {
+	var p codeActionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	actions := []codeAction{}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || doc.analysis == nil {
+		return actions, nil
+	}
+
+	start, end := offsetAt(doc.text, p.Range.Start), offsetAt(doc.text, p.Range.End)
+	for i, f := range doc.analysis.Findings {
+		if f.Span == nil || f.Replacement == nil {
+			continue
+		}
+		// Touching counts as overlapping, so a cursor at either edge finds it
+		if f.Span.End < start || f.Span.Start > end {
+			continue
+		}
+		title := fmt.Sprintf("Replace with %q", *f.Replacement)
+		if *f.Replacement == "" {
+			title = fmt.Sprintf("Remove %q", doc.text[f.Span.Start:f.Span.End])
+		}
+		diag := doc.diagnostics[i]
+		actions = append(actions, codeAction{
+			Title:       title,
+			Kind:        "quickfix",
+			Diagnostics: []diagnostic{diag},
+			IsPreferred: true,
+			Edit:        singleEdit(doc.uri, diag.Range, *f.Replacement),
+		})
+	}
+
+	if suggestion := doc.analysis.Suggestion; suggestion != "" && suggestion != doc.text {
+		actions = append(actions, codeAction{
+			Title: "Replace with suggestion",
+			Kind:  "quickfix",
+			Edit:  singleEdit(doc.uri, textRange{End: positionAt(doc.text, len(doc.text))}, suggestion),
+		})
+	}
+	return actions, nil
+}
+
+func singleEdit(uri string, r textRange, newText string) workspaceEdit {
+	return workspaceEdit{Changes: map[string][]textEdit{uri: {{Range: r, NewText: newText}}}}
+}
+
+// findingRange locates a finding in text. Findings without a span cover
+// the whole document.
+func findingRange(text string, f analyzer.Finding) textRange {
+	if f.Span == nil {
+		return textRange{End: positionAt(text, len(text))}
+	}
+	return textRange{Start: positionAt(text, f.Span.Start), End: positionAt(text, f.Span.End)}
+}
+
+func lspSeverity(s analyzer.Severity) int {
+	switch s {
+	case analyzer.SeverityError, analyzer.SeverityBlock:
+		return severityError
+	case analyzer.SeverityWarning:
+		return severityWarning
+	}
+	return severityInformation
+}
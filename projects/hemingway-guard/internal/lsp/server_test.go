@@ -0,0 +1,472 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+)
+
+// client drives a Server the way an editor does, over a pair of pipes.
+type client struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+// incoming is anything the server writes: a response or a notification.
+type incoming struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func startClient(t *testing.T, s *Server) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, out: bufio.NewReader(outR), done: make(chan error, 1)}
+	go func() {
+		err := s.Serve(context.Background(), inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+func (c *client) send(msg any) {
+	c.t.Helper()
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatalf("writing to server: %v", err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// call sends a request and returns its response.
+func (c *client) call(method string, params any) incoming {
+	c.t.Helper()
+	c.nextID++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	msg := c.read()
+	if msg.ID == nil || *msg.ID != c.nextID {
+		c.t.Fatalf("%s: got %+v, want the response to request %d", method, msg, c.nextID)
+	}
+	return msg
+}
+
+func (c *client) read() incoming {
+	c.t.Helper()
+	header, err := textproto.NewReader(c.out).ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatalf("reading header: %v", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		c.t.Fatalf("Content-Length %q: %v", header.Get("Content-Length"), err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.out, body); err != nil {
+		c.t.Fatalf("reading body: %v", err)
+	}
+	var msg incoming
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("decoding %s: %v", body, err)
+	}
+	return msg
+}
+
+// diagnostics reads the next message, which must publish diagnostics.
+func (c *client) diagnostics() publishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("got %+v, want diagnostics", msg)
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatal(err)
+	}
+	return p
+}
+
+func (c *client) initialize(options any) {
+	c.t.Helper()
+	resp := c.call("initialize", map[string]any{"initializationOptions": options})
+	if resp.Error != nil {
+		c.t.Fatalf("initialize: %v", resp.Error)
+	}
+	c.notify("initialized", struct{}{})
+}
+
+func (c *client) open(uri, languageID string, version int, text string) publishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": textDocumentItem{URI: uri, LanguageID: languageID, Version: version, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) change(uri string, version int, text string) publishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   versionedTextDocumentIdentifier{URI: uri, Version: version},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+	return c.diagnostics()
+}
+
+// exit shuts the server down and returns what Serve returned.
+func (c *client) exit(shutdown bool) error {
+	c.t.Helper()
+	if shutdown {
+		if resp := c.call("shutdown", nil); resp.Error != nil {
+			c.t.Fatalf("shutdown: %v", resp.Error)
+		}
+	}
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("server did not exit")
+		return nil
+	}
+}
+
+// codes lists diagnostics as "code@line:char-line:char".
+func codes(diags []diagnostic) []string {
+	out := make([]string, len(diags))
+	for i, d := range diags {
+		r := d.Range
+		out[i] = fmt.Sprintf("%s@%d:%d-%d:%d", d.Code, r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+	}
+	return out
+}
+
+func TestServerSession(t *testing.T) {
+	c := startClient(t, NewServer(analyzer.NewAnalyzer()))
+
+	resp := c.call("initialize", map[string]any{})
+	var result initializeResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	sync := result.Capabilities.TextDocumentSync
+	if !sync.OpenClose || sync.Change != syncFull || !sync.Save || !result.Capabilities.CodeActionProvider {
+		t.Errorf("capabilities = %+v, want full sync, saves and code actions", result.Capabilities)
+	}
+	if result.ServerInfo.Name != diagnosticSource {
+		t.Errorf("server name = %q, want %q", result.ServerInfo.Name, diagnosticSource)
+	}
+	c.notify("initialized", struct{}{})
+
+	const uri = "file:///repo/.git/COMMIT_EDITMSG"
+	diags := c.open(uri, languageGitCommit, 1, "Fixed the parser.\n")
+	if diags.URI != uri || diags.Version == nil || *diags.Version != 1 {
+		t.Errorf("published for %s version %v, want %s version 1", diags.URI, diags.Version, uri)
+	}
+	want := []string{"git/subject-period@0:16-0:17", "git/imperative@0:0-0:5"}
+	if got := codes(diags.Diagnostics); !sameSet(got, want) {
+		t.Errorf("diagnostics on open = %v, want %v", got, want)
+	}
+	for _, d := range diags.Diagnostics {
+		if d.Source != diagnosticSource || d.Severity != severityWarning {
+			t.Errorf("%s: source %q severity %d, want %q and a warning", d.Code, d.Source, d.Severity, diagnosticSource)
+		}
+	}
+
+	// A cursor in the first word offers only that word's quick fix
+	resp = c.call("textDocument/codeAction", codeActionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Range:        textRange{Start: position{Line: 0, Character: 2}, End: position{Line: 0, Character: 2}},
+	})
+	var actions []codeAction
+	if err := json.Unmarshal(resp.Result, &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Title != `Replace with "Fix"` {
+		t.Fatalf("actions at the first word = %+v, want one to replace it", actions)
+	}
+	edits := actions[0].Edit.Changes[uri]
+	if len(edits) != 1 || edits[0].NewText != "Fix" || edits[0].Range.End != (position{Line: 0, Character: 5}) {
+		t.Errorf("edit = %+v, want \"Fix\" over 0:0-0:5", edits)
+	}
+
+	diags = c.change(uri, 2, "Fix the parser\n")
+	if len(diags.Diagnostics) != 0 || *diags.Version != 2 {
+		t.Errorf("after the fix: version %d diagnostics %v, want version 2 and none", *diags.Version, codes(diags.Diagnostics))
+	}
+
+	c.notify("textDocument/didClose", map[string]any{"textDocument": textDocumentIdentifier{URI: uri}})
+	if diags := c.diagnostics(); diags.URI != uri || len(diags.Diagnostics) != 0 {
+		t.Errorf("on close: %+v, want the diagnostics cleared", diags)
+	}
+
+	if err := c.exit(true); err != nil {
+		t.Errorf("Serve = %v, want nil after shutdown and exit", err)
+	}
+}
+
+func TestServerCRLF(t *testing.T) {
+	long := strings.Repeat("word ", 16) + "tail"
+	tests := []struct {
+		name       string
+		languageID string
+		text       string
+		want       []string
+	}{
+		{
+			name:       "commit body",
+			languageID: languageGitCommit,
+			text:       "Fix the parser\r\n\r\n" + long + "\r\n",
+			want:       []string{fmt.Sprintf("git/body-wrap@2:72-2:%d", len(long))},
+		},
+		{
+			name:       "commit subject",
+			languageID: languageGitCommit,
+			text:       "Added the parser.\r\n\r\nBody\r\n",
+			want:       []string{"git/imperative@0:0-0:5", "git/subject-period@0:16-0:17"},
+		},
+		{
+			name:       "commit with comments",
+			languageID: languageGitCommit,
+			text:       "Fix the parser\r\n\r\n# " + long + "\r\n" + long + "\r\n",
+			want:       []string{fmt.Sprintf("git/body-wrap@3:72-3:%d", len(long))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := startClient(t, NewServer(analyzer.NewAnalyzer()))
+			c.initialize(nil)
+
+			const uri = "file:///tmp/doc"
+			diags := c.open(uri, tt.languageID, 1, tt.text)
+			if got := codes(diags.Diagnostics); !sameSet(got, tt.want) {
+				t.Errorf("on open = %v, want %v", got, tt.want)
+			}
+			// The same document with LF endings has the same ranges
+			diags = c.change(uri, 2, strings.ReplaceAll(tt.text, "\r\n", "\n"))
+			if got := codes(diags.Diagnostics); !sameSet(got, tt.want) {
+				t.Errorf("with LF = %v, want %v", got, tt.want)
+			}
+			if err := c.exit(true); err != nil {
+				t.Errorf("Serve = %v", err)
+			}
+		})
+	}
+}
+
+func TestServerCRLFCodeAction(t *testing.T) {
+	c := startClient(t, NewServer(analyzer.NewAnalyzer()))
+	c.initialize(nil)
+
+	const uri = "file:///repo/.git/COMMIT_EDITMSG"
+	c.open(uri, languageGitCommit, 1, "Fix the parser.\r\n\r\nBody\r\n")
+	resp := c.call("textDocument/codeAction", codeActionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Range:        textRange{Start: position{Line: 0, Character: 15}, End: position{Line: 0, Character: 15}},
+	})
+	var actions []codeAction
+	if err := json.Unmarshal(resp.Result, &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 {
+		t.Fatalf("actions = %+v, want one", actions)
+	}
+	want := textEdit{Range: textRange{Start: position{Line: 0, Character: 14}, End: position{Line: 0, Character: 15}}}
+	if edits := actions[0].Edit.Changes[uri]; len(edits) != 1 || edits[0] != want {
+		t.Errorf("edits = %+v, want the period removed, not the line ending", edits)
+	}
+	if err := c.exit(true); err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+}
+
+func TestServerInitializationOptions(t *testing.T) {
+	s := NewServer(analyzer.NewAnalyzer())
+	s.SetAppContext(analyzer.AppContext{AppName: "Slack", ChannelType: "DM"})
+	c := startClient(t, s)
+	c.initialize(initOptions{App: "Discord", Channel: "channel"})
+
+	c.open("file:///tmp/note.txt", "plaintext", 1, "Quick question about the launch")
+	if err := c.exit(true); err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+	// Serve has returned, so the server's state is safe to read
+	if s.appCtx.AppName != "Discord" || s.appCtx.ChannelType != "channel" {
+		t.Errorf("app context = %+v, want Discord channel", s.appCtx)
+	}
+}
+
+func TestServerProtocolErrors(t *testing.T) {
+	c := startClient(t, NewServer(analyzer.NewAnalyzer()))
+
+	if resp := c.call("textDocument/codeAction", struct{}{}); resp.Error == nil || resp.Error.Code != codeServerNotInitialized {
+		t.Errorf("request before initialize: %+v, want code %d", resp, codeServerNotInitialized)
+	}
+	c.initialize(nil)
+	if resp := c.call("initialize", struct{}{}); resp.Error == nil || resp.Error.Code != codeInvalidRequest {
+		t.Errorf("second initialize: %+v, want code %d", resp, codeInvalidRequest)
+	}
+	if resp := c.call("workspace/symbol", struct{}{}); resp.Error == nil || resp.Error.Code != codeMethodNotFound {
+		t.Errorf("unknown method: %+v, want code %d", resp, codeMethodNotFound)
+	}
+	if resp := c.call("textDocument/codeAction", "not an object"); resp.Error == nil || resp.Error.Code != codeInvalidParams {
+		t.Errorf("bad params: %+v, want code %d", resp, codeInvalidParams)
+	}
+
+	if _, err := io.WriteString(c.in, "Content-Length: 8\r\n\r\n{broken}"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := c.read(); msg.ID != nil || msg.Error == nil || msg.Error.Code != codeParseError {
+		t.Errorf("malformed JSON: %+v, want a parse error with a null id", msg)
+	}
+
+	// Changes to documents that aren't open are ignored; the session goes on
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   versionedTextDocumentIdentifier{URI: "file:///tmp/closed", Version: 2},
+		"contentChanges": []map[string]string{{"text": "hello"}},
+	})
+	if resp := c.call("shutdown", nil); resp.Error != nil {
+		t.Fatalf("shutdown: %v", resp.Error)
+	}
+	if resp := c.call("textDocument/codeAction", struct{}{}); resp.Error == nil || resp.Error.Code != codeInvalidRequest {
+		t.Errorf("request after shutdown: %+v, want code %d", resp, codeInvalidRequest)
+	}
+	if err := c.exit(false); err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+}
+
+// A provider outage still publishes the local rules' findings.
+func TestServerProviderFailure(t *testing.T) {
+	a := analyzer.NewAnalyzer()
+	a.SetProvider(analyzer.ProviderFunc(func(context.Context, string) (string, error) {
+		return "", errors.New("provider unavailable")
+	}))
+	c := startClient(t, NewServer(a))
+	c.initialize(nil)
+
+	diags := c.open("file:///repo/.git/COMMIT_EDITMSG", languageGitCommit, 1, "Fixed the parser.\n")
+	want := []string{"git/subject-period@0:16-0:17", "git/imperative@0:0-0:5"}
+	if got := codes(diags.Diagnostics); !sameSet(got, want) {
+		t.Errorf("diagnostics = %v, want the local findings %v", got, want)
+	}
+	if err := c.exit(true); err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+}
+
+// The provider reviews documents when they are opened and saved; changes
+// in between run only the local rules.
+func TestServerProviderOnOpenAndSave(t *testing.T) {
+	var calls atomic.Int32
+	a := analyzer.NewAnalyzer()
+	a.SetProvider(analyzer.ProviderFunc(func(context.Context, string) (string, error) {
+		calls.Add(1)
+		return `{"approved": false, "issues": ["too vague"], "suggestion": "Can we ship Friday?"}`, nil
+	}))
+	c := startClient(t, NewServer(a))
+	c.initialize(nil)
+
+	const uri = "file:///tmp/note.txt"
+	suggestion := func() string {
+		t.Helper()
+		resp := c.call("textDocument/codeAction", codeActionParams{TextDocument: textDocumentIdentifier{URI: uri}})
+		var actions []codeAction
+		if err := json.Unmarshal(resp.Result, &actions); err != nil {
+			t.Fatal(err)
+		}
+		for _, action := range actions {
+			if action.Title == "Replace with suggestion" {
+				return action.Edit.Changes[uri][0].NewText
+			}
+		}
+		return ""
+	}
+
+	c.open(uri, "plaintext", 1, "so maybe we could ship it")
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("provider called %d times on open, want 1", n)
+	}
+	if got := suggestion(); got != "Can we ship Friday?" {
+		t.Errorf("suggestion after open = %q", got)
+	}
+
+	for v := 2; v <= 5; v++ {
+		c.change(uri, v, "so maybe we could ship it on"[:20+v])
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("provider called %d times after typing, want still 1", n)
+	}
+
+	c.notify("textDocument/didSave", map[string]any{"textDocument": textDocumentIdentifier{URI: uri}})
+	if diags := c.diagnostics(); diags.URI != uri || *diags.Version != 5 {
+		t.Errorf("on save: published %s version %v", diags.URI, diags.Version)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("provider called %d times after save, want 2", n)
+	}
+	if err := c.exit(true); err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	c := startClient(t, NewServer(analyzer.NewAnalyzer()))
+	c.initialize(nil)
+	if err := c.exit(false); !errors.Is(err, ErrNoShutdown) {
+		t.Errorf("Serve = %v, want ErrNoShutdown", err)
+	}
+}
+
+func TestServerEOF(t *testing.T) {
+	c := startClient(t, NewServer(analyzer.NewAnalyzer()))
+	c.initialize(nil)
+	c.in.Close()
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("Serve = %v, want nil at end of input", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop at end of input")
+	}
+}
+
+func sameSet(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range got {
+		seen[s]++
+	}
+	for _, s := range want {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import "time"
+
+// Default holds the metrics below.
+var Default = NewRegistry()
+
+// startTime is when the process started.
+var startTime = time.Now()
+
+// The daemon's metrics. Label values are fixed strings, never user data.
+// There is no cache-hit counter because analyses aren't cached.
+var (
+	// Intercepts counts Enter presses held in a monitored field.
+	Intercepts = Default.Counter("hemingway_guard_intercepts_total",
+		"Enter presses held in a monitored field for analysis.")
+
+	// Analyses counts analyses by provider ("rules" or "model") and result
+	// ("ok", "error" or "timeout").
+	Analyses = Default.CounterVec("hemingway_guard_analyses_total",
+		"Analyses by provider and result.", "provider", "result")
+
+	// AnalysisSeconds times analyses, whatever their result.
+	AnalysisSeconds = Default.Histogram("hemingway_guard_analysis_duration_seconds",
+		"How long analyses took.",
+		[]float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30})
+
+	// ReviewTimeouts counts popover reviews the user didn't answer in time.
+	ReviewTimeouts = Default.Counter("hemingway_guard_review_timeouts_total",
+		"Popover reviews that timed out.")
+
+	// TapReenables counts times the event tap was switched back on after
+	// macOS disabled it.
+	TapReenables = Default.CounterVec("hemingway_guard_tap_reenables_total",
+		"Times the keyboard event tap was re-enabled after macOS disabled it.", "reason")
+
+	// IPCErrors counts popover connection problems by kind ("rejected",
+	// "bad_message" or "send").
+	IPCErrors = Default.CounterVec("hemingway_guard_ipc_errors_total",
+		"Popover connection errors by kind.", "kind")
+
+	// PopoverClients is the number of connected popovers.
+	PopoverClients = Default.Gauge("hemingway_guard_popover_clients",
+		"Popovers connected to the daemon.")
+
+	// FocusTransitions counts focus moving into ("field") or out of
+	// ("away") a monitored message field.
+	FocusTransitions = Default.CounterVec("hemingway_guard_focus_transitions_total",
+		"Focus changes into or out of a monitored message field.", "to")
+)
+
+func init() {
+	Default.GaugeFunc("hemingway_guard_start_time_seconds",
+		"When the process started, in seconds since the Unix epoch.",
+		func() float64 { return float64(startTime.UnixNano()) / 1e9 })
+}
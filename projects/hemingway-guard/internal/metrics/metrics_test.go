@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) (string, Samples) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	samples, err := ParseText(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("parsing our own output: %v\n%s", err, buf.String())
+	}
+	return buf.String(), samples
+}
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("test_intercepts_total", "Intercepts.").Add(3)
+	vec := reg.CounterVec("test_analyses_total", "Analyses.", "provider", "result")
+	vec.With("rules", "ok").Add(2)
+	vec.With("model", "error").Inc()
+	reg.Gauge("test_clients", "Clients.").Set(1.5)
+	reg.GaugeFunc("test_start_time_seconds", "Start.", func() float64 { return 1700000000.25 })
+	h := reg.Histogram("test_seconds", "Durations.", []float64{1, 0.1})
+	h.Observe(0.05)
+
+	const want = `# HELP test_intercepts_total Intercepts.
+# TYPE test_intercepts_total counter
+test_intercepts_total 3
+# HELP test_analyses_total Analyses.
+# TYPE test_analyses_total counter
+test_analyses_total{provider="model",result="error"} 1
+test_analyses_total{provider="rules",result="ok"} 2
+# HELP test_clients Clients.
+# TYPE test_clients gauge
+test_clients 1.5
+# HELP test_start_time_seconds Start.
+# TYPE test_start_time_seconds gauge
+test_start_time_seconds 1.70000000025e+09
+# HELP test_seconds Durations.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="+Inf"} 1
+test_seconds_sum 0.05
+test_seconds_count 1
+`
+	got, samples := scrape(t, reg)
+	if got != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"test_intercepts_total", nil, 3},
+		{"test_analyses_total", []string{"provider", "rules", "result", "ok"}, 2},
+		{"test_analyses_total", []string{"provider", "model"}, 1},
+		{"test_clients", nil, 1.5},
+		{"test_start_time_seconds", nil, 1700000000.25},
+		{"test_seconds_bucket", []string{"le", "+Inf"}, 1},
+		{"test_seconds_sum", nil, 0.05},
+		{"missing_total", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := samples.Get(tt.name, tt.labels...); got != tt.want {
+			t.Errorf("Get(%s, %v) = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+	if got := samples.Sum("test_analyses_total"); got != 3 {
+		t.Errorf("Sum(test_analyses_total) = %v, want 3", got)
+	}
+}
+
+// Buckets are cumulative and inclusive of their upper bound.
+func TestHistogramBuckets(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.Histogram("test_seconds", "Durations.", []float64{0.1, 0.5, 1})
+	for _, v := range []float64{0.05, 0.1, 0.2, 0.5, 0.7, 3, 12} {
+		h.Observe(v)
+	}
+	_, samples := scrape(t, reg)
+
+	want := map[string]float64{"0.1": 2, "0.5": 4, "1": 5, "+Inf": 7}
+	for le, n := range want {
+		if got := samples.Get("test_seconds_bucket", "le", le); got != n {
+			t.Errorf("bucket le=%s = %v, want %v", le, got, n)
+		}
+	}
+	if got := samples.Get("test_seconds_count"); got != 7 {
+		t.Errorf("count = %v, want 7", got)
+	}
+	if got := samples.Get("test_seconds_sum"); math.Abs(got-16.55) > 1e-9 {
+		t.Errorf("sum = %v, want 16.55", got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	reg := NewRegistry()
+	vec := reg.CounterVec("test_total", "Escapes.", "reason")
+	values := []string{`back\slash`, `"quoted"`, "two\nlines", `trailing\`, "", "plain, {braces} = ok"}
+	for _, v := range values {
+		vec.With(v).Inc()
+	}
+	text, samples := scrape(t, reg)
+
+	if !strings.Contains(text, `test_total{reason="two\nlines"} 1`) ||
+		!strings.Contains(text, `test_total{reason="\"quoted\""} 1`) ||
+		!strings.Contains(text, `test_total{reason="trailing\\"} 1`) {
+		t.Errorf("label values not escaped:\n%s", text)
+	}
+	var got []string
+	for _, s := range samples {
+		got = append(got, s.Labels["reason"])
+	}
+	for _, v := range values {
+		if samples.Get("test_total", "reason", v) != 1 {
+			t.Errorf("label value %q didn't survive the round trip; got %q", v, got)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	const text = `# HELP x Anything.
+# TYPE x counter
+
+x 1 1700000000000
+y{a="1",b="two words"} 2.5
+  z{} NaN
+`
+	samples, err := ParseText(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("%d samples, want 3", len(samples))
+	}
+	if s := samples[0]; s.Name != "x" || s.Value != 1 {
+		t.Errorf("timestamped sample = %+v", s)
+	}
+	if s := samples[1]; !reflect.DeepEqual(s.Labels, map[string]string{"a": "1", "b": "two words"}) || s.Value != 2.5 {
+		t.Errorf("labeled sample = %+v", s)
+	}
+	if s := samples[2]; s.Name != "z" || !math.IsNaN(s.Value) {
+		t.Errorf("NaN sample = %+v", s)
+	}
+
+	for _, bad := range []string{
+		"x",
+		"x notanumber",
+		`x{a="1"`,
+		`x{a="1} 2`,
+		`x{a} 2`,
+		"{a=\"1\"} 2",
+	} {
+		if _, err := ParseText(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseText(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDuplicateMetric(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("test_total", "Once.")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice didn't panic")
+		}
+	}()
+	reg.Gauge("test_total", "Twice.")
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("test_total", "Requests.").Inc()
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "test_total 1\n") {
+		t.Errorf("body = %s", rec.Body)
+	}
+}
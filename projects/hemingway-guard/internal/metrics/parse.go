@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sample is one line of the text format.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Samples is a scrape of the text format, in the order written.
+type Samples []Sample
+
+// ParseText reads samples in the Prometheus text format, as written by
+// WriteText. Comments and timestamps are ignored.
+func ParseText(r io.Reader) (Samples, error) {
+	var samples Samples
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, sc.Err()
+}
+
+func parseSample(line string) (Sample, error) {
+	s := Sample{Labels: map[string]string{}}
+	end := strings.IndexAny(line, "{ ")
+	if end <= 0 {
+		return s, fmt.Errorf("malformed sample %q", line)
+	}
+	s.Name, line = line[:end], line[end:]
+
+	if strings.HasPrefix(line, "{") {
+		line = line[1:]
+		for !strings.HasPrefix(line, "}") {
+			eq := strings.Index(line, "=\"")
+			if eq <= 0 {
+				return s, fmt.Errorf("malformed labels in %s", s.Name)
+			}
+			name := line[:eq]
+			value, rest, err := unquoteLabel(line[eq+2:])
+			if err != nil {
+				return s, fmt.Errorf("%s: %w", s.Name, err)
+			}
+			s.Labels[name] = value
+			line = strings.TrimPrefix(rest, ",")
+			if line == "" {
+				return s, fmt.Errorf("unterminated labels in %s", s.Name)
+			}
+		}
+		line = line[1:]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return s, fmt.Errorf("%s has no value", s.Name)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("%s: %w", s.Name, err)
+	}
+	s.Value = v
+	return s, nil
+}
+
+// unquoteLabel reads a label value up to its closing quote and returns the
+// rest of the line.
+func unquoteLabel(s string) (value, rest string, err error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated label value")
+}
+
+// Get returns the value of the sample with the given name and labels, or
+// zero if there is none.
+func (ss Samples) Get(name string, labels ...string) float64 {
+	for _, s := range ss {
+		if s.Name == name && s.matches(labels) {
+			return s.Value
+		}
+	}
+	return 0
+}
+
+// Sum adds up the samples with the given name whose labels include the
+// given name-value pairs.
+func (ss Samples) Sum(name string, labels ...string) float64 {
+	total := 0.0
+	for _, s := range ss {
+		if s.Name == name && s.matches(labels) {
+			total += s.Value
+		}
+	}
+	return total
+}
+
+func (s Sample) matches(pairs []string) bool {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if s.Labels[pairs[i]] != pairs[i+1] {
+			return false
+		}
+	}
+	return true
+}
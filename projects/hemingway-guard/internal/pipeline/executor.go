@@ -0,0 +1,289 @@
+// Package pipeline carries a held message from the popover's answer back
+// to the app it was written in.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+)
+
+// ErrNoText indicates an action that replaces the message came without text.
+var ErrNoText = errors.New("action has no replacement text")
+
+// ErrNotApplied indicates the replacement text didn't stick in the field.
+var ErrNotApplied = errors.New("text was not applied to the field")
+
+// ErrBlocked indicates send anyway was chosen for a blocked message, which
+// only an override sends.
+var ErrBlocked = errors.New("message is blocked; only an override sends it")
+
+// ErrNotOffered indicates an action the review didn't offer, such as send
+// anyway when enforcement requires an edit.
+var ErrNotOffered = errors.New("action was not offered")
+
+// ErrUnknownAction indicates an action this build doesn't handle.
+var ErrUnknownAction = errors.New("unknown action")
+
+// logger logs how actions are applied.
+var logger = logging.Component("pipeline")
+
+// Field is the composer holding the message. *accessibility.FocusMonitor
+// implements it for the live system.
+type Field interface {
+	// CurrentElement returns the focused field, retained, or nil.
+	CurrentElement() *accessibility.Element
+	CurrentText() string
+	SetCurrentText(text string) error
+}
+
+// ElementField adapts a single element, such as one served by
+// accessibility.Replay, to Field.
+type ElementField struct {
+	Element *accessibility.Element
+}
+
+// CurrentElement returns the element, retained.
+func (f ElementField) CurrentElement() *accessibility.Element {
+	return f.Element.Retain()
+}
+
+// CurrentText returns the element's value.
+func (f ElementField) CurrentText() string {
+	return f.Element.Value()
+}
+
+// SetCurrentText sets the element's value.
+func (f ElementField) SetCurrentText(text string) error {
+	return f.Element.SetValue(text)
+}
+
+// Paster replaces the focused field's contents through the clipboard.
+type Paster interface {
+	Paste(text string) error
+}
+
+// PasterFunc adapts a function such as keyboard.PasteText to Paster.
+type PasterFunc func(text string) error
+
+// Paste calls f.
+func (f PasterFunc) Paste(text string) error {
+	return f(text)
+}
+
+// Sender sends the held message. *keyboard.Interceptor implements it.
+type Sender interface {
+	ReleaseEnter()
+}
+
+// Notifier tells the user why a held message wasn't sent.
+type Notifier interface {
+	Notify(message string) error
+}
+
+// NotifierFunc adapts a function to Notifier.
+type NotifierFunc func(message string) error
+
+// Notify calls f.
+func (f NotifierFunc) Notify(message string) error {
+	return f(message)
+}
+
+// Result describes what Execute did.
+type Result struct {
+	Replaced bool // the field's text was replaced
+	Pasted   bool // the replacement went through the clipboard
+	Sent     bool // the held Enter was released
+}
+
+// Executor applies the user's answer from the popover: it writes any
+// replacement text into the field, then sends or keeps holding the message.
+type Executor struct {
+	field       Field
+	sender      Sender
+	paster      Paster
+	notifier    Notifier
+	pasteSettle time.Duration
+}
+
+// NewExecutor creates an executor for the given field and sender.
+func NewExecutor(field Field, sender Sender) *Executor {
+	return &Executor{
+		field:       field,
+		sender:      sender,
+		pasteSettle: 500 * time.Millisecond,
+	}
+}
+
+// SetPaster sets the fallback used when the field ignores AXValue writes.
+// Without one, such fields fail with ErrNotApplied.
+func (x *Executor) SetPaster(p Paster) {
+	x.paster = p
+}
+
+// SetNotifier sets where to report messages that were held back because
+// the target changed.
+func (x *Executor) SetNotifier(n Notifier) {
+	x.notifier = n
+}
+
+// SetPasteSettle sets how long to wait for a paste to show up in the field.
+func (x *Executor) SetPasteSettle(d time.Duration) {
+	x.pasteSettle = d
+}
+
+// Execute applies resp to review. Send anyway sends the original message.
+// Edit replaces the message with the edited text and sends it. Use
+// suggestion puts the suggestion in the field but keeps holding it so the
+// user can look it over before pressing Enter again. Cancel keeps holding
+// the message. When replacement fails the message is never sent.
+//
+// Choices the review doesn't offer are refused with ErrNotOffered, or
+// ErrBlocked for send anyway on a blocked message, which only override
+// sends. When send anyway isn't offered, edited text is put in the field
+// but not sent, so it is analyzed again when the user presses Enter.
+//
+// fp is the field as it was when Enter was intercepted. Nothing is written
+// or sent unless focus is still on that field with the same text; otherwise
+// Execute returns ErrTargetChanged and notifies the user.
+func (x *Executor) Execute(resp ipc.ActionResponse, review ipc.ReviewRequest, fp *Fingerprint) (Result, error) {
+	if resp.Action == ipc.ActionCancel {
+		return Result{}, nil
+	}
+
+	analysis := review.Analysis
+	if !review.Offers(resp.Action) {
+		if resp.Action == ipc.ActionSendAnyway && analysis != nil && analysis.Blocked {
+			x.notify("Message not sent: it looks like it contains a credential. Remove it, or choose Override to send it anyway.")
+			return Result{}, ErrBlocked
+		}
+		x.notify("Message not sent: it has to be changed first. Edit it and press Enter again.")
+		return Result{}, fmt.Errorf("%w: %s", ErrNotOffered, resp.Action)
+	}
+
+	original := x.field.CurrentText()
+	if err := x.verify(fp, original, true); err != nil {
+		return Result{}, err
+	}
+
+	switch resp.Action {
+	case ipc.ActionSendAnyway, ipc.ActionOverride:
+		x.sender.ReleaseEnter()
+		return Result{Sent: true}, nil
+
+	case ipc.ActionEdit:
+		result, err := x.replace(resp.EditedText)
+		if err != nil || !review.Offers(ipc.ActionSendAnyway) {
+			return result, err
+		}
+		// Focus may have moved while the text was being written
+		if err := x.verify(fp, "", false); err != nil {
+			x.restore(fp, original)
+			return Result{}, err
+		}
+		x.sender.ReleaseEnter()
+		result.Sent = true
+		return result, nil
+
+	case ipc.ActionUseSuggestion:
+		text := resp.EditedText
+		if text == "" && analysis != nil {
+			text = analysis.Suggestion
+		}
+		return x.replace(text)
+
+	default:
+		return Result{}, fmt.Errorf("%w %q", ErrUnknownAction, resp.Action)
+	}
+}
+
+// verify compares the focused field against fp, and its text too when
+// checkText is set. A mismatch is reported to the user.
+func (x *Executor) verify(fp *Fingerprint, text string, checkText bool) error {
+	current := x.field.CurrentElement()
+	if current != nil {
+		defer current.Release()
+	}
+
+	var err error
+	if checkText {
+		err = fp.Verify(current, text)
+	} else {
+		err = fp.VerifyTarget(current)
+	}
+	if err != nil {
+		x.notify("Message not sent: the conversation changed while it was held. Check it and press Enter again.")
+	}
+	return err
+}
+
+// restore puts the original text back into the fingerprinted field after
+// a replacement landed but can no longer be sent.
+func (x *Executor) restore(fp *Fingerprint, original string) {
+	if err := fp.Element().SetValue(original); err != nil {
+		logger.Error("failed to restore original text", logging.Err(err))
+	}
+}
+
+func (x *Executor) notify(message string) {
+	if x.notifier == nil {
+		return
+	}
+	if err := x.notifier.Notify(message); err != nil {
+		logger.Warn("failed to notify user", logging.Err(err))
+	}
+}
+
+// replace writes text into the field through AXValue, falling back to a
+// paste when the write fails or doesn't stick.
+func (x *Executor) replace(text string) (Result, error) {
+	if strings.TrimSpace(text) == "" {
+		return Result{}, ErrNoText
+	}
+
+	err := x.field.SetCurrentText(text)
+	if err == nil && sameText(x.field.CurrentText(), text) {
+		return Result{Replaced: true}, nil
+	}
+	if err != nil {
+		logger.Debug("setting AXValue failed", logging.Err(err))
+	} else {
+		logger.Debug("AXValue write did not stick")
+	}
+
+	if x.paster == nil {
+		return Result{}, ErrNotApplied
+	}
+	if err := x.paster.Paste(text); err != nil {
+		return Result{}, fmt.Errorf("paste failed: %w", err)
+	}
+	if !x.waitForText(text) {
+		return Result{}, ErrNotApplied
+	}
+	return Result{Replaced: true, Pasted: true}, nil
+}
+
+// waitForText polls the field until it holds text or pasteSettle passes.
+// Pasted text arrives asynchronously.
+func (x *Executor) waitForText(text string) bool {
+	deadline := time.Now().Add(x.pasteSettle)
+	for {
+		if sameText(x.field.CurrentText(), text) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+}
+
+// sameText compares field contents as read back from the app.
+func sameText(got, want string) bool {
+	return normalizeText(got) == normalizeText(want)
+}
@@ -0,0 +1,345 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+)
+
+// chatWindow is a Slack window with a search field and a focused composer.
+const chatWindow = `{"version": 1, "bundle_id": "com.tinyspeck.slackmacgap", "pid": 42,
+"window": {"role": "AXWindow", "title": "general (Channel) - Acme - Slack", "children": [
+	{"role": "AXTextField", "identifier": "search", "editable": true},
+	{"role": "AXTextArea", "description": "Message #general", "editable": true, "focused": true,
+	 "value": "Would you be able to possibly check the deploy?"}
+]}}`
+
+const heldText = "Would you be able to possibly check the deploy?"
+
+func loadWindow(t *testing.T, snapshot string) *accessibility.Replay {
+	t.Helper()
+	snap, err := accessibility.ReadSnapshot(strings.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	return accessibility.NewReplay(snap)
+}
+
+// fakeField is a composer whose text the tests control. Writes go through
+// write, which decides what the app would show afterwards.
+type fakeField struct {
+	mu      sync.Mutex
+	focused *accessibility.Element
+	text    string
+	setErr  error
+	write   func(text string) string
+	writes  []string
+}
+
+func (f *fakeField) CurrentElement() *accessibility.Element {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.focused == nil {
+		return nil
+	}
+	return f.focused.Retain()
+}
+
+func (f *fakeField) CurrentText() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.text
+}
+
+func (f *fakeField) SetCurrentText(text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes = append(f.writes, text)
+	if f.setErr != nil {
+		return f.setErr
+	}
+	if f.write != nil {
+		f.text = f.write(text)
+	} else {
+		f.text = text
+	}
+	return nil
+}
+
+func (f *fakeField) setText(text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.text = text
+}
+
+// focus moves focus to e; nil means nothing is focused.
+func (f *fakeField) focus(e *accessibility.Element) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.focused = e
+}
+
+type fakeSender struct{ released int }
+
+func (s *fakeSender) ReleaseEnter() { s.released++ }
+
+type fakePaster struct {
+	field  *fakeField
+	err    error
+	lands  bool
+	pasted []string
+}
+
+func (p *fakePaster) Paste(text string) error {
+	p.pasted = append(p.pasted, text)
+	if p.err != nil {
+		return p.err
+	}
+	if p.lands {
+		// Pasted text shows up a little later
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			p.field.setText(text)
+		}()
+	}
+	return nil
+}
+
+type fakeNotifier struct{ messages []string }
+
+func (n *fakeNotifier) Notify(message string) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+// harness wires an executor to fakes around chatWindow, with the composer
+// fingerprinted holding heldText.
+type harness struct {
+	replay   *accessibility.Replay
+	composer *accessibility.Element
+	field    *fakeField
+	sender   *fakeSender
+	paster   *fakePaster
+	notifier *fakeNotifier
+	fp       *Fingerprint
+	x        *Executor
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{replay: loadWindow(t, chatWindow)}
+	h.composer = h.replay.Focused()
+	h.field = &fakeField{focused: h.composer, text: heldText}
+	h.sender = &fakeSender{}
+	h.paster = &fakePaster{field: h.field}
+	h.notifier = &fakeNotifier{}
+	h.fp = CaptureFingerprint(h.composer, heldText)
+
+	h.x = NewExecutor(h.field, h.sender)
+	h.x.SetPaster(h.paster)
+	h.x.SetNotifier(h.notifier)
+	h.x.SetPasteSettle(200 * time.Millisecond)
+
+	t.Cleanup(func() {
+		h.fp.Release()
+		h.composer.Release()
+	})
+	return h
+}
+
+// Reviews as policy.Decide offers them.
+var (
+	gateActions  = []ipc.Action{ipc.ActionSendAnyway, ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}
+	blockActions = []ipc.Action{ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}
+)
+
+func reviewWith(actions []ipc.Action) ipc.ReviewRequest {
+	return ipc.ReviewRequest{
+		Analysis:     &analyzer.Analysis{Suggestion: "Can you check the deploy?"},
+		OriginalText: heldText,
+		Actions:      actions,
+	}
+}
+
+func TestExecuteActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     ipc.ActionResponse
+		actions  []ipc.Action
+		want     Result
+		wantText string
+	}{
+		{"cancel", ipc.ActionResponse{Action: ipc.ActionCancel}, gateActions, Result{}, heldText},
+		{"send anyway", ipc.ActionResponse{Action: ipc.ActionSendAnyway}, gateActions, Result{Sent: true}, heldText},
+		{"edit sends", ipc.ActionResponse{Action: ipc.ActionEdit, EditedText: "Check the deploy?"}, gateActions,
+			Result{Replaced: true, Sent: true}, "Check the deploy?"},
+		{"edit withheld without send anyway", ipc.ActionResponse{Action: ipc.ActionEdit, EditedText: "Check the deploy?"}, blockActions,
+			Result{Replaced: true}, "Check the deploy?"},
+		{"suggestion is held for review", ipc.ActionResponse{Action: ipc.ActionUseSuggestion}, gateActions,
+			Result{Replaced: true}, "Can you check the deploy?"},
+		{"suggestion as edited in the popover", ipc.ActionResponse{Action: ipc.ActionUseSuggestion, EditedText: "Deploy ok?"}, gateActions,
+			Result{Replaced: true}, "Deploy ok?"},
+		{"everything offered without a list", ipc.ActionResponse{Action: ipc.ActionSendAnyway}, nil, Result{Sent: true}, heldText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			got, err := h.x.Execute(tt.resp, reviewWith(tt.actions), h.fp)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Result = %+v, want %+v", got, tt.want)
+			}
+			releases := 0
+			if tt.want.Sent {
+				releases = 1
+			}
+			if h.sender.released != releases {
+				t.Errorf("Enter released %d times, want %d", h.sender.released, releases)
+			}
+			if got := h.field.CurrentText(); got != tt.wantText {
+				t.Errorf("field text = %q, want %q", got, tt.wantText)
+			}
+			if len(h.paster.pasted) != 0 {
+				t.Errorf("pasted %q", h.paster.pasted)
+			}
+		})
+	}
+}
+
+func TestExecuteRefusesUnofferedActions(t *testing.T) {
+	blocked := reviewWith(nil)
+	blocked.Analysis.Blocked = true
+	override := reviewWith([]ipc.Action{ipc.ActionOverride, ipc.ActionEdit, ipc.ActionCancel})
+
+	tests := []struct {
+		name   string
+		resp   ipc.ActionResponse
+		review ipc.ReviewRequest
+		want   error
+	}{
+		{"send anyway under block", ipc.ActionResponse{Action: ipc.ActionSendAnyway}, reviewWith(blockActions), ErrNotOffered},
+		{"override not offered", ipc.ActionResponse{Action: ipc.ActionOverride}, reviewWith(gateActions), ErrNotOffered},
+		{"send anyway on a blocked message", ipc.ActionResponse{Action: ipc.ActionSendAnyway}, blocked, ErrBlocked},
+		{"send anyway beside override", ipc.ActionResponse{Action: ipc.ActionSendAnyway}, override, ErrNotOffered},
+		{"edit not offered", ipc.ActionResponse{Action: ipc.ActionEdit, EditedText: "x"}, reviewWith([]ipc.Action{ipc.ActionSendAnyway}), ErrNotOffered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			_, err := h.x.Execute(tt.resp, tt.review, h.fp)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Execute = %v, want %v", err, tt.want)
+			}
+			if h.sender.released != 0 || len(h.field.writes) != 0 {
+				t.Error("refused action touched the field or sent")
+			}
+			if len(h.notifier.messages) != 1 {
+				t.Errorf("notices = %q, want one", h.notifier.messages)
+			}
+		})
+	}
+}
+
+func TestExecuteOverride(t *testing.T) {
+	h := newHarness(t)
+	review := reviewWith([]ipc.Action{ipc.ActionOverride, ipc.ActionEdit, ipc.ActionCancel})
+	review.Analysis.Blocked = true
+
+	got, err := h.x.Execute(ipc.ActionResponse{Action: ipc.ActionOverride}, review, h.fp)
+	if err != nil || !got.Sent || h.sender.released != 1 {
+		t.Errorf("override = %+v, %v; released %d", got, err, h.sender.released)
+	}
+}
+
+func TestExecuteUnknownAction(t *testing.T) {
+	h := newHarness(t)
+	_, err := h.x.Execute(ipc.ActionResponse{Action: "launch"}, reviewWith([]ipc.Action{"launch"}), h.fp)
+	if !errors.Is(err, ErrUnknownAction) || h.sender.released != 0 {
+		t.Errorf("Execute = %v, released %d", err, h.sender.released)
+	}
+}
+
+func TestExecuteReplacementFallback(t *testing.T) {
+	const edited = "Check the deploy?"
+	edit := ipc.ActionResponse{Action: ipc.ActionEdit, EditedText: edited}
+
+	tests := []struct {
+		name    string
+		setup   func(h *harness)
+		want    Result
+		wantErr error
+	}{
+		{"read back with other line endings", func(h *harness) {
+			h.field.write = func(text string) string { return text + "\r\n" }
+		}, Result{Replaced: true, Sent: true}, nil},
+		{"write ignored, paste lands", func(h *harness) {
+			h.field.write = func(string) string { return heldText }
+			h.paster.lands = true
+		}, Result{Replaced: true, Pasted: true, Sent: true}, nil},
+		{"write fails, paste lands", func(h *harness) {
+			h.field.setErr = errors.New("AXError -25200")
+			h.paster.lands = true
+		}, Result{Replaced: true, Pasted: true, Sent: true}, nil},
+		{"read back mismatch, paste never lands", func(h *harness) {
+			h.field.write = func(text string) string { return text[:5] }
+		}, Result{}, ErrNotApplied},
+		{"paste fails", func(h *harness) {
+			h.field.setErr = errors.New("AXError -25200")
+			h.paster.err = errors.New("pasteboard unavailable")
+		}, Result{}, nil},
+		{"no paster", func(h *harness) {
+			h.field.write = func(string) string { return heldText }
+			h.x.SetPaster(nil)
+		}, Result{}, ErrNotApplied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			tt.setup(h)
+
+			got, err := h.x.Execute(edit, reviewWith(gateActions), h.fp)
+			if tt.want.Sent {
+				if err != nil {
+					t.Fatalf("Execute: %v", err)
+				}
+			} else if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("Execute = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Result = %+v, want %+v", got, tt.want)
+			}
+			if sent := h.sender.released > 0; sent != tt.want.Sent {
+				t.Errorf("Enter released = %v, want %v", sent, tt.want.Sent)
+			}
+			if tt.want.Pasted && (len(h.paster.pasted) != 1 || h.paster.pasted[0] != edited) {
+				t.Errorf("pasted %q", h.paster.pasted)
+			}
+		})
+	}
+}
+
+func TestExecuteRefusesEmptyReplacement(t *testing.T) {
+	h := newHarness(t)
+	review := reviewWith(gateActions)
+	review.Analysis.Suggestion = ""
+
+	for _, resp := range []ipc.ActionResponse{
+		{Action: ipc.ActionEdit, EditedText: "  \n"},
+		{Action: ipc.ActionUseSuggestion},
+	} {
+		if _, err := h.x.Execute(resp, review, h.fp); !errors.Is(err, ErrNoText) {
+			t.Errorf("%s = %v, want ErrNoText", resp.Action, err)
+		}
+	}
+	if h.sender.released != 0 || len(h.field.writes) != 0 {
+		t.Error("empty replacement touched the field or sent")
+	}
+}
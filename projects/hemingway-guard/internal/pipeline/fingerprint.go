@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+)
+
+// ErrTargetChanged indicates focus moved away from the field a message was
+// held in, so sending now would deliver it to the wrong place.
+var ErrTargetChanged = errors.New("target field changed")
+
+// Fingerprint identifies the field and message at the moment Enter was
+// intercepted.
+type Fingerprint struct {
+	PID         int
+	BundleID    string
+	WindowTitle string
+	TextHash    [sha256.Size]byte
+
+	element *accessibility.Element
+	window  *accessibility.Element
+}
+
+// CaptureFingerprint records the identity of e and the text it holds. The
+// fingerprint keeps e alive; call Release when done with it.
+func CaptureFingerprint(e *accessibility.Element, text string) *Fingerprint {
+	fp := &Fingerprint{
+		PID:      e.PID(),
+		BundleID: e.BundleID(),
+		TextHash: hashText(text),
+		element:  e.Retain(),
+		window:   e.Window(),
+	}
+	if fp.window != nil {
+		fp.WindowTitle = fp.window.Title()
+	}
+	return fp
+}
+
+// Element returns the fingerprinted field. It stays owned by the
+// fingerprint.
+func (fp *Fingerprint) Element() *accessibility.Element {
+	return fp.element
+}
+
+// Release frees the elements held by the fingerprint.
+func (fp *Fingerprint) Release() {
+	fp.element.Release()
+	if fp.window != nil {
+		fp.window.Release()
+	}
+}
+
+// Verify checks that current is the fingerprinted field and still holds
+// the fingerprinted text.
+func (fp *Fingerprint) Verify(current *accessibility.Element, text string) error {
+	if err := fp.VerifyTarget(current); err != nil {
+		return err
+	}
+	if hashText(text) != fp.TextHash {
+		return fmt.Errorf("%w: message text changed", ErrTargetChanged)
+	}
+	return nil
+}
+
+// VerifyTarget checks that current is the fingerprinted field, in the same
+// app and conversation, regardless of its text.
+func (fp *Fingerprint) VerifyTarget(current *accessibility.Element) error {
+	if current == nil {
+		return fmt.Errorf("%w: no field is focused", ErrTargetChanged)
+	}
+	if current.PID() != fp.PID || current.BundleID() != fp.BundleID {
+		return fmt.Errorf("%w: app changed to %s", ErrTargetChanged, current.BundleID())
+	}
+
+	window := current.Window()
+	if window == nil {
+		return fmt.Errorf("%w: field has no window", ErrTargetChanged)
+	}
+	defer window.Release()
+	if fp.window == nil || !window.Equal(fp.window) {
+		return fmt.Errorf("%w: window changed", ErrTargetChanged)
+	}
+	// Switching conversations often keeps the window but changes its title.
+	// The title names a contact or channel, so it stays out of the error.
+	if window.Title() != fp.WindowTitle {
+		return fmt.Errorf("%w: conversation changed", ErrTargetChanged)
+	}
+
+	if !current.Equal(fp.element) {
+		return fmt.Errorf("%w: focused field changed", ErrTargetChanged)
+	}
+	return nil
+}
+
+func hashText(text string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(normalizeText(text)))
+}
+
+// normalizeText smooths over the line ending and trailing whitespace
+// differences apps introduce when text is read back.
+func normalizeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.TrimRight(s, " \t\n")
+}
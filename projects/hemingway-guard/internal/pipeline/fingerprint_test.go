@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/internal/accessibility"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+)
+
+// searchField returns the search field beside the composer in chatWindow.
+func searchField(t *testing.T, replay *accessibility.Replay) *accessibility.Element {
+	t.Helper()
+	window := replay.Window()
+	defer window.Release()
+	children := window.Children()
+	for _, child := range children[1:] {
+		child.Release()
+	}
+	t.Cleanup(children[0].Release)
+	return children[0]
+}
+
+func TestFingerprintVerify(t *testing.T) {
+	replay := loadWindow(t, chatWindow)
+	composer := replay.Focused()
+	defer composer.Release()
+	fp := CaptureFingerprint(composer, heldText)
+	defer fp.Release()
+
+	if fp.PID != 42 || fp.BundleID != "com.tinyspeck.slackmacgap" || fp.WindowTitle != "general (Channel) - Acme - Slack" {
+		t.Errorf("fingerprint = %+v", fp)
+	}
+	if err := fp.Verify(composer, heldText); err != nil {
+		t.Errorf("Verify of the same field and text: %v", err)
+	}
+	// Apps read text back with other line endings and trailing space
+	if err := fp.Verify(composer, heldText+" \r\n"); err != nil {
+		t.Errorf("Verify with trailing whitespace: %v", err)
+	}
+	if err := fp.VerifyTarget(composer); err != nil {
+		t.Errorf("VerifyTarget: %v", err)
+	}
+}
+
+func TestFingerprintMismatch(t *testing.T) {
+	other := loadWindow(t, strings.NewReplacer(`"pid": 42`, `"pid": 77`, "slackmacgap", "Discord").Replace(chatWindow))
+
+	tests := []struct {
+		name    string
+		current func(t *testing.T, replay *accessibility.Replay) *accessibility.Element
+		text    string
+		detail  string
+	}{
+		{"text changed", func(t *testing.T, r *accessibility.Replay) *accessibility.Element {
+			e := r.Focused()
+			t.Cleanup(e.Release)
+			return e
+		}, "Would you check the deploy?", "text changed"},
+		{"nothing focused", func(*testing.T, *accessibility.Replay) *accessibility.Element {
+			return nil
+		}, heldText, "no field"},
+		{"other field", func(t *testing.T, r *accessibility.Replay) *accessibility.Element {
+			return searchField(t, r)
+		}, heldText, "focused field changed"},
+		{"other app", func(t *testing.T, _ *accessibility.Replay) *accessibility.Element {
+			e := other.Focused()
+			t.Cleanup(e.Release)
+			return e
+		}, heldText, "app changed"},
+		{"other conversation", func(t *testing.T, r *accessibility.Replay) *accessibility.Element {
+			r.Snapshot().Window.Title = "random (Channel) - Acme - Slack"
+			e := r.Focused()
+			t.Cleanup(e.Release)
+			return e
+		}, heldText, "conversation changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := loadWindow(t, chatWindow)
+			composer := replay.Focused()
+			defer composer.Release()
+			fp := CaptureFingerprint(composer, heldText)
+			defer fp.Release()
+
+			err := fp.Verify(tt.current(t, replay), tt.text)
+			if !errors.Is(err, ErrTargetChanged) {
+				t.Fatalf("Verify = %v, want ErrTargetChanged", err)
+			}
+			if !strings.Contains(err.Error(), tt.detail) {
+				t.Errorf("Verify = %v, want it to mention %q", err, tt.detail)
+			}
+			// The error is logged, so it can't name the message or the conversation
+			for _, private := range []string{heldText, tt.text, "general", "random"} {
+				if strings.Contains(err.Error(), private) {
+					t.Errorf("Verify = %v, which mentions %q", err, private)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteTargetChanged(t *testing.T) {
+	for _, action := range []ipc.ActionResponse{
+		{Action: ipc.ActionSendAnyway},
+		{Action: ipc.ActionEdit, EditedText: "Check the deploy?"},
+		{Action: ipc.ActionUseSuggestion},
+	} {
+		t.Run(string(action.Action), func(t *testing.T) {
+			h := newHarness(t)
+			h.field.focus(searchField(t, h.replay))
+
+			_, err := h.x.Execute(action, reviewWith(gateActions), h.fp)
+			if !errors.Is(err, ErrTargetChanged) {
+				t.Fatalf("Execute = %v, want ErrTargetChanged", err)
+			}
+			if h.sender.released != 0 || len(h.field.writes) != 0 {
+				t.Error("wrote to or sent from a field that changed")
+			}
+			if len(h.notifier.messages) != 1 || !strings.Contains(h.notifier.messages[0], "conversation changed") {
+				t.Errorf("notices = %q", h.notifier.messages)
+			}
+		})
+	}
+
+	t.Run("message edited meanwhile", func(t *testing.T) {
+		h := newHarness(t)
+		h.field.setText(heldText + " Thanks!")
+		_, err := h.x.Execute(ipc.ActionResponse{Action: ipc.ActionSendAnyway}, reviewWith(gateActions), h.fp)
+		if !errors.Is(err, ErrTargetChanged) || h.sender.released != 0 {
+			t.Errorf("Execute = %v, released %d", err, h.sender.released)
+		}
+	})
+}
+
+// Focus can move while an edit is being written. The edit is then taken
+// back out of the field it landed in, and nothing is sent.
+func TestExecuteRestoresWhenFocusMovesDuringEdit(t *testing.T) {
+	h := newHarness(t)
+	search := searchField(t, h.replay)
+	h.field.write = func(text string) string {
+		h.composer.SetValue(text)
+		h.field.focused = search // under the field's lock
+		return text
+	}
+
+	_, err := h.x.Execute(ipc.ActionResponse{Action: ipc.ActionEdit, EditedText: "Check the deploy?"}, reviewWith(gateActions), h.fp)
+	if !errors.Is(err, ErrTargetChanged) {
+		t.Fatalf("Execute = %v, want ErrTargetChanged", err)
+	}
+	if h.sender.released != 0 {
+		t.Error("Enter released after focus moved")
+	}
+	if got := h.composer.Value(); got != heldText {
+		t.Errorf("composer holds %q, want the original text back", got)
+	}
+}
@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+)
+
+// Enforcement is what happens to a checked message that wasn't approved.
+type Enforcement string
+
+const (
+	// EnforceObserve records the analysis and sends every message that
+	// isn't blocked.
+	EnforceObserve Enforcement = "observe"
+	// EnforceAdvise sends every message that isn't blocked and shows the
+	// issues of those that weren't approved.
+	EnforceAdvise Enforcement = "advise"
+	// EnforceGate holds messages that weren't approved until the user
+	// chooses what to do in the popover. It is the default.
+	EnforceGate Enforcement = "gate"
+	// EnforceBlock is gate, except that messages with error or block
+	// findings can't be sent as written; the user has to change them.
+	EnforceBlock Enforcement = "block"
+)
+
+// Enforcements lists the modes from least to most strict.
+var Enforcements = []Enforcement{EnforceObserve, EnforceAdvise, EnforceGate, EnforceBlock}
+
+// ParseEnforcement parses a mode name. Empty means gate.
+func ParseEnforcement(s string) (Enforcement, error) {
+	if s == "" {
+		return EnforceGate, nil
+	}
+	for _, e := range Enforcements {
+		if strings.EqualFold(s, string(e)) {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown enforcement mode %q; use observe, advise, gate or block", s)
+}
+
+// Verdict sorts analyses by how they are enforced.
+type Verdict int
+
+const (
+	// VerdictApproved means the message is fine.
+	VerdictApproved Verdict = iota
+	// VerdictRejected means the message wasn't approved, but nothing
+	// found is worse than a warning.
+	VerdictRejected
+	// VerdictSevere means a finding has error severity.
+	VerdictSevere
+	// VerdictBlocked means a finding blocks the message, such as a
+	// pasted credential.
+	VerdictBlocked
+	// VerdictFailed means the analysis failed.
+	VerdictFailed
+)
+
+// Verdicts lists every verdict.
+var Verdicts = []Verdict{VerdictApproved, VerdictRejected, VerdictSevere, VerdictBlocked, VerdictFailed}
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictApproved:
+		return "approved"
+	case VerdictRejected:
+		return "rejected"
+	case VerdictSevere:
+		return "severe"
+	case VerdictBlocked:
+		return "blocked"
+	case VerdictFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// VerdictOf sorts the result of analyzer.Analyze. A message the local
+// rules block stays blocked even when the provider failed.
+func VerdictOf(a *analyzer.Analysis, err error) Verdict {
+	switch {
+	case a != nil && a.Blocked:
+		return VerdictBlocked
+	case err != nil || a == nil:
+		return VerdictFailed
+	case a.Approved:
+		return VerdictApproved
+	}
+	for _, f := range a.Findings {
+		if f.Severity >= analyzer.SeverityError {
+			return VerdictSevere
+		}
+	}
+	return VerdictRejected
+}
+
+// Outcome is what the interceptor does with the held Enter.
+type Outcome int
+
+const (
+	// OutcomeSend lets the message through.
+	OutcomeSend Outcome = iota
+	// OutcomeAdvise lets the message through and shows its issues.
+	OutcomeAdvise
+	// OutcomeReview holds the message and asks the user in the popover.
+	OutcomeReview
+	// OutcomeHold holds the message. The user has to change it and press
+	// Enter again.
+	OutcomeHold
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSend:
+		return "send"
+	case OutcomeAdvise:
+		return "advise"
+	case OutcomeReview:
+		return "review"
+	case OutcomeHold:
+		return "hold"
+	}
+	return "unknown"
+}
+
+// Decision is what to do with one intercepted message.
+type Decision struct {
+	Outcome Outcome
+	// Actions are the choices the popover offers for OutcomeReview. Edit
+	// only sends when send anyway is among them; otherwise edited text is
+	// analyzed again.
+	Actions []ipc.Action
+}
+
+// Popover choices for each kind of review.
+var (
+	reviewActions   = []ipc.Action{ipc.ActionSendAnyway, ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}
+	overrideActions = []ipc.Action{ipc.ActionOverride, ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}
+	changeActions   = []ipc.Action{ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}
+)
+
+// Decide picks the outcome for a message with verdict v under mode e.
+// canReview is whether the popover can show it. Failed analyses are
+// always sent, so a broken model never stops anyone from talking.
+// Blocked messages need an explicit override in every mode, observe and
+// advise included; block mode doesn't offer one.
+//
+// Without the popover, gate sends messages it would have reviewed, as
+// nobody can choose, but still holds blocked ones. Block holds everything
+// it wouldn't let through as written.
+func Decide(e Enforcement, v Verdict, canReview bool) Decision {
+	switch {
+	case v == VerdictApproved || v == VerdictFailed:
+		return Decision{Outcome: OutcomeSend}
+	case v == VerdictBlocked && e != EnforceBlock:
+		return review(overrideActions, canReview, OutcomeHold)
+	}
+
+	switch e {
+	case EnforceObserve:
+		return Decision{Outcome: OutcomeSend}
+	case EnforceAdvise:
+		return Decision{Outcome: OutcomeAdvise}
+	case EnforceBlock:
+		if v == VerdictSevere || v == VerdictBlocked {
+			return review(changeActions, canReview, OutcomeHold)
+		}
+		return review(reviewActions, canReview, OutcomeSend)
+	}
+
+	// Gate, and anything unknown
+	return review(reviewActions, canReview, OutcomeSend)
+}
+
+// review asks the user, or falls back to fallback without the popover.
+func review(actions []ipc.Action, canReview bool, fallback Outcome) Decision {
+	if !canReview {
+		return Decision{Outcome: fallback}
+	}
+	return Decision{Outcome: OutcomeReview, Actions: append([]ipc.Action(nil), actions...)}
+}
@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/lancekrogers/hemingway-guard/internal/analyzer"
+	"github.com/lancekrogers/hemingway-guard/internal/ipc"
+)
+
+func TestVerdictOf(t *testing.T) {
+	errFailed := errors.New("provider failed")
+	finding := func(s analyzer.Severity) []analyzer.Finding {
+		return []analyzer.Finding{{Rule: "test/rule", Severity: s}}
+	}
+	tests := []struct {
+		name     string
+		analysis *analyzer.Analysis
+		err      error
+		want     Verdict
+	}{
+		{"approved", &analyzer.Analysis{Approved: true}, nil, VerdictApproved},
+		{"rejected by the model", &analyzer.Analysis{}, nil, VerdictRejected},
+		{"warnings", &analyzer.Analysis{Findings: finding(analyzer.SeverityWarning)}, nil, VerdictRejected},
+		{"errors", &analyzer.Analysis{Findings: finding(analyzer.SeverityError)}, nil, VerdictSevere},
+		{"blocked", &analyzer.Analysis{Blocked: true, Findings: finding(analyzer.SeverityBlock)}, nil, VerdictBlocked},
+		{"failed", nil, errFailed, VerdictFailed},
+		{"failed with local results", &analyzer.Analysis{Approved: true}, errFailed, VerdictFailed},
+		{"failed but blocked locally", &analyzer.Analysis{Blocked: true}, errFailed, VerdictBlocked},
+		{"no analysis", nil, nil, VerdictFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerdictOf(tt.analysis, tt.err); got != tt.want {
+				t.Errorf("VerdictOf = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	var (
+		send     = Decision{Outcome: OutcomeSend}
+		advise   = Decision{Outcome: OutcomeAdvise}
+		hold     = Decision{Outcome: OutcomeHold}
+		review   = Decision{Outcome: OutcomeReview, Actions: []ipc.Action{ipc.ActionSendAnyway, ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}}
+		override = Decision{Outcome: OutcomeReview, Actions: []ipc.Action{ipc.ActionOverride, ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}}
+		change   = Decision{Outcome: OutcomeReview, Actions: []ipc.Action{ipc.ActionUseSuggestion, ipc.ActionEdit, ipc.ActionCancel}}
+	)
+
+	// Every mode and verdict, with the popover and without it
+	tests := []struct {
+		mode              Enforcement
+		verdict           Verdict
+		popover, noReview Decision
+	}{
+		{EnforceObserve, VerdictApproved, send, send},
+		{EnforceObserve, VerdictRejected, send, send},
+		{EnforceObserve, VerdictSevere, send, send},
+		{EnforceObserve, VerdictBlocked, override, hold},
+		{EnforceObserve, VerdictFailed, send, send},
+
+		{EnforceAdvise, VerdictApproved, send, send},
+		{EnforceAdvise, VerdictRejected, advise, advise},
+		{EnforceAdvise, VerdictSevere, advise, advise},
+		{EnforceAdvise, VerdictBlocked, override, hold},
+		{EnforceAdvise, VerdictFailed, send, send},
+
+		{EnforceGate, VerdictApproved, send, send},
+		{EnforceGate, VerdictRejected, review, send},
+		{EnforceGate, VerdictSevere, review, send},
+		{EnforceGate, VerdictBlocked, override, hold},
+		{EnforceGate, VerdictFailed, send, send},
+
+		{EnforceBlock, VerdictApproved, send, send},
+		{EnforceBlock, VerdictRejected, review, send},
+		{EnforceBlock, VerdictSevere, change, hold},
+		{EnforceBlock, VerdictBlocked, change, hold},
+		{EnforceBlock, VerdictFailed, send, send},
+
+		// Unknown modes act like gate
+		{"", VerdictRejected, review, send},
+		{"strict", VerdictBlocked, override, hold},
+	}
+
+	covered := make(map[Enforcement]map[Verdict]bool)
+	for _, tt := range tests {
+		if covered[tt.mode] == nil {
+			covered[tt.mode] = make(map[Verdict]bool)
+		}
+		covered[tt.mode][tt.verdict] = true
+		for _, c := range []struct {
+			canReview bool
+			want      Decision
+		}{{true, tt.popover}, {false, tt.noReview}} {
+			t.Run(fmt.Sprintf("%s/%s/popover=%v", tt.mode, tt.verdict, c.canReview), func(t *testing.T) {
+				got := Decide(tt.mode, tt.verdict, c.canReview)
+				if got.Outcome != c.want.Outcome || !reflect.DeepEqual(got.Actions, c.want.Actions) {
+					t.Errorf("Decide = %v %v, want %v %v", got.Outcome, got.Actions, c.want.Outcome, c.want.Actions)
+				}
+			})
+		}
+	}
+	for _, mode := range Enforcements {
+		for _, v := range Verdicts {
+			if !covered[mode][v] {
+				t.Errorf("no case for %s with %s", mode, v)
+			}
+		}
+	}
+}
+
+func TestDecideHoldsBlockedInEveryMode(t *testing.T) {
+	for _, mode := range Enforcements {
+		for _, canReview := range []bool{true, false} {
+			d := Decide(mode, VerdictBlocked, canReview)
+			if d.Outcome == OutcomeSend || d.Outcome == OutcomeAdvise {
+				t.Errorf("%s, popover=%v: blocked message %s", mode, canReview, d.Outcome)
+			}
+			if slices.Contains(d.Actions, ipc.ActionSendAnyway) {
+				t.Errorf("%s: blocked message offers send anyway", mode)
+			}
+		}
+	}
+}
+
+func TestDecideCopiesActions(t *testing.T) {
+	d := Decide(EnforceGate, VerdictRejected, true)
+	d.Actions[0] = ipc.ActionCancel
+	if again := Decide(EnforceGate, VerdictRejected, true); again.Actions[0] != ipc.ActionSendAnyway {
+		t.Errorf("changing one decision's actions changed the next: %v", again.Actions)
+	}
+}
+
+func TestParseEnforcement(t *testing.T) {
+	for s, want := range map[string]Enforcement{"": EnforceGate, "observe": EnforceObserve, "Advise": EnforceAdvise, "GATE": EnforceGate, "block": EnforceBlock} {
+		if got, err := ParseEnforcement(s); err != nil || got != want {
+			t.Errorf("ParseEnforcement(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseEnforcement("strict"); err == nil {
+		t.Error("ParseEnforcement accepted an unknown mode")
+	}
+}
@@ -0,0 +1,257 @@
+// Package policy decides whether messages are checked, from the menu bar
+// switch, snoozes, apps turned off by hand and schedule rules, and what
+// happens to those that aren't approved. It has no platform code and reads
+// time from an injected clock, so every decision can be tested.
+package policy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Mode is why messages are or aren't checked, most important first.
+type Mode int
+
+const (
+	// ModeActive means messages are checked.
+	ModeActive Mode = iota
+	// ModeOff means the menu bar switch is off.
+	ModeOff
+	// ModeSnoozed means checking is paused for a while.
+	ModeSnoozed
+	// ModeOutsideSchedule means no schedule rule covers the current time.
+	ModeOutsideSchedule
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeActive:
+		return "active"
+	case ModeOff:
+		return "off"
+	case ModeSnoozed:
+		return "snoozed"
+	case ModeOutsideSchedule:
+		return "outside_schedule"
+	}
+	return "unknown"
+}
+
+// State is a snapshot of the policy.
+type State struct {
+	Mode Mode
+	// Enabled is the menu bar switch, whatever the mode.
+	Enabled bool
+	// SnoozedUntil is when a snooze ends; zero while snoozed means until
+	// resumed.
+	SnoozedUntil time.Time
+	// DisabledApps are the bundle IDs turned off by hand, sorted.
+	DisabledApps []string
+	// NextChange is when the mode may change by itself, because a snooze
+	// ends or a schedule window opens or closes. Zero if never.
+	NextChange time.Time
+}
+
+// Policy holds the settings that decide whether messages are checked. It
+// is safe for concurrent use.
+type Policy struct {
+	mu           sync.Mutex
+	now          func() time.Time
+	enabled      bool
+	snoozed      bool
+	snoozedUntil time.Time // zero: until resumed
+	disabledApps map[string]bool
+	rules        []Rule
+	enforcement  Enforcement
+	appModes     map[string]Enforcement
+}
+
+// New returns an enabled policy without schedule rules that checks every
+// app and gates messages that aren't approved.
+func New() *Policy {
+	return &Policy{
+		now:          time.Now,
+		enabled:      true,
+		disabledApps: make(map[string]bool),
+		enforcement:  EnforceGate,
+	}
+}
+
+// SetClock replaces time.Now, for tests.
+func (p *Policy) SetClock(now func() time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.now = now
+}
+
+// SetEnabled sets the menu bar switch.
+func (p *Policy) SetEnabled(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled = enabled
+}
+
+// Enabled returns the menu bar switch.
+func (p *Policy) Enabled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enabled
+}
+
+// Snooze pauses checking for d, or until Resume if d is zero or less.
+func (p *Policy) Snooze(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snoozed = true
+	p.snoozedUntil = time.Time{}
+	if d > 0 {
+		p.snoozedUntil = p.now().Add(d)
+	}
+}
+
+// SnoozeUntil pauses checking until t, or until Resume if t is zero.
+func (p *Policy) SnoozeUntil(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snoozed = true
+	p.snoozedUntil = t
+}
+
+// Resume ends a snooze.
+func (p *Policy) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snoozed = false
+	p.snoozedUntil = time.Time{}
+}
+
+// SetAppEnabled turns checking on or off for the app with bundleID.
+func (p *Policy) SetAppEnabled(bundleID string, enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if enabled {
+		delete(p.disabledApps, bundleID)
+	} else {
+		p.disabledApps[bundleID] = true
+	}
+}
+
+// SetRules replaces the schedule rules. Without rules, checking is on at
+// all times.
+func (p *Policy) SetRules(rules []Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append([]Rule(nil), rules...)
+}
+
+// SetEnforcement sets the enforcement mode for every app, and the modes
+// of apps that differ, by bundle ID.
+func (p *Policy) SetEnforcement(mode Enforcement, apps map[string]Enforcement) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enforcement = mode
+	p.appModes = make(map[string]Enforcement, len(apps))
+	for id, m := range apps {
+		p.appModes[id] = m
+	}
+}
+
+// Enforcement returns the enforcement mode of the app with bundleID.
+func (p *Policy) Enforcement(bundleID string) Enforcement {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.appModes[bundleID]; ok {
+		return m
+	}
+	return p.enforcement
+}
+
+// Enforced reports whether messages in the app with bundleID are checked
+// now.
+func (p *Policy) Enforced(bundleID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if p.mode(now) != ModeActive || p.disabledApps[bundleID] {
+		return false
+	}
+	if len(p.rules) == 0 {
+		return true
+	}
+	for _, r := range p.rules {
+		if r.Matches(bundleID, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// State returns a snapshot of the policy.
+func (p *Policy) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	st := State{
+		Mode:         p.mode(now),
+		Enabled:      p.enabled,
+		DisabledApps: []string{},
+		NextChange:   p.nextChange(now),
+	}
+	if st.Mode == ModeSnoozed {
+		st.SnoozedUntil = p.snoozedUntil
+	}
+	for id := range p.disabledApps {
+		st.DisabledApps = append(st.DisabledApps, id)
+	}
+	sort.Strings(st.DisabledApps)
+	return st
+}
+
+func (p *Policy) mode(now time.Time) Mode {
+	switch {
+	case !p.enabled:
+		return ModeOff
+	case p.snoozed && (p.snoozedUntil.IsZero() || now.Before(p.snoozedUntil)):
+		return ModeSnoozed
+	case len(p.rules) > 0 && !p.anyOpen(now):
+		return ModeOutsideSchedule
+	}
+	return ModeActive
+}
+
+// anyOpen reports whether any rule's window covers now, for any app.
+func (p *Policy) anyOpen(now time.Time) bool {
+	for _, r := range p.rules {
+		if r.open(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextChange returns the earliest snooze end or rule boundary after now.
+func (p *Policy) nextChange(now time.Time) time.Time {
+	var next time.Time
+	consider := func(t time.Time) {
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if p.snoozed && !p.snoozedUntil.IsZero() {
+		consider(p.snoozedUntil)
+	}
+	for _, r := range p.rules {
+		for _, t := range r.boundaries(now) {
+			consider(t)
+		}
+	}
+	return next
+}
+
+// Tomorrow returns midnight at the start of the day after t, in t's time
+// zone, for "snooze until tomorrow".
+func Tomorrow(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
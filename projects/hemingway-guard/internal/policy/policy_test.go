@@ -0,0 +1,217 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for Policy.SetClock.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newPolicy(start time.Time) (*Policy, *clock) {
+	c := &clock{t: start}
+	p := New()
+	p.SetClock(c.now)
+	return p, c
+}
+
+const (
+	slack   = "com.tinyspeck.slackmacgap"
+	discord = "com.hnc.Discord"
+)
+
+// saturday is 2026-03-07, a Saturday, at noon.
+var saturday = time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+
+func TestSnoozeExpires(t *testing.T) {
+	p, c := newPolicy(saturday)
+	p.Snooze(15 * time.Minute)
+
+	st := p.State()
+	end := saturday.Add(15 * time.Minute)
+	if st.Mode != ModeSnoozed || !st.SnoozedUntil.Equal(end) || !st.NextChange.Equal(end) {
+		t.Errorf("snoozed: %+v, want snoozed until and changing at %v", st, end)
+	}
+	if p.Enforced(slack) {
+		t.Error("messages checked while snoozed")
+	}
+
+	c.advance(15*time.Minute - time.Second)
+	if p.State().Mode != ModeSnoozed {
+		t.Error("snooze ended early")
+	}
+	c.advance(time.Second)
+	st = p.State()
+	if st.Mode != ModeActive || !st.SnoozedUntil.IsZero() || !st.NextChange.IsZero() {
+		t.Errorf("after the snooze: %+v, want active with nothing pending", st)
+	}
+	if !p.Enforced(slack) {
+		t.Error("messages not checked after the snooze ran out")
+	}
+}
+
+func TestSnoozeUntilResumed(t *testing.T) {
+	p, c := newPolicy(saturday)
+	p.Snooze(0)
+	c.advance(30 * 24 * time.Hour)
+	if st := p.State(); st.Mode != ModeSnoozed || !st.SnoozedUntil.IsZero() || !st.NextChange.IsZero() {
+		t.Errorf("open-ended snooze: %+v, want snoozed with no end", st)
+	}
+	p.Resume()
+	if st := p.State(); st.Mode != ModeActive {
+		t.Errorf("after Resume: mode %v, want active", st.Mode)
+	}
+}
+
+func TestSnoozeAgainReplaces(t *testing.T) {
+	p, c := newPolicy(saturday)
+	p.Snooze(time.Hour)
+	c.advance(10 * time.Minute)
+	p.Snooze(15 * time.Minute)
+	if got, want := p.State().SnoozedUntil, saturday.Add(25*time.Minute); !got.Equal(want) {
+		t.Errorf("SnoozedUntil = %v, want %v", got, want)
+	}
+	c.advance(15 * time.Minute)
+	if p.State().Mode != ModeActive {
+		t.Error("the first, longer snooze still applies")
+	}
+}
+
+func TestSnoozeUntilTomorrowAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	tests := []struct {
+		name  string
+		start time.Time
+		wait  time.Duration // real time until midnight
+	}{
+		{"spring forward", time.Date(2026, 3, 7, 20, 0, 0, 0, loc), 4 * time.Hour},
+		{"day of spring forward", time.Date(2026, 3, 8, 0, 30, 0, 0, loc), 22*time.Hour + 30*time.Minute},
+		{"day of fall back", time.Date(2026, 11, 1, 0, 30, 0, 0, loc), 24*time.Hour + 30*time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, c := newPolicy(tt.start)
+			until := Tomorrow(tt.start)
+			if until.Hour() != 0 || until.Minute() != 0 || until.Day() == tt.start.Day() {
+				t.Fatalf("Tomorrow(%v) = %v, want the next midnight", tt.start, until)
+			}
+			if got := until.Sub(tt.start); got != tt.wait {
+				t.Errorf("Tomorrow is %v away, want %v", got, tt.wait)
+			}
+
+			p.SnoozeUntil(until)
+			c.advance(tt.wait - time.Minute)
+			if p.State().Mode != ModeSnoozed {
+				t.Error("snooze ended before midnight")
+			}
+			c.advance(time.Minute)
+			if p.State().Mode != ModeActive {
+				t.Error("snooze didn't end at midnight")
+			}
+		})
+	}
+}
+
+func TestSnoozeForDurationAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	// An hour's snooze is an hour of real time, not of wall clock
+	p, c := newPolicy(time.Date(2026, 3, 8, 1, 30, 0, 0, loc))
+	p.Snooze(time.Hour)
+	until := p.State().SnoozedUntil
+	if got := until.In(loc).Format("15:04 MST"); got != "03:30 EDT" {
+		t.Errorf("snooze ends at %s, want 03:30 EDT", got)
+	}
+	c.advance(time.Hour)
+	if p.State().Mode != ModeActive {
+		t.Error("snooze outlasted an hour")
+	}
+}
+
+func TestScheduleAcrossMidnight(t *testing.T) {
+	p, c := newPolicy(time.Date(2026, 3, 7, 21, 0, 0, 0, time.UTC)) // Saturday
+	p.SetRules([]Rule{
+		{Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, From: 9 * 60, To: 18 * 60},
+		{Apps: []string{slack}, Days: []time.Weekday{time.Saturday}, From: 22 * 60, To: 2 * 60},
+	})
+
+	steps := []struct {
+		at       time.Time
+		mode     Mode
+		slack    bool
+		discord  bool
+		nextOpen time.Time
+	}{
+		{time.Date(2026, 3, 7, 21, 0, 0, 0, time.UTC), ModeOutsideSchedule, false, false, time.Date(2026, 3, 7, 22, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 7, 22, 0, 0, 0, time.UTC), ModeActive, true, false, time.Date(2026, 3, 8, 2, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 8, 1, 59, 0, 0, time.UTC), ModeActive, true, false, time.Date(2026, 3, 8, 2, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 8, 2, 0, 0, 0, time.UTC), ModeOutsideSchedule, false, false, time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), ModeActive, true, true, time.Date(2026, 3, 9, 18, 0, 0, 0, time.UTC)},
+	}
+	for _, s := range steps {
+		c.t = s.at
+		st := p.State()
+		if st.Mode != s.mode || !st.NextChange.Equal(s.nextOpen) {
+			t.Errorf("%s: mode %v next change %v, want %v and %v", s.at.Format("Mon 15:04"), st.Mode, st.NextChange, s.mode, s.nextOpen)
+		}
+		if p.Enforced(slack) != s.slack || p.Enforced(discord) != s.discord {
+			t.Errorf("%s: Slack checked %v, Discord %v; want %v and %v", s.at.Format("Mon 15:04"), p.Enforced(slack), p.Enforced(discord), s.slack, s.discord)
+		}
+	}
+}
+
+func TestScheduleNextChangeAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	p, _ := newPolicy(time.Date(2026, 3, 7, 23, 0, 0, 0, loc))
+	p.SetRules([]Rule{{From: 22 * 60, To: 6 * 60}})
+
+	st := p.State()
+	if st.Mode != ModeActive {
+		t.Fatalf("mode = %v, want active", st.Mode)
+	}
+	want := time.Date(2026, 3, 8, 6, 0, 0, 0, loc)
+	if !st.NextChange.Equal(want) {
+		t.Errorf("NextChange = %v, want %v", st.NextChange, want)
+	}
+	// The night the clocks go forward is an hour shorter
+	if got := st.NextChange.Sub(time.Date(2026, 3, 7, 23, 0, 0, 0, loc)); got != 6*time.Hour {
+		t.Errorf("window closes in %v, want 6h", got)
+	}
+}
+
+func TestModePrecedence(t *testing.T) {
+	p, c := newPolicy(saturday)
+	p.SetRules([]Rule{{Days: []time.Weekday{time.Monday}}})
+	if got := p.State().Mode; got != ModeOutsideSchedule {
+		t.Errorf("mode = %v, want outside_schedule", got)
+	}
+	p.Snooze(time.Hour)
+	if got := p.State().Mode; got != ModeSnoozed {
+		t.Errorf("mode = %v, want snoozed over the schedule", got)
+	}
+	p.SetEnabled(false)
+	if st := p.State(); st.Mode != ModeOff || !st.SnoozedUntil.IsZero() {
+		t.Errorf("state = %+v, want off, hiding the snooze", st)
+	}
+	p.SetEnabled(true)
+	c.advance(2 * 24 * time.Hour) // Monday
+	if got := p.State().Mode; got != ModeActive {
+		t.Errorf("mode = %v, want active", got)
+	}
+}
+
+func TestDisabledApps(t *testing.T) {
+	p, _ := newPolicy(saturday)
+	p.SetAppEnabled(discord, false)
+	p.SetAppEnabled(slack, false)
+	p.SetAppEnabled(slack, true)
+	if p.Enforced(discord) || !p.Enforced(slack) {
+		t.Errorf("Discord checked %v, Slack %v; want only Slack", p.Enforced(discord), p.Enforced(slack))
+	}
+	if got := p.State().DisabledApps; !reflect.DeepEqual(got, []string{discord}) {
+		t.Errorf("DisabledApps = %v, want Discord", got)
+	}
+}
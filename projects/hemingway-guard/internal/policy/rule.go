@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TimeOfDay is minutes after midnight, from 0 to 24*60.
+type TimeOfDay int
+
+// ParseTimeOfDay parses "HH:MM" on a 24-hour clock. "24:00" is the end of
+// the day.
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %q; use HH:MM", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return TimeOfDay(h*60 + m), nil
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t/60, t%60)
+}
+
+// on returns the time t on the day of date, in date's time zone.
+func (t TimeOfDay) on(date time.Time) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, int(t)/60, int(t)%60, 0, 0, date.Location())
+}
+
+func timeOfDay(t time.Time) TimeOfDay {
+	return TimeOfDay(t.Hour()*60 + t.Minute())
+}
+
+var dayNames = map[string][]time.Weekday{
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		dayNames[name] = []time.Weekday{d}
+		dayNames[name[:3]] = []time.Weekday{d}
+	}
+}
+
+// ParseDays parses day names: "mon" or "monday" through "sun", plus
+// "weekdays" and "weekends". Case doesn't matter.
+func ParseDays(names []string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range names {
+		d, ok := dayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		for _, day := range d {
+			if !slices.Contains(days, day) {
+				days = append(days, day)
+			}
+		}
+	}
+	return days, nil
+}
+
+// Rule is a schedule window in which messages are checked. When a policy
+// has rules, an app is checked only while a rule covering it is open.
+type Rule struct {
+	// Apps are the bundle IDs the rule covers; empty covers every app.
+	Apps []string
+	// Days the window opens on; empty is every day.
+	Days []time.Weekday
+	// From and To bound the window. Equal values mean all day, and a
+	// window ending before it starts runs past midnight into the next day.
+	From, To TimeOfDay
+}
+
+// Matches reports whether the rule covers bundleID at t.
+func (r Rule) Matches(bundleID string, t time.Time) bool {
+	if len(r.Apps) > 0 && !slices.Contains(r.Apps, bundleID) {
+		return false
+	}
+	return r.open(t)
+}
+
+// open reports whether the window covers t, whatever the app.
+func (r Rule) open(t time.Time) bool {
+	now := timeOfDay(t)
+	today := r.onDay(t.Weekday())
+	switch {
+	case r.From == r.To:
+		return today
+	case r.From < r.To:
+		return today && now >= r.From && now < r.To
+	}
+	// Overnight: the part after midnight belongs to the day it started
+	yesterday := r.onDay((t.Weekday() + 6) % 7)
+	return (today && now >= r.From) || (yesterday && now < r.To)
+}
+
+func (r Rule) onDay(d time.Weekday) bool {
+	return len(r.Days) == 0 || slices.Contains(r.Days, d)
+}
+
+// boundaries returns the times in the coming week at which the window
+// opens or closes.
+func (r Rule) boundaries(now time.Time) []time.Time {
+	var times []time.Time
+	for i := 0; i <= 7; i++ {
+		y, m, d := now.Date()
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, now.Location())
+		today, yesterday := r.onDay(day.Weekday()), r.onDay((day.Weekday()+6)%7)
+		switch {
+		case r.From == r.To:
+			if today != yesterday {
+				times = append(times, day)
+			}
+		case r.From < r.To:
+			if today {
+				times = append(times, r.From.on(day), r.To.on(day))
+			}
+		default:
+			if today {
+				times = append(times, r.From.on(day))
+			}
+			if yesterday {
+				times = append(times, r.To.on(day))
+			}
+		}
+	}
+	return times
+}
@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // DST tests need America/New_York wherever they run
+)
+
+// newYork has DST transitions on 2026-03-08 (02:00 -> 03:00) and
+// 2026-11-01 (02:00 -> 01:00).
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func hhmm(t *testing.T, s string) TimeOfDay {
+	t.Helper()
+	tod, err := ParseTimeOfDay(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tod
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	for s, want := range map[string]TimeOfDay{"00:00": 0, "09:30": 570, "23:59": 1439, "24:00": 1440} {
+		if got, err := ParseTimeOfDay(s); err != nil || got != want {
+			t.Errorf("ParseTimeOfDay(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "9:30", "24:01", "12:60", "-1:00", "noon", "12:00pm"} {
+		if _, err := ParseTimeOfDay(s); err == nil {
+			t.Errorf("ParseTimeOfDay(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	days, err := ParseDays([]string{"Weekends", " fri ", "saturday"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Weekday{time.Saturday, time.Sunday, time.Friday}
+	if len(days) != len(want) {
+		t.Fatalf("ParseDays = %v, want %v", days, want)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("ParseDays = %v, want %v", days, want)
+		}
+	}
+	if _, err := ParseDays([]string{"someday"}); err == nil {
+		t.Error("ParseDays accepted an unknown day")
+	}
+}
+
+func TestRuleOpen(t *testing.T) {
+	// 2026-03-07 is a Saturday
+	at := func(day int, clock string) time.Time {
+		tod := hhmm(t, clock)
+		return time.Date(2026, 3, day, int(tod)/60, int(tod)%60, 0, 0, time.UTC)
+	}
+	workHours := Rule{Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, From: hhmm(t, "09:00"), To: hhmm(t, "18:00")}
+	saturdayNight := Rule{Days: []time.Weekday{time.Saturday}, From: hhmm(t, "22:00"), To: hhmm(t, "02:00")}
+	everyNight := Rule{From: hhmm(t, "22:00"), To: hhmm(t, "06:00")}
+	sundays := Rule{Days: []time.Weekday{time.Sunday}}
+
+	tests := []struct {
+		name string
+		rule Rule
+		at   time.Time
+		want bool
+	}{
+		{"work hours open", workHours, at(9, "09:00"), true},
+		{"work hours last minute", workHours, at(9, "17:59"), true},
+		{"work hours close", workHours, at(9, "18:00"), false},
+		{"work hours before", workHours, at(9, "08:59"), false},
+		{"work hours on a weekend", workHours, at(7, "12:00"), false},
+
+		{"overnight before it opens", saturdayNight, at(7, "21:59"), false},
+		{"overnight opens", saturdayNight, at(7, "22:00"), true},
+		{"overnight at midnight", saturdayNight, at(8, "00:00"), true},
+		{"overnight after midnight", saturdayNight, at(8, "01:59"), true},
+		{"overnight closes", saturdayNight, at(8, "02:00"), false},
+		{"overnight, next evening", saturdayNight, at(8, "23:00"), false},
+		{"overnight, morning of its own day", saturdayNight, at(7, "01:00"), false},
+		{"every night, early morning", everyNight, at(9, "05:59"), true},
+		{"every night, daytime", everyNight, at(9, "12:00"), false},
+
+		{"all day", sundays, at(8, "00:00"), true},
+		{"all day, late", sundays, at(8, "23:59"), true},
+		{"all day, next day", sundays, at(9, "00:00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.open(tt.at); got != tt.want {
+				t.Errorf("open(%s) = %v, want %v", tt.at.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatchesApps(t *testing.T) {
+	r := Rule{Apps: []string{"com.tinyspeck.slackmacgap"}}
+	now := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+	if !r.Matches("com.tinyspeck.slackmacgap", now) {
+		t.Error("rule doesn't match its app")
+	}
+	if r.Matches("com.hnc.Discord", now) {
+		t.Error("rule matches an app it doesn't cover")
+	}
+	if !(Rule{}).Matches("com.hnc.Discord", now) {
+		t.Error("rule without apps doesn't match every app")
+	}
+}
+
+func TestRuleOpenAcrossDST(t *testing.T) {
+	loc := newYork(t)
+	night := Rule{From: hhmm(t, "22:00"), To: hhmm(t, "06:00")}
+	early := Rule{From: hhmm(t, "01:00"), To: hhmm(t, "03:00")}
+
+	tests := []struct {
+		name string
+		rule Rule
+		at   time.Time
+		want bool
+	}{
+		// Spring forward: 02:00 EST becomes 03:00 EDT
+		{"night, before the jump", night, time.Date(2026, 3, 8, 1, 59, 0, 0, loc), true},
+		{"night, after the jump", night, time.Date(2026, 3, 8, 3, 0, 0, 0, loc), true},
+		{"night closes on the wall clock", night, time.Date(2026, 3, 8, 6, 0, 0, 0, loc), false},
+		{"early, an hour after 01:30 is 03:30", early, time.Date(2026, 3, 8, 1, 30, 0, 0, loc).Add(time.Hour), false},
+		// Fall back: 01:00-02:00 happens twice
+		{"early, first 01:30", early, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC).In(loc), true},
+		{"early, second 01:30", early, time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC).In(loc), true},
+		{"early closes", early, time.Date(2026, 11, 1, 3, 0, 0, 0, loc), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.open(tt.at); got != tt.want {
+				t.Errorf("open(%s) = %v, want %v", tt.at.Format("Jan 2 15:04 MST"), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package textutil
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// chatPattern finds one kind of chat markup that isn't prose.
+type chatPattern struct {
+	kind    TokenKind
+	pattern *regexp.Regexp
+	// trim, if set, shortens a match, e.g. to drop a sentence's period
+	// from the end of a link.
+	trim func(match string) string
+}
+
+// chatPatterns are tried in order; a span claimed by one isn't matched
+// again by a later one. Code comes first, so nothing inside it counts.
+var chatPatterns = []chatPattern{
+	// Fenced blocks may open mid-line in Slack and Discord, and run to
+	// the end of the message if never closed
+	{kind: Code, pattern: regexp.MustCompile("```(?s:.*?)(?:```|\\z)")},
+	{kind: Code, pattern: regexp.MustCompile("`[^`\n]+`")},
+	// Slack's escaped forms: <https://...|label>, <@U123>, <#C123|general>,
+	// <!here>; Discord's <@123>, <#123> and <:name:123>
+	{kind: URL, pattern: regexp.MustCompile(`<(?:https?|mailto):[^>|\s]*(?:\|[^>\n]*)?>`)}, // labels may have spaces
+	{kind: Mention, pattern: regexp.MustCompile(`<(?:@[!&]?|#|!)[\w|.^-]+>`)},
+	{kind: Shortcode, pattern: regexp.MustCompile(`<a?:\w+:\d+>`)},
+	{kind: URL, pattern: regexp.MustCompile(`\b(?:https?://|www\.)\S+`), trim: trimURL},
+	{kind: Mention, pattern: regexp.MustCompile(`(?:^|[^\w@])([@#][A-Za-z][\w.-]*)`), trim: trimMention},
+	// Shortcodes stand apart from words, so "a:b:c" and "note:this:" aren't
+	// emoji; \B holds at a colon only next to a non-word character or an end
+	{kind: Shortcode, pattern: regexp.MustCompile(`\B:[a-z0-9_+-]*[a-z][a-z0-9_+-]*:\B`)},
+}
+
+// TokenizeChat splits a chat message like Tokenize, except that code, links,
+// mentions, channels and emoji shortcodes are single tokens of kind Code,
+// URL, Mention or Shortcode.
+func TokenizeChat(s string) []Token {
+	var marks []Token
+	claimed := func(start, end int) bool {
+		for _, m := range marks {
+			if start < m.End && m.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range chatPatterns {
+		for _, loc := range p.pattern.FindAllStringSubmatchIndex(s, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) > 2 {
+				start, end = loc[2], loc[3] // the group, without its boundary
+			}
+			match := s[start:end]
+			if p.trim != nil {
+				match = p.trim(match)
+				end = start + len(match)
+			}
+			if match == "" || claimed(start, end) {
+				continue
+			}
+			marks = append(marks, Token{Kind: p.kind, Text: match, Start: start, End: end})
+		}
+	}
+	sort.Slice(marks, func(i, j int) bool { return marks[i].Start < marks[j].Start })
+
+	var tokens []Token
+	last := 0
+	prose := func(end int) {
+		for _, t := range Tokenize(s[last:end]) {
+			t.Start += last
+			t.End += last
+			tokens = append(tokens, t)
+		}
+	}
+	for _, m := range marks {
+		prose(m.Start)
+		tokens = append(tokens, m)
+		last = m.End
+	}
+	prose(len(s))
+	return tokens
+}
+
+// MaskChat blanks out the parts of a chat message that aren't prose: code,
+// links, mentions, channels and emoji shortcodes. Masked bytes become
+// spaces (newlines are kept), so offsets still point into the message.
+func MaskChat(s string) string {
+	b := []byte(s)
+	for _, t := range TokenizeChat(s) {
+		if t.Kind < Code {
+			continue
+		}
+		for i := t.Start; i < t.End; i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	return string(b)
+}
+
+// CountChatWords returns the number of words in a chat message, leaving out
+// code, links, mentions and shortcodes.
+func CountChatWords(s string) int {
+	return CountWords(MaskChat(s))
+}
+
+// TruncateChatWords is TruncateWords for chat messages: code, links,
+// mentions and shortcodes aren't counted, and are kept or dropped whole.
+func TruncateChatWords(s string, maxWords int) string {
+	return truncateWords(s, MaskChat(s), maxWords)
+}
+
+// trimURL drops trailing punctuation that more likely ends the sentence
+// than the link, and a closing parenthesis with no opening one in the link.
+func trimURL(u string) string {
+	for u != "" {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(`.,;:!?'"*_~>`, last) >= 0:
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+		default:
+			return u
+		}
+		u = u[:len(u)-1]
+	}
+	return u
+}
+
+// trimMention drops trailing periods and hyphens, as in "thanks @sam.".
+func trimMention(m string) string {
+	return strings.TrimRight(m, ".-")
+}
@@ -0,0 +1,95 @@
+package textutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// marks lists a message's chat tokens as "kind:text".
+func marks(s string) []string {
+	var out []string
+	for _, t := range TokenizeChat(s) {
+		if t.Kind >= Code {
+			out = append(out, fmt.Sprintf("%s:%s", t.Kind, t.Text))
+		}
+	}
+	return out
+}
+
+func TestTokenizeChat(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"inline code", "run `go test ./...` first", []string{"code:`go test ./...`"}},
+		{"code block", "like this:\n```\nfmt.Println(\"@sam :tada:\")\n```\nok?", []string{"code:```\nfmt.Println(\"@sam :tada:\")\n```"}},
+		{"unclosed block", "```\nstill going", []string{"code:```\nstill going"}},
+		{"code isn't split across lines", "one ` and\nanother `", nil},
+		{"link", "see https://example.com/docs.", []string{"url:https://example.com/docs"}},
+		{"link in parentheses", "(docs: https://en.wikipedia.org/wiki/Go_(language))", []string{"url:https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"www link", "try www.example.com, then", []string{"url:www.example.com"}},
+		{"slack link", "<https://example.com|the docs> has it", []string{"url:<https://example.com|the docs>"}},
+		{"mention", "thanks @sam.", []string{"mention:@sam"}},
+		{"channel", "ask in #launch-planning", []string{"mention:#launch-planning"}},
+		{"slack mentions", "<@U024BE7LH> see <#C123|general> <!here>", []string{"mention:<@U024BE7LH>", "mention:<#C123|general>", "mention:<!here>"}},
+		{"email isn't a mention", "mail sam@example.com", nil},
+		{"shortcode", "shipped :tada:", []string{"shortcode::tada:"}},
+		{"shortcode with punctuation", "(:white_check_mark:)!", []string{"shortcode::white_check_mark:"}},
+		{"adjacent shortcodes", ":thumbsup::skin-tone-2:", []string{"shortcode::thumbsup:", "shortcode::skin-tone-2:"}},
+		{"discord emoji", "gg <:pepe:123456789>", []string{"shortcode:<:pepe:123456789>"}},
+		{"colons between words", "a:b:c", nil},
+		{"label", "note:this: matters", nil},
+		{"suffix", "the :tada:s", nil},
+		{"times", "at 10:30:45 today", nil},
+		{"ratio", "mix 1:2:3", nil},
+		{"code wins", "`@sam https://x.y :tada:`", []string{"code:`@sam https://x.y :tada:`"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := marks(tt.text); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("TokenizeChat(%q) marks %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskChat(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"run `go test` now", "run           now"},
+		{"see https://x.io.", "see             ."},
+		{"hi @sam :wave:", "hi " + strings.Repeat(" ", 11)},
+		{"```\nx\n```\nthen", "   \n \n   \nthen"},
+		{"a:b:c stays", "a:b:c stays"},
+	}
+	for _, tt := range tests {
+		got := MaskChat(tt.text)
+		if got != tt.want {
+			t.Errorf("MaskChat(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if len(got) != len(tt.text) {
+			t.Errorf("MaskChat(%q) changed the length", tt.text)
+		}
+	}
+}
+
+func TestChatWords(t *testing.T) {
+	const msg = "hey @sam, the fix is in `parser.go` :tada: see https://example.com/pr/12 for details"
+	if got, want := CountChatWords(msg), 8; got != want {
+		t.Errorf("CountChatWords = %d, want %d", got, want)
+	}
+	// Markup isn't counted, and is kept whole
+	if got, want := TruncateChatWords(msg, 6), "hey @sam, the fix is in `parser.go` :tada: see..."; got != want {
+		t.Errorf("TruncateChatWords(6) = %q, want %q", got, want)
+	}
+	if got, want := TruncateChatWords(msg, 5), "hey @sam, the fix is in..."; got != want {
+		t.Errorf("TruncateChatWords(5) = %q, want %q", got, want)
+	}
+	if got := TruncateChatWords(msg, 8); got != msg {
+		t.Errorf("TruncateChatWords(8) = %q, want the whole message", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Package textutil splits, truncates and normalizes message text without
+// breaking characters apart.
+//
+// Byte offsets are used throughout, so results line up with the spans the
+// analyzer reports. Truncation cuts between grapheme clusters: an emoji
+// with a skin tone, a flag or a letter with combining accents is kept whole
+// or dropped whole.
+package textutil
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis marks where text was cut.
+const ellipsis = "..."
+
+// NextGrapheme returns the length in bytes of the grapheme cluster that
+// starts s, or 0 if s is empty.
+//
+// Clusters follow the common cases of Unicode's extended grapheme clusters:
+// CR LF, combining marks, variation selectors, emoji modifiers and tags,
+// ZWJ emoji sequences and regional indicator pairs (flags). Invalid UTF-8
+// bytes are clusters of their own.
+func NextGrapheme(s string) int {
+	if s == "" {
+		return 0
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	if r == '\r' && len(s) > 1 && s[1] == '\n' {
+		return 2
+	}
+	if r == utf8.RuneError || isControl(r) {
+		return n
+	}
+
+	prev := r
+	regional := isRegionalIndicator(r)
+	for n < len(s) {
+		next, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case next == utf8.RuneError && size == 1:
+			return n
+		case isExtend(next):
+		case prev == zwj && isPictographic(next):
+		case regional && isRegionalIndicator(next):
+			// A flag is exactly two indicators
+			regional = false
+		default:
+			return n
+		}
+		if !isRegionalIndicator(next) {
+			regional = false
+		}
+		prev = next
+		n += size
+	}
+	return n
+}
+
+// GraphemeCount returns the number of grapheme clusters in s.
+func GraphemeCount(s string) int {
+	count := 0
+	for s != "" {
+		s = s[NextGrapheme(s):]
+		count++
+	}
+	return count
+}
+
+// Truncate shortens s to at most maxGraphemes grapheme clusters, marking
+// the cut with "...". The marker isn't counted.
+func Truncate(s string, maxGraphemes int) string {
+	end := 0
+	for i := 0; i < maxGraphemes && end < len(s); i++ {
+		end += NextGrapheme(s[end:])
+	}
+	if end >= len(s) {
+		return s
+	}
+	return s[:end] + ellipsis
+}
+
+// TruncateBytes shortens s to at most maxBytes bytes, cut between grapheme
+// clusters and marked with "...". The marker isn't counted.
+func TruncateBytes(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	end := 0
+	for end < len(s) {
+		n := NextGrapheme(s[end:])
+		if end+n > maxBytes {
+			break
+		}
+		end += n
+	}
+	return s[:end] + ellipsis
+}
+
+const zwj = '\u200d'
+
+func isControl(r rune) bool {
+	return r == '\n' || r == '\r' || unicode.IsControl(r) || r == '\u2028' || r == '\u2029'
+}
+
+// isExtend reports whether r attaches to the rune before it.
+func isExtend(r rune) bool {
+	switch {
+	case r == zwj:
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tones
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // emoji tags, used in subdivision flags
+		return true
+	}
+	// Combining marks, including variation selectors
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isPictographic approximates Unicode's Extended_Pictographic property:
+// the emoji that ZWJ sequences join.
+func isPictographic(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // miscellaneous symbols and dingbats
+		return true
+	}
+	return unicode.Is(unicode.So, r)
+}
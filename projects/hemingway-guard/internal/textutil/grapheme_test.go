@@ -0,0 +1,69 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// graphemeSeeds are strings whose clusters span several runes.
+var graphemeSeeds = []string{
+	"",
+	"plain ascii",
+	"café résumé",
+	"é̂ stacked accents",
+	"👍🏽 thumbs up",
+	"👩‍👩‍👧‍👦 family",
+	"🇯🇵🇺🇸 flags",
+	"🏴\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F scotland",
+	"line\r\nbreak",
+	"\xff\xfe invalid bytes",
+}
+
+func FuzzTruncate(f *testing.F) {
+	for _, s := range graphemeSeeds {
+		f.Add(s, 3)
+	}
+	f.Fuzz(func(t *testing.T, s string, limit int) {
+		limit %= 64
+		if limit < 0 {
+			limit = -limit
+		}
+
+		for _, c := range []struct {
+			name string
+			out  string
+			size func(string) int
+		}{
+			{"Truncate", Truncate(s, limit), GraphemeCount},
+			{"TruncateBytes", TruncateBytes(s, limit), func(s string) int { return len(s) }},
+		} {
+			if c.out == s {
+				continue
+			}
+			kept, ok := strings.CutSuffix(c.out, ellipsis)
+			if !ok || !strings.HasPrefix(s, kept) {
+				t.Fatalf("%s(%q, %d) = %q, want a prefix of the input and %q", c.name, s, limit, c.out, ellipsis)
+			}
+			if n := c.size(kept); n > limit {
+				t.Errorf("%s(%q, %d) kept %q, %d over the limit", c.name, s, limit, kept, n-limit)
+			}
+			// A cut between clusters can't split a character
+			if utf8.ValidString(s) && !utf8.ValidString(c.out) {
+				t.Errorf("%s(%q, %d) = %q, which isn't valid UTF-8", c.name, s, limit, c.out)
+			}
+			if !isBoundary(s, len(kept)) {
+				t.Errorf("%s(%q, %d) cut at %d, inside a cluster", c.name, s, limit, len(kept))
+			}
+		}
+	})
+}
+
+// isBoundary reports whether offset falls between grapheme clusters of s.
+func isBoundary(s string, offset int) bool {
+	at := 0
+	for at < offset {
+		at += NextGrapheme(s[at:])
+	}
+	return at == offset
+}
@@ -0,0 +1,37 @@
+package textutil
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// invisibles are zero-width characters that change nothing a reader sees
+// but split words and patterns apart. The zero-width joiner and non-joiner
+// aren't included: emoji sequences and some scripts depend on them.
+var invisibles = strings.NewReplacer(
+	"\u200b", "", // zero-width space
+	"\u2060", "", // word joiner
+	"\ufeff", "", // byte order mark, or zero-width no-break space
+	"\u00ad", "", // soft hyphen
+)
+
+// smartQuotes maps typographic quotes to their ASCII forms.
+var smartQuotes = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201b", "'",
+	"\u201c", `"`, "\u201d", `"`, "\u201e", `"`, "\u201f", `"`,
+)
+
+// Normalize returns s in Unicode NFC with zero-width characters removed and
+// smart quotes straightened, so text typed, pasted or autocorrected
+// differently compares and matches the same.
+//
+// Invalid UTF-8 becomes U+FFFD first, so removing a character can't join
+// the stray bytes around it into a new one.
+//
+// Offsets into s don't carry over to the result. Check the original text
+// when findings need spans.
+func Normalize(s string) string {
+	s = strings.ToValidUTF8(s, "\ufffd")
+	return norm.NFC.String(smartQuotes.Replace(invisibles.Replace(s)))
+}
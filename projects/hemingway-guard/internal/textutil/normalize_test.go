@@ -0,0 +1,33 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzNormalize(f *testing.F) {
+	for _, s := range graphemeSeeds {
+		f.Add(s)
+	}
+	for _, s := range []string{
+		"café",
+		"“quoted” and ‘single’",
+		"pass\u200bword\u00adbreak\u2060join\ufeff",
+		"e\u200b\u0301 accent after an invisible",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		out := Normalize(s)
+		if !utf8.ValidString(out) {
+			t.Fatalf("Normalize(%q) = %q, which isn't valid UTF-8", s, out)
+		}
+		if strings.ContainsAny(out, "\u200b\u2060\ufeff\u00ad\u2018\u2019\u201a\u201b\u201c\u201d\u201e\u201f") {
+			t.Errorf("Normalize(%q) = %q, which keeps invisibles or smart quotes", s, out)
+		}
+		if again := Normalize(out); again != out {
+			t.Errorf("Normalize(%q) = %q, but normalizing again gives %q", s, out, again)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package textutil
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind classifies a Token.
+type TokenKind int
+
+const (
+	// Word is a run of letters and digits, including apostrophes, hyphens
+	// and periods between them: "don't", "well-known", "3.5".
+	Word TokenKind = iota
+	// Space is a run of whitespace within a line.
+	Space
+	// LineBreak is one line break: "\n", "\r\n", "\r" or a Unicode line or
+	// paragraph separator.
+	LineBreak
+	// Symbol is one grapheme cluster of anything else, such as punctuation
+	// or an emoji.
+	Symbol
+
+	// Only TokenizeChat produces the kinds below.
+
+	// Code is inline code or a fenced code block, backticks included.
+	Code
+	// URL is a link, including Slack's <https://...|label> form.
+	URL
+	// Mention is a user, group or channel reference: "@sam", "#launch",
+	// "<@U024BE7LH>".
+	Mention
+	// Shortcode is an emoji shortcode such as ":tada:", or a Discord
+	// custom emoji.
+	Shortcode
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case Word:
+		return "word"
+	case Space:
+		return "space"
+	case LineBreak:
+		return "line break"
+	case Symbol:
+		return "symbol"
+	case Code:
+		return "code"
+	case URL:
+		return "url"
+	case Mention:
+		return "mention"
+	case Shortcode:
+		return "shortcode"
+	}
+	return "unknown"
+}
+
+// Token is a piece of text at a byte range. The tokens of a string cover
+// it exactly: joined in order, their Text is the original string.
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Start int
+	End   int
+}
+
+// Tokenize splits s into words, whitespace, line breaks and symbols.
+func Tokenize(s string) []Token {
+	var tokens []Token
+	for i := 0; i < len(s); {
+		kind, end := Symbol, i+NextGrapheme(s[i:])
+		r, _ := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case isLineBreak(r):
+			kind = LineBreak
+		case unicode.IsSpace(r):
+			kind, end = Space, spaceEnd(s, i)
+		case isWordRune(r):
+			kind, end = Word, wordEnd(s, i)
+		}
+		tokens = append(tokens, Token{Kind: kind, Text: s[i:end], Start: i, End: end})
+		i = end
+	}
+	return tokens
+}
+
+// Words returns the word tokens of s.
+func Words(s string) []Token {
+	var words []Token
+	for _, t := range Tokenize(s) {
+		if t.Kind == Word {
+			words = append(words, t)
+		}
+	}
+	return words
+}
+
+// CountWords returns the number of words in s.
+func CountWords(s string) int {
+	return len(Words(s))
+}
+
+// TruncateWords shortens s to its first maxWords words, marking the cut
+// with "...". Line breaks and spacing between the kept words are left as
+// they were.
+func TruncateWords(s string, maxWords int) string {
+	return truncateWords(s, s, maxWords)
+}
+
+// truncateWords cuts s after the maxWords-th word of counted, a copy of s
+// with the same offsets, such as one from MaskChat.
+func truncateWords(s, counted string, maxWords int) string {
+	words := Words(counted)
+	if len(words) <= maxWords {
+		return s
+	}
+	if maxWords <= 0 {
+		return ellipsis
+	}
+	return s[:words[maxWords-1].End] + ellipsis
+}
+
+func isLineBreak(r rune) bool {
+	return r == '\n' || r == '\r' || r == '\u0085' || r == '\u2028' || r == '\u2029'
+}
+
+func isWordRune(r rune) bool {
+	return unicode.In(r, unicode.L, unicode.N, unicode.Pc)
+}
+
+// isJoiner reports whether r continues a word when a letter or digit
+// follows it.
+func isJoiner(r rune) bool {
+	return r == '\'' || r == '\u2019' || r == '-' || r == '.'
+}
+
+func spaceEnd(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsSpace(r) || isLineBreak(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+func wordEnd(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if isJoiner(r) {
+			next, _ := utf8.DecodeRuneInString(s[i+size:])
+			if !isWordRune(next) {
+				break
+			}
+		} else if !isWordRune(r) {
+			break
+		}
+		// Keep accents and other marks with their letter
+		i += NextGrapheme(s[i:])
+	}
+	return i
+}
@@ -0,0 +1,41 @@
+package textutil
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzTokenize(f *testing.F) {
+	for _, s := range graphemeSeeds {
+		f.Add(s)
+	}
+	for _, s := range []string{
+		"don't stop, well-known 3.5",
+		"see `go test` and https://example.com/a?b=c",
+		"```\ncode block\n```",
+		"hey @sam, in #launch :tada: <@U024BE7LH> <https://example.com|label>",
+		"ok next para\u0085end",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		for name, tokenize := range map[string]func(string) []Token{"Tokenize": Tokenize, "TokenizeChat": TokenizeChat} {
+			end := 0
+			for i, tok := range tokenize(s) {
+				if tok.Start != end || tok.End <= tok.Start || tok.End > len(s) {
+					t.Fatalf("%s(%q): token %d %+v doesn't follow on from %d", name, s, i, tok, end)
+				}
+				if tok.Text != s[tok.Start:tok.End] {
+					t.Fatalf("%s(%q): token %d text %q, want %q", name, s, i, tok.Text, s[tok.Start:tok.End])
+				}
+				if utf8.ValidString(s) && !utf8.ValidString(tok.Text) {
+					t.Fatalf("%s(%q): token %d %q splits a character", name, s, i, tok.Text)
+				}
+				end = tok.End
+			}
+			if end != len(s) {
+				t.Fatalf("%s(%q): tokens end at %d of %d", name, s, end, len(s))
+			}
+		}
+	})
+}
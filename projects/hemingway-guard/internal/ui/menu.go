@@ -0,0 +1,83 @@
+// Package ui provides the user interface components for HemingwayGuard.
+package ui
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+)
+
+// MenuAction represents menu item actions.
+type MenuAction int
+
+const (
+	MenuActionToggleEnabled MenuAction = 1
+	MenuActionSettings      MenuAction = 2
+	MenuActionQuit          MenuAction = 3
+	MenuActionSnooze15Min   MenuAction = 4
+	MenuActionSnoozeHour    MenuAction = 5
+	MenuActionSnoozeDay     MenuAction = 6 // until tomorrow
+	MenuActionResume        MenuAction = 7
+)
+
+// MenuCallback is called when a menu item is clicked.
+type MenuCallback func(action MenuAction)
+
+var (
+	menuCallbackMu sync.RWMutex
+	menuCallback   MenuCallback
+)
+
+// SetMenuCallback sets the callback for menu item clicks.
+func SetMenuCallback(cb MenuCallback) {
+	menuCallbackMu.Lock()
+	defer menuCallbackMu.Unlock()
+	menuCallback = cb
+}
+
+// Title is the menu bar title for a policy state.
+func Title(st policy.State) string {
+	switch st.Mode {
+	case policy.ModeOff:
+		return "✍️ (off)"
+	case policy.ModeSnoozed:
+		return "✍️ (snoozed)"
+	case policy.ModeOutsideSchedule:
+		return "✍️ (off hours)"
+	}
+	return "✍️"
+}
+
+// Describe is the menu's status line for a policy state at now.
+func Describe(st policy.State, now time.Time) string {
+	switch st.Mode {
+	case policy.ModeOff:
+		return "Switched off"
+	case policy.ModeSnoozed:
+		if st.SnoozedUntil.IsZero() {
+			return "Snoozed until resumed"
+		}
+		return "Snoozed until " + formatWhen(st.SnoozedUntil, now)
+	case policy.ModeOutsideSchedule:
+		if !st.NextChange.IsZero() {
+			return "Outside schedule until " + formatWhen(st.NextChange, now)
+		}
+		return "Outside schedule"
+	}
+	return "Checking messages"
+}
+
+// formatWhen formats t as a time, adding the day unless it is today.
+func formatWhen(t, now time.Time) string {
+	t = t.In(now.Location())
+	y, m, d := t.Date()
+	ny, nm, nd := now.Date()
+	switch {
+	case y == ny && m == nm && d == nd:
+		return t.Format(time.Kitchen)
+	case t.Sub(now) < 7*24*time.Hour:
+		return t.Format("Mon " + time.Kitchen)
+	}
+	return t.Format("Jan 2 " + time.Kitchen)
+}
@@ -1,46 +1,31 @@
-// Package ui provides the user interface components for HemingwayGuard.
 package ui
 
 /*
 #cgo CFLAGS: -x objective-c
 #cgo LDFLAGS: -framework Cocoa
 
+#include <stdlib.h>
+
 // Function declarations - implemented in menubar_darwin.m
 void createStatusItem(const char* title);
 void setStatusItemTitle(const char* title);
 void setEnabledState(int enabled);
+void setStatusLine(const char* text);
+void setSnoozed(int snoozed);
 void removeStatusItem(void);
 */
 import "C"
 
 import (
-	"log"
 	"sync"
-)
+	"time"
+	"unsafe"
 
-// MenuAction represents menu item actions.
-type MenuAction int
-
-const (
-	MenuActionToggleEnabled MenuAction = 1
-	MenuActionSettings      MenuAction = 2
-	MenuActionQuit          MenuAction = 3
+	"github.com/lancekrogers/hemingway-guard/internal/logging"
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
 )
 
-// MenuCallback is called when a menu item is clicked.
-type MenuCallback func(action MenuAction)
-
-var (
-	menuCallbackMu sync.RWMutex
-	menuCallback   MenuCallback
-)
-
-// SetMenuCallback sets the callback for menu item clicks.
-func SetMenuCallback(cb MenuCallback) {
-	menuCallbackMu.Lock()
-	defer menuCallbackMu.Unlock()
-	menuCallback = cb
-}
+var logger = logging.Component("ui")
 
 //export goMenuItemClicked
 func goMenuItemClicked(tag C.int) {
@@ -68,7 +53,7 @@ func NewMenuBar() *MenuBar {
 func (m *MenuBar) Show(title string) {
 	cTitle := C.CString(title)
 	C.createStatusItem(cTitle)
-	log.Printf("Menu bar created with title: %s", title)
+	logger.Debug("menu bar created", "title", title)
 }
 
 // SetTitle updates the status item title.
@@ -97,6 +82,23 @@ func (m *MenuBar) IsEnabled() bool {
 	return m.enabled
 }
 
+// SetState shows a policy state: the title, the Enabled checkbox, the
+// status line and which snooze items are offered.
+func (m *MenuBar) SetState(st policy.State) {
+	m.SetTitle(Title(st))
+	m.SetEnabled(st.Enabled)
+
+	line := C.CString(Describe(st, time.Now()))
+	defer C.free(unsafe.Pointer(line))
+	C.setStatusLine(line)
+
+	if st.Mode == policy.ModeSnoozed {
+		C.setSnoozed(1)
+	} else {
+		C.setSnoozed(0)
+	}
+}
+
 // Hide removes the status item from the menu bar.
 func (m *MenuBar) Hide() {
 	C.removeStatusItem()
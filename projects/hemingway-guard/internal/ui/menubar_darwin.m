@@ -26,6 +26,14 @@ void createStatusItem(const char* title) {
         menuDelegate = [[MenuDelegate alloc] init];
 
         NSMenu *menu = [[NSMenu alloc] init];
+        [menu setAutoenablesItems:NO];
+
+        NSMenuItem *statusLine = [[NSMenuItem alloc] initWithTitle:@"Checking messages"
+                                                            action:nil
+                                                     keyEquivalent:@""];
+        [statusLine setTag:8];
+        [statusLine setEnabled:NO];
+        [menu addItem:statusLine];
 
         NSMenuItem *enableItem = [[NSMenuItem alloc] initWithTitle:@"Enabled"
                                                             action:@selector(menuItemClicked:)
@@ -37,6 +45,19 @@ void createStatusItem(const char* title) {
 
         [menu addItem:[NSMenuItem separatorItem]];
 
+        NSArray *snoozeTitles = @[@"Snooze 15 Minutes", @"Snooze 1 Hour", @"Snooze Until Tomorrow", @"Resume"];
+        for (NSInteger i = 0; i < (NSInteger)[snoozeTitles count]; i++) {
+            NSMenuItem *item = [[NSMenuItem alloc] initWithTitle:snoozeTitles[i]
+                                                          action:@selector(menuItemClicked:)
+                                                   keyEquivalent:@""];
+            [item setTarget:menuDelegate];
+            [item setTag:4 + i];
+            [menu addItem:item];
+        }
+        [[menu itemWithTag:7] setHidden:YES];
+
+        [menu addItem:[NSMenuItem separatorItem]];
+
         NSMenuItem *settingsItem = [[NSMenuItem alloc] initWithTitle:@"Settings..."
                                                               action:@selector(menuItemClicked:)
                                                        keyEquivalent:@","];
@@ -58,22 +79,40 @@ void createStatusItem(const char* title) {
 }
 
 void setStatusItemTitle(const char* title) {
-    if (statusItem == nil) return;
-
+    // Checked on the main queue, after a pending createStatusItem has run
     dispatch_async(dispatch_get_main_queue(), ^{
+        if (statusItem == nil) return;
         [statusItem.button setTitle:[NSString stringWithUTF8String:title]];
     });
 }
 
 void setEnabledState(int enabled) {
-    if (statusItem == nil) return;
-
     dispatch_async(dispatch_get_main_queue(), ^{
+        if (statusItem == nil) return;
         NSMenuItem *enableItem = [statusItem.menu itemWithTag:1];
         [enableItem setState:enabled ? NSControlStateValueOn : NSControlStateValueOff];
     });
 }
 
+void setStatusLine(const char* text) {
+    NSString *line = [NSString stringWithUTF8String:text];
+
+    dispatch_async(dispatch_get_main_queue(), ^{
+        if (statusItem == nil) return;
+        [[statusItem.menu itemWithTag:8] setTitle:line];
+    });
+}
+
+void setSnoozed(int snoozed) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        if (statusItem == nil) return;
+        for (NSInteger tag = 4; tag <= 6; tag++) {
+            [[statusItem.menu itemWithTag:tag] setHidden:snoozed ? YES : NO];
+        }
+        [[statusItem.menu itemWithTag:7] setHidden:snoozed ? NO : YES];
+    });
+}
+
 void removeStatusItem(void) {
     if (statusItem == nil) return;
 
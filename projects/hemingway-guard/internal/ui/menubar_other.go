@@ -0,0 +1,49 @@
+//go:build !darwin
+
+package ui
+
+import (
+	"sync"
+
+	"github.com/lancekrogers/hemingway-guard/internal/policy"
+)
+
+// MenuBar tracks the enabled state off macOS, where there is no menu bar
+// to draw.
+type MenuBar struct {
+	enabled bool
+	mu      sync.Mutex
+}
+
+// NewMenuBar creates a new menu bar manager.
+func NewMenuBar() *MenuBar {
+	return &MenuBar{enabled: true}
+}
+
+// Show does nothing.
+func (m *MenuBar) Show(title string) {}
+
+// SetTitle does nothing.
+func (m *MenuBar) SetTitle(title string) {}
+
+// SetEnabled updates the enabled state.
+func (m *MenuBar) SetEnabled(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = enabled
+}
+
+// IsEnabled returns the current enabled state.
+func (m *MenuBar) IsEnabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enabled
+}
+
+// SetState updates the enabled state from a policy state.
+func (m *MenuBar) SetState(st policy.State) {
+	m.SetEnabled(st.Enabled)
+}
+
+// Hide does nothing.
+func (m *MenuBar) Hide() {}
@@ -6,6 +6,42 @@ import SwiftUI
 import Cocoa
 
 // MARK: - Data Models
+//
+// These mirror internal/ipc/schema/protocol.schema.json. Follow the
+// versioning rules in internal/ipc/schema/README.md when changing them.
+
+/// The protocol version this popover speaks. Must match the schema's
+/// x-protocol-version.
+let ipcProtocolVersion = "1.6"
+
+/// Opens the connection in each direction.
+struct Hello: Codable {
+    let type: String
+    let protocolVersion: String
+    let role: String?
+    let token: String?
+
+    init(role: String, token: String?) {
+        self.type = "hello"
+        self.protocolVersion = ipcProtocolVersion
+        self.role = role
+        self.token = token
+    }
+
+    enum CodingKeys: String, CodingKey {
+        case type
+        case protocolVersion = "protocol_version"
+        case role
+        case token
+    }
+}
+
+/// Just enough of any message to dispatch on its type.
+struct Envelope: Decodable {
+    let type: String
+    let error: String?
+    let text: String?
+}
 
 struct AnalysisResult: Codable {
     let approved: Bool
@@ -14,6 +50,10 @@ struct AnalysisResult: Codable {
     let gradeLevel: Double
     let issues: [String]
     let suggestion: String
+    /// Only the override action sends a blocked message. Added in 1.5.
+    let blocked: Bool?
+
+    var isBlocked: Bool { blocked ?? false }
 
     enum CodingKeys: String, CodingKey {
         case approved
@@ -22,6 +62,7 @@ struct AnalysisResult: Codable {
         case gradeLevel = "grade_level"
         case issues
         case suggestion
+        case blocked
     }
 }
 
@@ -30,13 +71,51 @@ enum UserAction: String, Codable {
     case useSuggestion = "use_suggestion"
     case edit = "edit"
     case cancel = "cancel"
+    case override = "override"
+}
+
+struct Anchor: Codable {
+    let x: Double
+    let y: Double
+}
+
+/// A message held by the Go daemon, waiting for the user's decision.
+struct ReviewRequest: Codable {
+    let type: String
+    let requestId: String
+    let analysis: AnalysisResult?
+    let originalText: String?
+    let anchor: Anchor?
+    /// The choices to offer. Kept as strings so a newer daemon's actions
+    /// don't break decoding. Added in 1.6.
+    let actions: [String]?
+
+    enum CodingKeys: String, CodingKey {
+        case type
+        case requestId = "request_id"
+        case analysis
+        case originalText = "original_text"
+        case anchor
+        case actions
+    }
 }
 
 struct ActionResponse: Codable {
+    let type: String
+    let requestId: String
     let action: UserAction
     let editedText: String?
 
+    init(requestId: String, action: UserAction, editedText: String?) {
+        self.type = "action"
+        self.requestId = requestId
+        self.action = action
+        self.editedText = editedText
+    }
+
     enum CodingKeys: String, CodingKey {
+        case type
+        case requestId = "request_id"
         case action
         case editedText = "edited_text"
     }
@@ -47,19 +126,43 @@ struct ActionResponse: Codable {
 struct ApprovalPopoverView: View {
     let analysis: AnalysisResult
     let originalText: String
+    let actions: [String]?
     let onAction: (UserAction, String?) -> Void
 
     @State private var editedText: String = ""
     @State private var isEditing: Bool = false
 
+    /// Whether the daemon lets the user choose action. Daemons before 1.6
+    /// don't say, so everything but sending a blocked message is offered.
+    func offers(_ action: UserAction) -> Bool {
+        if action == .cancel {
+            return true
+        }
+        if let actions = actions {
+            return actions.contains(action.rawValue)
+        }
+        switch action {
+        case .sendAnyway: return !analysis.isBlocked
+        case .override: return analysis.isBlocked
+        default: return true
+        }
+    }
+
     var body: some View {
         VStack(alignment: .leading, spacing: 12) {
             // Header
             HStack {
-                Image(systemName: analysis.approved ? "checkmark.circle.fill" : "exclamationmark.triangle.fill")
-                    .foregroundColor(analysis.approved ? .green : .orange)
-                Text(analysis.approved ? "Ready to Send" : "Review Suggested")
-                    .font(.headline)
+                if analysis.isBlocked {
+                    Image(systemName: "lock.shield.fill")
+                        .foregroundColor(.red)
+                    Text("Possible Credential")
+                        .font(.headline)
+                } else {
+                    Image(systemName: analysis.approved ? "checkmark.circle.fill" : "exclamationmark.triangle.fill")
+                        .foregroundColor(analysis.approved ? .green : .orange)
+                    Text(analysis.approved ? "Ready to Send" : (offers(.sendAnyway) ? "Review Suggested" : "Change Required"))
+                        .font(.headline)
+                }
                 Spacer()
             }
 
@@ -121,17 +224,20 @@ struct ApprovalPopoverView: View {
                         editedText = originalText
                     }
                     Spacer()
-                    Button("Send Edited") {
+                    // Without send anyway, edits are checked again
+                    Button(offers(.sendAnyway) ? "Send Edited" : "Apply Edit") {
                         onAction(.edit, editedText)
                     }
                     .buttonStyle(.borderedProminent)
                 } else {
-                    Button("Edit") {
-                        editedText = originalText
-                        isEditing = true
+                    if offers(.edit) {
+                        Button("Edit") {
+                            editedText = originalText
+                            isEditing = true
+                        }
                     }
 
-                    if !analysis.suggestion.isEmpty {
+                    if offers(.useSuggestion) && !analysis.suggestion.isEmpty {
                         Button("Use Suggestion") {
                             onAction(.useSuggestion, analysis.suggestion)
                         }
@@ -139,10 +245,20 @@ struct ApprovalPopoverView: View {
 
                     Spacer()
 
-                    Button("Send Anyway") {
-                        onAction(.sendAnyway, nil)
+                    if offers(.override) {
+                        Button("Override and Send", role: .destructive) {
+                            onAction(.override, nil)
+                        }
+                    } else if offers(.sendAnyway) {
+                        Button("Send Anyway") {
+                            onAction(.sendAnyway, nil)
+                        }
+                        .buttonStyle(.borderedProminent)
+                    } else {
+                        Button("Cancel") {
+                            onAction(.cancel, nil)
+                        }
                     }
-                    .buttonStyle(.borderedProminent)
                 }
             }
         }
@@ -173,10 +289,11 @@ class PopoverController: NSObject {
     private var popover: NSPopover?
     private var eventMonitor: Any?
 
-    func show(analysis: AnalysisResult, originalText: String, near point: NSPoint, onAction: @escaping (UserAction, String?) -> Void) {
+    func show(analysis: AnalysisResult, originalText: String, actions: [String]? = nil, near point: NSPoint, onAction: @escaping (UserAction, String?) -> Void) {
         let contentView = ApprovalPopoverView(
             analysis: analysis,
-            originalText: originalText
+            originalText: originalText,
+            actions: actions
         ) { [weak self] action, text in
             onAction(action, text)
             self?.close()
@@ -216,22 +333,136 @@ class PopoverController: NSObject {
 
 // MARK: - IPC Helper (for Go integration)
 
+/// Connects to the Go daemon over its Unix socket. Messages are
+/// newline-delimited JSON: each side opens with "hello", the daemon sends
+/// "review" and "dismiss", and the popover answers each review with an
+/// "action".
 class IPCClient {
     private let socketPath: String
+    private let token: String?
+    private var handle: FileHandle?
+    private var buffer = Data()
+
+    var onReview: ((ReviewRequest) -> Void)?
+    var onDismiss: ((String) -> Void)?
+    var onNotice: ((String) -> Void)?
+
+    /// Mirrors ipc.DefaultSocketPath: a per-user directory under
+    /// $XDG_RUNTIME_DIR or the user's temp directory.
+    static var defaultSocketPath: String {
+        let env = ProcessInfo.processInfo.environment
+        let dir: String
+        if let runtime = env["XDG_RUNTIME_DIR"], !runtime.isEmpty {
+            dir = (runtime as NSString).appendingPathComponent("hemingway-guard")
+        } else {
+            dir = (NSTemporaryDirectory() as NSString).appendingPathComponent("hemingway-guard-\(getuid())")
+        }
+        return (dir as NSString).appendingPathComponent("popover.sock")
+    }
 
-    init(socketPath: String = "/tmp/hemingway-guard.sock") {
+    init(socketPath: String = IPCClient.defaultSocketPath,
+         token: String? = ProcessInfo.processInfo.environment["HEMINGWAY_GUARD_TOKEN"]) {
         self.socketPath = socketPath
+        self.token = token
     }
 
-    func sendAction(_ action: UserAction, editedText: String? = nil) {
-        let response = ActionResponse(action: action, editedText: editedText)
+    func connect() -> Bool {
+        let fd = socket(AF_UNIX, SOCK_STREAM, 0)
+        guard fd >= 0 else { return false }
+
+        var addr = sockaddr_un()
+        addr.sun_family = sa_family_t(AF_UNIX)
+        let pathBytes = Array(socketPath.utf8CString)
+        guard pathBytes.count <= MemoryLayout.size(ofValue: addr.sun_path) else {
+            close(fd)
+            return false
+        }
+        withUnsafeMutableBytes(of: &addr.sun_path) { raw in
+            raw.copyBytes(from: pathBytes.map { UInt8(bitPattern: $0) })
+        }
 
-        guard let data = try? JSONEncoder().encode(response) else {
-            print("Failed to encode action response")
+        let result = withUnsafePointer(to: &addr) {
+            $0.withMemoryRebound(to: sockaddr.self, capacity: 1) {
+                Darwin.connect(fd, $0, socklen_t(MemoryLayout<sockaddr_un>.size))
+            }
+        }
+        guard result == 0 else {
+            close(fd)
+            return false
+        }
+
+        let handle = FileHandle(fileDescriptor: fd, closeOnDealloc: true)
+        handle.readabilityHandler = { [weak self] h in
+            let data = h.availableData
+            guard !data.isEmpty else {
+                h.readabilityHandler = nil
+                return
+            }
+            self?.receive(data)
+        }
+        self.handle = handle
+        send(Hello(role: "popover", token: token))
+        return true
+    }
+
+    func sendAction(requestId: String, _ action: UserAction, editedText: String? = nil) {
+        send(ActionResponse(requestId: requestId, action: action, editedText: editedText))
+    }
+
+    private func send<T: Encodable>(_ message: T) {
+        guard var data = try? JSONEncoder().encode(message) else {
+            print("Failed to encode \(T.self)")
             return
         }
+        data.append(0x0A)
+        handle?.write(data)
+    }
+
+    /// Peers are compatible when they share the major version.
+    private func isCompatible(_ version: String) -> Bool {
+        version.split(separator: ".").first == ipcProtocolVersion.split(separator: ".").first
+    }
 
-        // TODO: Send via Unix socket to Go backend
-        print("Would send to Go: \(String(data: data, encoding: .utf8) ?? "")")
+    private func receive(_ data: Data) {
+        buffer.append(data)
+        while let newline = buffer.firstIndex(of: 0x0A) {
+            let line = buffer.subdata(in: buffer.startIndex..<newline)
+            buffer.removeSubrange(buffer.startIndex...newline)
+            guard !line.isEmpty,
+                  let envelope = try? JSONDecoder().decode(Envelope.self, from: line) else {
+                continue
+            }
+
+            switch envelope.type {
+            case "hello":
+                if let hello = try? JSONDecoder().decode(Hello.self, from: line),
+                   !isCompatible(hello.protocolVersion) {
+                    print("Daemon speaks protocol \(hello.protocolVersion), expected \(ipcProtocolVersion)")
+                    handle?.readabilityHandler = nil
+                }
+            case "error":
+                print("Daemon error: \(envelope.error ?? "unknown")")
+            case "notice":
+                if let text = envelope.text {
+                    DispatchQueue.main.async { [weak self] in
+                        self?.onNotice?(text)
+                    }
+                }
+            case "review", "dismiss":
+                guard let message = try? JSONDecoder().decode(ReviewRequest.self, from: line) else {
+                    continue
+                }
+                DispatchQueue.main.async { [weak self] in
+                    if message.type == "review" {
+                        self?.onReview?(message)
+                    } else {
+                        self?.onDismiss?(message.requestId)
+                    }
+                }
+            default:
+                // Newer daemons may send types this popover doesn't know
+                break
+            }
+        }
     }
 }
@@ -1,12 +1,29 @@
 // Package apps provides target application detection for HemingwayGuard.
 package apps
 
+import "strings"
+
 // TargetApp represents a messaging application to monitor.
 type TargetApp struct {
 	Name     string
 	BundleID string
 	// TextFieldRoles are the AX roles to look for in this app
 	TextFieldRoles []string
+
+	// ComposerRules tell message boxes apart from other text fields
+	// (search bars, rename dialogs) in this app
+	ComposerRules ComposerRules
+}
+
+// ComposerRules describe which text fields in an app are message composers.
+// Placeholder and identifier matches are case-insensitive prefixes.
+type ComposerRules struct {
+	// ExcludedPlaceholders mark fields that are never composers, e.g. "Search"
+	ExcludedPlaceholders []string
+	// ExcludedIdentifiers mark fields by their AXIdentifier
+	ExcludedIdentifiers []string
+	// ExcludedAncestorRoles mark fields inside these containers, e.g. settings sheets
+	ExcludedAncestorRoles []string
 }
 
 // DefaultTargets returns the default list of messaging apps to monitor.
@@ -16,16 +33,30 @@ func DefaultTargets() []TargetApp {
 			Name:           "Messages",
 			BundleID:       "com.apple.MobileSMS",
 			TextFieldRoles: []string{"AXTextArea", "AXTextField"},
+			ComposerRules: ComposerRules{
+				ExcludedPlaceholders:  []string{"Search", "To:"},
+				ExcludedAncestorRoles: []string{"AXSheet", "AXToolbar"},
+			},
 		},
 		{
 			Name:           "Slack",
 			BundleID:       "com.tinyspeck.slackmacgap",
 			TextFieldRoles: []string{"AXTextArea", "AXTextField"},
+			ComposerRules: ComposerRules{
+				ExcludedPlaceholders:  []string{"Search", "Find", "Jump to", "Add a topic", "Add a description", "Set a status"},
+				ExcludedIdentifiers:   []string{"search", "channel-name", "status"},
+				ExcludedAncestorRoles: []string{"AXSheet"},
+			},
 		},
 		{
 			Name:           "Discord",
 			BundleID:       "com.hnc.Discord",
 			TextFieldRoles: []string{"AXTextArea", "AXTextField"},
+			ComposerRules: ComposerRules{
+				ExcludedPlaceholders:  []string{"Search", "Find or start a conversation", "Channel name"},
+				ExcludedIdentifiers:   []string{"search", "channel-name"},
+				ExcludedAncestorRoles: []string{"AXSheet"},
+			},
 		},
 	}
 }
@@ -54,3 +85,17 @@ func FindTarget(bundleID string) *TargetApp {
 	}
 	return nil
 }
+
+// MatchesPrefix reports whether s starts with any of prefixes, ignoring case.
+func MatchesPrefix(s string, prefixes []string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return false
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}